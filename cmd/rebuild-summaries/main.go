@@ -0,0 +1,27 @@
+// Command rebuild-summaries recalcule les résumés journaliers des tableaux de bord
+// à partir des entrées de caisse, puis vérifie qu'ils leur correspondent.
+package main
+
+import (
+	"log"
+
+	"github.com/kgermando/appartment-app-api/database"
+	"github.com/kgermando/appartment-app-api/models"
+)
+
+func main() {
+	database.Connect()
+
+	if err := models.RebuildDailySummaries(database.DB); err != nil {
+		log.Fatal("Failed to rebuild daily summaries: ", err)
+	}
+
+	mismatches, err := models.CheckDailySummaries(database.DB)
+	if err != nil {
+		log.Fatal("Failed to check daily summaries: ", err)
+	}
+	if len(mismatches) > 0 {
+		log.Fatalf("%d daily summaries differ from the cash entries", len(mismatches))
+	}
+	log.Println("Daily summaries rebuilt")
+}
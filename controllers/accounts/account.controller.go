@@ -0,0 +1,278 @@
+package accounts
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kgermando/appartment-app-api/database"
+	"github.com/kgermando/appartment-app-api/models"
+	"github.com/kgermando/appartment-app-api/utils"
+)
+
+// accountBalance est un compte avec ses soldes actuels par devise
+type accountBalance struct {
+	models.TreasuryAccount
+	Balances map[string]utils.Amount `json:"balances"`
+}
+
+// Get all treasury accounts with their current balance, filters: kind, active
+func GetAllAccounts(c *fiber.Ctx) error {
+	db := database.DB
+
+	query := db.Model(&models.TreasuryAccount{})
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	if active := c.Query("active"); active != "" {
+		query = query.Where("active = ?", active == "true")
+	}
+
+	var accounts []models.TreasuryAccount
+	if err := query.Order("kind, name").Find(&accounts).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch accounts",
+			"error":   err.Error(),
+		})
+	}
+
+	now := time.Now()
+	data := make([]accountBalance, 0, len(accounts))
+	totals := make(map[string]utils.Amount)
+	for _, account := range accounts {
+		balances, err := models.AccountBalance(db, account, now)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Failed to compute balances",
+				"error":   err.Error(),
+			})
+		}
+		data = append(data, accountBalance{TreasuryAccount: account, Balances: balances})
+		for currency, amount := range balances {
+			totals[currency] = totals[currency].Add(amount)
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "All accounts",
+		"data":    data,
+		"totals": fiber.Map{
+			"balances": totals,
+		},
+	})
+}
+
+// Get one account
+func GetAccount(c *fiber.Ctx) error {
+	db := database.DB
+
+	var account models.TreasuryAccount
+	db.Where("uuid = ?", c.Params("uuid")).First(&account)
+	if account.UUID == "" {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No account found",
+			"data":    nil,
+		})
+	}
+
+	balances, err := models.AccountBalance(db, account, time.Now())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to compute balance",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Account found",
+		"data":    accountBalance{TreasuryAccount: account, Balances: balances},
+	})
+}
+
+// Create an account
+func CreateAccount(c *fiber.Ctx) error {
+	p := &models.TreasuryAccount{}
+
+	if err := c.BodyParser(&p); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid input",
+			"data":    nil,
+		})
+	}
+
+	if p.Name == "" || !models.IsValidAccountKind(p.Kind) {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Name is required and kind must be one of petty_cash, bank or mobile_money",
+			"data":    nil,
+		})
+	}
+	if p.Kind != models.AccountPettyCash && p.Provider == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Provider is required for bank and mobile money accounts",
+			"data":    nil,
+		})
+	}
+
+	account := &models.TreasuryAccount{
+		UUID:              utils.GenerateUUID(),
+		Name:              p.Name,
+		Kind:              p.Kind,
+		Provider:          p.Provider,
+		AccountNumber:     p.AccountNumber,
+		Building:          p.Building,
+		OpeningBalanceUSD: p.OpeningBalanceUSD,
+		OpeningBalanceCDF: p.OpeningBalanceCDF,
+		OpeningDate:       p.OpeningDate,
+		Active:            true,
+	}
+	if account.OpeningDate.IsZero() {
+		account.OpeningDate = time.Now()
+	}
+
+	if err := database.DB.Create(account).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to create account",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Account created success",
+		"data":    account,
+	})
+}
+
+// Update an account. Opening balances can only change while the account has no movement.
+func UpdateAccount(c *fiber.Ctx) error {
+	db := database.DB
+
+	type UpdateDataInput struct {
+		Name              string        `json:"name"`
+		Provider          string        `json:"provider"`
+		AccountNumber     string        `json:"account_number"`
+		Building          string        `json:"building"`
+		OpeningBalanceUSD *utils.Amount `json:"opening_balance_usd"`
+		OpeningBalanceCDF *utils.Amount `json:"opening_balance_cdf"`
+		Active            *bool         `json:"active"`
+	}
+
+	var updateData UpdateDataInput
+	if err := c.BodyParser(&updateData); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Review your input",
+			"data":    nil,
+		})
+	}
+
+	var account models.TreasuryAccount
+	db.Where("uuid = ?", c.Params("uuid")).First(&account)
+	if account.UUID == "" {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No account found",
+			"data":    nil,
+		})
+	}
+
+	if updateData.OpeningBalanceUSD != nil || updateData.OpeningBalanceCDF != nil {
+		_, movements, err := models.AccountLedger(db, account, nil, nil)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Failed to check account movements",
+				"error":   err.Error(),
+			})
+		}
+		if len(movements) > 0 {
+			return c.Status(409).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Opening balances cannot change once the account has movements",
+				"data":    nil,
+			})
+		}
+		if updateData.OpeningBalanceUSD != nil {
+			account.OpeningBalanceUSD = *updateData.OpeningBalanceUSD
+		}
+		if updateData.OpeningBalanceCDF != nil {
+			account.OpeningBalanceCDF = *updateData.OpeningBalanceCDF
+		}
+	}
+
+	if updateData.Name != "" {
+		account.Name = updateData.Name
+	}
+	account.Provider = updateData.Provider
+	account.AccountNumber = updateData.AccountNumber
+	account.Building = updateData.Building
+	if updateData.Active != nil {
+		account.Active = *updateData.Active
+	}
+
+	db.Save(&account)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Account updated success",
+		"data":    account,
+	})
+}
+
+// Get the movements of an account with the running balance, ?start_date=&end_date= (YYYY-MM-DD)
+func GetAccountLedger(c *fiber.Ctx) error {
+	db := database.DB
+
+	var account models.TreasuryAccount
+	db.Where("uuid = ?", c.Params("uuid")).First(&account)
+	if account.UUID == "" {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No account found",
+			"data":    nil,
+		})
+	}
+
+	filter, err := utils.ParseQueryFilter(c, 0)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+			"data":    nil,
+		})
+	}
+
+	opening, movements, err := models.AccountLedger(db, account, filter.Start, filter.End)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch account movements",
+			"error":   err.Error(),
+		})
+	}
+
+	closing := opening
+	if len(movements) > 0 {
+		closing = movements[len(movements)-1].Balances
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Account ledger",
+		"data": fiber.Map{
+			"account":          account,
+			"opening_balances": opening,
+			"movements":        movements,
+			"closing_balances": closing,
+		},
+	})
+}
@@ -0,0 +1,126 @@
+package accounts
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kgermando/appartment-app-api/database"
+	"github.com/kgermando/appartment-app-api/models"
+	"github.com/kgermando/appartment-app-api/utils"
+	"gorm.io/gorm"
+)
+
+// Get the transfers of an account, in and out
+func GetAccountTransfers(c *fiber.Ctx) error {
+	db := database.DB
+	uuid := c.Params("uuid")
+
+	var transfers []models.TreasuryTransfer
+	err := db.Preload("FromAccount").Preload("ToAccount").
+		Where("from_account_uuid = ? OR to_account_uuid = ?", uuid, uuid).
+		Order("created_at DESC").
+		Find(&transfers).Error
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch transfers",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "All transfers",
+		"data":    transfers,
+	})
+}
+
+// Transfer funds between two accounts. A transfer is neither an income nor an
+// expense and cannot be modified: a wrong transfer is corrected by a transfer back.
+func CreateTransfer(c *fiber.Ctx) error {
+	db := database.DB
+
+	p := &models.TreasuryTransfer{}
+	if err := c.BodyParser(&p); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid input",
+			"data":    nil,
+		})
+	}
+
+	if p.FromAccountUUID == "" || p.ToAccountUUID == "" || p.Motif == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Form not complete - from_account_uuid, to_account_uuid and motif are required",
+			"data":    nil,
+		})
+	}
+	if p.FromAccountUUID == p.ToAccountUUID {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Source and destination accounts must be different",
+			"data":    nil,
+		})
+	}
+	if p.AmountUSD.IsNegative() || p.AmountCDF.IsNegative() || (p.AmountUSD.IsZero() && p.AmountCDF.IsZero()) {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "amount_usd or amount_cdf must be greater than 0",
+			"data":    nil,
+		})
+	}
+
+	transfer := &models.TreasuryTransfer{
+		UUID:            utils.GenerateUUID(),
+		FromAccountUUID: p.FromAccountUUID,
+		ToAccountUUID:   p.ToAccountUUID,
+		AmountUSD:       p.AmountUSD,
+		AmountCDF:       p.AmountCDF,
+		Motif:           p.Motif,
+		Signature:       p.Signature,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := models.EnsurePeriodOpen(tx, time.Now()); err != nil {
+			return fiber.NewError(409, err.Error())
+		}
+
+		var accounts []models.TreasuryAccount
+		if err := tx.Where("uuid IN ?", []string{p.FromAccountUUID, p.ToAccountUUID}).Find(&accounts).Error; err != nil {
+			return err
+		}
+		if len(accounts) != 2 {
+			return fiber.NewError(404, "Treasury account not found")
+		}
+		for _, account := range accounts {
+			if !account.Active {
+				return fiber.NewError(400, "Treasury account "+account.Name+" is closed")
+			}
+		}
+
+		return tx.Omit("FromAccount", "ToAccount").Create(transfer).Error
+	})
+	if err != nil {
+		var fe *fiber.Error
+		if errors.As(err, &fe) {
+			return c.Status(fe.Code).JSON(fiber.Map{
+				"status":  "error",
+				"message": fe.Message,
+				"data":    nil,
+			})
+		}
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to create transfer",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Transfer created success",
+		"data":    transfer,
+	})
+}
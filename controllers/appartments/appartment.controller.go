@@ -1,112 +1,73 @@
 package appartments
 
 import (
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/kgermando/appartment-app-api/database"
 	"github.com/kgermando/appartment-app-api/models"
 	"github.com/kgermando/appartment-app-api/utils"
+	"gorm.io/gorm"
 )
 
 // Paginate
 func GetPaginatedAppartments(c *fiber.Ctx) error {
-	db := database.DB
-
-	managerUUID := c.Params("manager_uuid")
-
-	// Parse query parameters for pagination
-	page, err := strconv.Atoi(c.Query("page", "1"))
-	if err != nil || page <= 0 {
-		page = 1
-	}
-	limit, err := strconv.Atoi(c.Query("limit", "15"))
-	if err != nil || limit <= 0 {
-		limit = 15
-	}
-	offset := (page - 1) * limit
-
-	// Parse search query
-	search := c.Query("search", "")
+	return paginateAppartments(c, c.Params("manager_uuid"))
+}
 
-	var appartments []models.Appartment
-	var totalRecords int64
+func GetPaginatedAppartmentsManagerGeneral(c *fiber.Ctx) error {
+	return paginateAppartments(c, "")
+}
 
-	// Count total records matching the search query
-	db.Model(&models.Appartment{}).
-		Where("manager_uuid = ?", managerUUID).
-		Where("name ILIKE ? OR number ILIKE ? OR status ILIKE ?", "%"+search+"%", "%"+search+"%", "%"+search+"%").
-		Count(&totalRecords)
-
-	err = db.
-		Where("manager_uuid = ?", managerUUID).
-		Where("name ILIKE ? OR number ILIKE ? OR status ILIKE ?", "%"+search+"%", "%"+search+"%", "%"+search+"%").
-		Offset(offset).
-		Limit(limit).
-		Order("appartments.updated_at DESC").
-		Preload("Manager").
-		Preload("Caisses").
-		Find(&appartments).Error
+// paginateAppartments applique la recherche, les filtres et le tri communs aux listes paginées.
+// Un managerUUID non vide limite la liste aux appartements de ce gestionnaire.
+func paginateAppartments(c *fiber.Ctx, managerUUID string) error {
+	db := database.DB
 
+	// Parse pagination and search filters
+	filter, err := utils.ParseQueryFilter(c, 15)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
+		return c.Status(400).JSON(fiber.Map{
 			"status":  "error",
-			"message": "Failed to fetch Appartments",
-			"error":   err.Error(),
+			"message": err.Error(),
+			"data":    nil,
 		})
 	}
 
-	// Calculate total pages
-	totalPages := int((totalRecords + int64(limit) - 1) / int64(limit))
-
-	//  Prepare pagination metadata
-	pagination := map[string]interface{}{
-		"total_records": totalRecords,
-		"total_pages":   totalPages,
-		"current_page":  page,
-		"page_size":     limit,
+	query := filter.ApplySearch(db.Model(&models.Appartment{}), "name", "number", "status")
+	if managerUUID != "" {
+		query = query.Where("manager_uuid = ?", managerUUID)
 	}
 
-	// Return response
-	return c.JSON(fiber.Map{
-		"status":     "success",
-		"message":    "Appartments retrieved successfully",
-		"data":       appartments,
-		"pagination": pagination,
-	})
-}
-
-func GetPaginatedAppartmentsManagerGeneral(c *fiber.Ctx) error {
-	db := database.DB
-
-	// Parse query parameters for pagination
-	page, err := strconv.Atoi(c.Query("page", "1"))
-	if err != nil || page <= 0 {
-		page = 1
+	// Structured filters and sorting
+	query, err = applyAppartmentFilters(c, query)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+			"data":    nil,
+		})
 	}
-	limit, err := strconv.Atoi(c.Query("limit", "15"))
-	if err != nil || limit <= 0 {
-		limit = 15
+	order, err := appartmentOrder(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+			"data":    nil,
+		})
 	}
-	offset := (page - 1) * limit
-
-	// Parse search query
-	search := c.Query("search", "")
 
 	var appartments []models.Appartment
 	var totalRecords int64
 
 	// Count total records matching the search query
-	db.Model(&models.Appartment{}).
-		Where("name ILIKE ? OR number ILIKE ? OR status ILIKE ?", "%"+search+"%", "%"+search+"%", "%"+search+"%").
-		Count(&totalRecords)
-
-	err = db.
-		Where("name ILIKE ? OR number ILIKE ? OR status ILIKE ?", "%"+search+"%", "%"+search+"%", "%"+search+"%").
-		Offset(offset).
-		Limit(limit).
-		Order("appartments.updated_at DESC").
+	query.Session(&gorm.Session{}).Count(&totalRecords)
+
+	err = filter.Paginate(query).
+		Order(order).
 		Preload("Manager").
 		Preload("Caisses").
 		Find(&appartments).Error
@@ -119,17 +80,18 @@ func GetPaginatedAppartmentsManagerGeneral(c *fiber.Ctx) error {
 		})
 	}
 
-	// Calculate total pages
-	totalPages := int((totalRecords + int64(limit) - 1) / int64(limit))
-
-	//  Prepare pagination metadata
-	pagination := map[string]interface{}{
-		"total_records": totalRecords,
-		"total_pages":   totalPages,
-		"current_page":  page,
-		"page_size":     limit,
+	// Attach cover thumbnails for the gallery preview
+	if err := attachCoverThumbnails(db, appartments); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch Appartment photos",
+			"error":   err.Error(),
+		})
 	}
 
+	// Prepare pagination metadata
+	pagination := filter.Pagination(totalRecords)
+
 	// Return response
 	return c.JSON(fiber.Map{
 		"status":     "success",
@@ -169,7 +131,13 @@ func GetAppartment(c *fiber.Ctx) error {
 	uuid := c.Params("uuid")
 	db := database.DB
 	var appartment models.Appartment
-	db.Where("uuid = ?", uuid).Preload("Manager").Preload("Caisses").First(&appartment)
+	db.Where("uuid = ?", uuid).
+		Preload("Manager").
+		Preload("Caisses").
+		Preload("Photos", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position ASC")
+		}).
+		First(&appartment)
 	if appartment.Name == "" {
 		return c.Status(404).JSON(
 			fiber.Map{
@@ -203,8 +171,17 @@ func GetAppartmentStats(c *fiber.Ctx) error {
 		})
 	}
 
-	// Obtenir l'année courante ou depuis les paramètres de requête
-	year := time.Now().Year()
+	loc, err := utils.RequestLocation(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+			"data":    nil,
+		})
+	}
+
+	// Obtenir l'année courante (dans le fuseau demandé) ou depuis les paramètres de requête
+	year := time.Now().In(loc).Year()
 	if yearParam := c.Query("year"); yearParam != "" {
 		if parsedYear, err := strconv.Atoi(yearParam); err == nil && parsedYear > 0 {
 			year = parsedYear
@@ -212,26 +189,27 @@ func GetAppartmentStats(c *fiber.Ctx) error {
 	}
 
 	// Initialiser les statistiques pour les 12 mois
-	monthlyStats := make(map[string]map[string]float64)
+	monthlyStats := make(map[string]map[string]utils.Amount)
 	months := []string{"January", "February", "March", "April", "May", "June",
 		"July", "August", "September", "October", "November", "December"}
 
+	// Une clé income_<devise> et expense_<devise> par devise activée
+	currencies := utils.EnabledCurrencies()
 	for _, month := range months {
-		monthlyStats[month] = map[string]float64{
-			"income_cdf":  0.0,
-			"income_usd":  0.0,
-			"expense_cdf": 0.0,
-			"expense_usd": 0.0,
+		monthlyStats[month] = make(map[string]utils.Amount)
+		for _, cur := range currencies {
+			monthlyStats[month]["income_"+strings.ToLower(cur)] = utils.Zero
+			monthlyStats[month]["expense_"+strings.ToLower(cur)] = utils.Zero
 		}
 	}
 
 	// Récupérer toutes les entrées de la caisse pour cet appartement
 	var caisses []models.Caisse
-	startDate := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
-	endDate := time.Date(year, 12, 31, 23, 59, 59, 0, time.UTC)
+	startDate := time.Date(year, 1, 1, 0, 0, 0, 0, loc)
+	endDate := startDate.AddDate(1, 0, 0)
 
-	err := db.Where("appartment_uuid = ? AND created_at >= ? AND created_at <= ?",
-		uuid, startDate, endDate).Find(&caisses).Error
+	err = db.Scopes(models.PostedCaisses).Where("appartment_uuid = ? AND transaction_date >= ? AND transaction_date < ?",
+		uuid, startDate, endDate).Preload("Lines").Find(&caisses).Error
 
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
@@ -243,34 +221,49 @@ func GetAppartmentStats(c *fiber.Ctx) error {
 
 	// Calculer les totaux par mois
 	for _, caisse := range caisses {
-		monthIndex := int(caisse.CreatedAt.Month()) - 1
+		monthIndex := int(caisse.TransactionDate.In(loc).Month()) - 1
 		if monthIndex >= 0 && monthIndex < 12 {
 			monthName := months[monthIndex]
+			prefix := ""
 			switch caisse.Type {
 			case "Income":
-				monthlyStats[monthName]["income_cdf"] += caisse.DeviceCDF
-				monthlyStats[monthName]["income_usd"] += caisse.DeviceUSD
+				prefix = "income"
 			case "Expense":
-				monthlyStats[monthName]["expense_cdf"] += caisse.DeviceCDF
-				monthlyStats[monthName]["expense_usd"] += caisse.DeviceUSD
+				prefix = "expense"
+			default:
+				continue
+			}
+			for _, line := range caisse.Lines {
+				// Une devise désactivée depuis reste dans les totaux de ses entrées
+				if !slices.Contains(currencies, line.Currency) {
+					currencies = append(currencies, line.Currency)
+				}
+				key := prefix + "_" + strings.ToLower(line.Currency)
+				monthlyStats[monthName][key] = monthlyStats[monthName][key].Add(line.Amount)
 			}
 		}
 	}
 
 	// Calculer les totaux mensuels et annuels
-	var totalYearIncomeCDF, totalYearIncomeUSD, totalYearExpenseCDF, totalYearExpenseUSD float64
+	yearlyTotals := make(map[string]utils.Amount)
 	for _, month := range months {
-		// Calculer les totaux pour chaque mois
-		monthlyStats[month]["total_income_cdf"] = monthlyStats[month]["income_cdf"]
-		monthlyStats[month]["total_income_usd"] = monthlyStats[month]["income_usd"]
-		monthlyStats[month]["total_expense_cdf"] = monthlyStats[month]["expense_cdf"]
-		monthlyStats[month]["total_expense_usd"] = monthlyStats[month]["expense_usd"]
-
-		// Calculer les totaux annuels
-		totalYearIncomeCDF += monthlyStats[month]["income_cdf"]
-		totalYearIncomeUSD += monthlyStats[month]["income_usd"]
-		totalYearExpenseCDF += monthlyStats[month]["expense_cdf"]
-		totalYearExpenseUSD += monthlyStats[month]["expense_usd"]
+		// Calculer les totaux pour chaque mois et les totaux annuels, par devise
+		for _, cur := range currencies {
+			for _, key := range []string{"income_" + strings.ToLower(cur), "expense_" + strings.ToLower(cur)} {
+				monthlyStats[month]["total_"+key] = monthlyStats[month][key]
+				yearlyTotals["total_"+key] = yearlyTotals["total_"+key].Add(monthlyStats[month][key])
+			}
+		}
+	}
+
+	currencyInfo := map[string]string{
+		"cdf": "Francs Congolais",
+		"usd": "US Dollars",
+	}
+	for _, cur := range currencies {
+		if _, ok := currencyInfo[strings.ToLower(cur)]; !ok {
+			currencyInfo[strings.ToLower(cur)] = cur
+		}
 	}
 
 	// Préparer la réponse
@@ -281,6 +274,7 @@ func GetAppartmentStats(c *fiber.Ctx) error {
 			"updated_at":     appartment.UpdatedAt,
 			"name":           appartment.Name,
 			"number":         appartment.Number,
+			"area":           appartment.Area,
 			"surface":        appartment.Surface,
 			"rooms":          appartment.Rooms,
 			"bathrooms":      appartment.Bathrooms,
@@ -295,16 +289,8 @@ func GetAppartmentStats(c *fiber.Ctx) error {
 		},
 		"year":          year,
 		"monthly_stats": monthlyStats,
-		"yearly_totals": map[string]float64{
-			"total_income_cdf":  totalYearIncomeCDF,
-			"total_income_usd":  totalYearIncomeUSD,
-			"total_expense_cdf": totalYearExpenseCDF,
-			"total_expense_usd": totalYearExpenseUSD,
-		},
-		"currency_info": map[string]string{
-			"cdf": "Francs Congolais",
-			"usd": "US Dollars",
-		},
+		"yearly_totals": yearlyTotals,
+		"currency_info": currencyInfo,
 	}
 
 	return c.JSON(fiber.Map{
@@ -318,19 +304,20 @@ func GetAppartmentStats(c *fiber.Ctx) error {
 func CreateAppartment(c *fiber.Ctx) error {
 	// Define input struct with string for date field
 	type CreateAppartmentInput struct {
-		Name          string    `json:"name"`
-		Number        string    `json:"number"`
-		Surface       float64   `json:"surface"`
-		Rooms         int       `json:"rooms"`
-		Bathrooms     int       `json:"bathrooms"`
-		Balcony       bool      `json:"balcony"`
-		Furnished     bool      `json:"furnished"`
-		MonthlyRent   float64   `json:"monthly_rent"`
-		GarantieMonth float64   `json:"garantie_month"`
-		Garantie      float64   `json:"garantie_montant"`
-		Echeance      time.Time `json:"echeance"`
-		Status        string    `json:"status"`
-		ManagerUUID   string    `json:"manager_uuid"`
+		Name          string       `json:"name"`
+		Number        string       `json:"number"`
+		Area          string       `json:"area"`
+		Surface       float64      `json:"surface"`
+		Rooms         int          `json:"rooms"`
+		Bathrooms     int          `json:"bathrooms"`
+		Balcony       bool         `json:"balcony"`
+		Furnished     bool         `json:"furnished"`
+		MonthlyRent   utils.Amount `json:"monthly_rent"`
+		GarantieMonth float64      `json:"garantie_month"`
+		Garantie      utils.Amount `json:"garantie_montant"`
+		Echeance      time.Time    `json:"echeance"`
+		Status        string       `json:"status"`
+		ManagerUUID   string       `json:"manager_uuid"`
 	}
 
 	var input CreateAppartmentInput
@@ -346,6 +333,7 @@ func CreateAppartment(c *fiber.Ctx) error {
 	appartment := &models.Appartment{
 		Name:          input.Name,
 		Number:        input.Number,
+		Area:          input.Area,
 		Surface:       input.Surface,
 		Rooms:         input.Rooms,
 		Bathrooms:     input.Bathrooms,
@@ -378,19 +366,20 @@ func UpdateAppartment(c *fiber.Ctx) error {
 	db := database.DB
 
 	type UpdateDataInput struct {
-		Name          string    `json:"name"`
-		Number        string    `json:"number"`
-		Surface       float64   `json:"surface"`
-		Rooms         int       `json:"rooms"`
-		Bathrooms     int       `json:"bathrooms"`
-		Balcony       bool      `json:"balcony"`
-		Furnished     bool      `json:"furnished"`
-		MonthlyRent   float64   `json:"monthly_rent"`
-		GarantieMonth float64   `json:"garantie_month"`
-		Garantie      float64   `json:"garantie_montant"`
-		Echeance      time.Time `json:"echeance"` // Accept as string
-		Status        string    `json:"status"`
-		ManagerUUID   string    `json:"manager_uuid"`
+		Name          string       `json:"name"`
+		Number        string       `json:"number"`
+		Area          string       `json:"area"`
+		Surface       float64      `json:"surface"`
+		Rooms         int          `json:"rooms"`
+		Bathrooms     int          `json:"bathrooms"`
+		Balcony       bool         `json:"balcony"`
+		Furnished     bool         `json:"furnished"`
+		MonthlyRent   utils.Amount `json:"monthly_rent"`
+		GarantieMonth float64      `json:"garantie_month"`
+		Garantie      utils.Amount `json:"garantie_montant"`
+		Echeance      time.Time    `json:"echeance"` // Accept as string
+		Status        string       `json:"status"`
+		ManagerUUID   string       `json:"manager_uuid"`
 	}
 
 	var updateData UpdateDataInput
@@ -410,6 +399,7 @@ func UpdateAppartment(c *fiber.Ctx) error {
 	db.Where("uuid = ?", uuid).First(&appartment)
 	appartment.Name = updateData.Name
 	appartment.Number = updateData.Number
+	appartment.Area = updateData.Area
 	appartment.Surface = updateData.Surface
 	appartment.Rooms = updateData.Rooms
 	appartment.Bathrooms = updateData.Bathrooms
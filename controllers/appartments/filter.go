@@ -0,0 +1,156 @@
+package appartments
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// appartmentStatuses liste les statuts acceptés par le filtre status
+var appartmentStatuses = map[string]bool{
+	"available":   true,
+	"occupied":    true,
+	"maintenance": true,
+	"unavailable": true,
+}
+
+// appartmentSortFields associe les champs triables à leur colonne
+var appartmentSortFields = map[string]string{
+	"name":         "appartments.name",
+	"number":       "appartments.number",
+	"surface":      "appartments.surface",
+	"rooms":        "appartments.rooms",
+	"bathrooms":    "appartments.bathrooms",
+	"monthly_rent": "appartments.monthly_rent",
+	"echeance":     "appartments.echeance",
+	"status":       "appartments.status",
+	"created_at":   "appartments.created_at",
+	"updated_at":   "appartments.updated_at",
+}
+
+// applyAppartmentFilters applique les filtres structurés de la requête :
+// status (liste séparée par des virgules), min_rent, max_rent, rooms, bathrooms,
+// furnished, balcony, min_surface, max_surface, manager_uuid,
+// echeance_after et echeance_before (YYYY-MM-DD, bornes incluses)
+func applyAppartmentFilters(c *fiber.Ctx, query *gorm.DB) (*gorm.DB, error) {
+	if v := c.Query("status"); v != "" {
+		var statuses []string
+		for _, status := range strings.Split(v, ",") {
+			status = strings.TrimSpace(status)
+			if !appartmentStatuses[status] {
+				return nil, fmt.Errorf("invalid status '%s'", status)
+			}
+			statuses = append(statuses, status)
+		}
+		query = query.Where("appartments.status IN ?", statuses)
+	}
+
+	floatFilters := []struct {
+		param string
+		cond  string
+	}{
+		{"min_rent", "appartments.monthly_rent >= ?"},
+		{"max_rent", "appartments.monthly_rent <= ?"},
+		{"min_surface", "appartments.surface >= ?"},
+		{"max_surface", "appartments.surface <= ?"},
+	}
+	for _, f := range floatFilters {
+		if v := c.Query(f.param); v != "" {
+			value, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for %s", f.param)
+			}
+			query = query.Where(f.cond, value)
+		}
+	}
+
+	intFilters := []struct {
+		param string
+		cond  string
+	}{
+		{"rooms", "appartments.rooms = ?"},
+		{"bathrooms", "appartments.bathrooms = ?"},
+	}
+	for _, f := range intFilters {
+		if v := c.Query(f.param); v != "" {
+			value, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for %s", f.param)
+			}
+			query = query.Where(f.cond, value)
+		}
+	}
+
+	boolFilters := []struct {
+		param string
+		cond  string
+	}{
+		{"furnished", "appartments.furnished = ?"},
+		{"balcony", "appartments.balcony = ?"},
+	}
+	for _, f := range boolFilters {
+		if v := c.Query(f.param); v != "" {
+			value, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for %s", f.param)
+			}
+			query = query.Where(f.cond, value)
+		}
+	}
+
+	if v := c.Query("manager_uuid"); v != "" {
+		query = query.Where("appartments.manager_uuid = ?", v)
+	}
+
+	if v := c.Query("echeance_after"); v != "" {
+		after, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for echeance_after, expected YYYY-MM-DD")
+		}
+		query = query.Where("appartments.echeance >= ?", after)
+	}
+	if v := c.Query("echeance_before"); v != "" {
+		before, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for echeance_before, expected YYYY-MM-DD")
+		}
+		// Add 24 hours to include the entire day
+		query = query.Where("appartments.echeance < ?", before.Add(24*time.Hour))
+	}
+
+	return query, nil
+}
+
+// appartmentOrder construit la clause ORDER BY à partir du paramètre sort,
+// ex. sort=-monthly_rent,name (préfixe "-" pour un tri décroissant)
+func appartmentOrder(c *fiber.Ctx) (string, error) {
+	sort := c.Query("sort", "")
+	if sort == "" {
+		return "appartments.updated_at DESC", nil
+	}
+
+	var clauses []string
+	for _, field := range strings.Split(sort, ",") {
+		field = strings.TrimSpace(field)
+		direction := "ASC"
+		if strings.HasPrefix(field, "-") {
+			direction = "DESC"
+			field = strings.TrimPrefix(field, "-")
+		}
+
+		column, ok := appartmentSortFields[field]
+		if !ok {
+			return "", fmt.Errorf("cannot sort by '%s'", field)
+		}
+		clauses = append(clauses, column+" "+direction)
+	}
+
+	// Ordre stable entre les pages
+	clauses = append(clauses, "appartments.uuid ASC")
+
+	return strings.Join(clauses, ", "), nil
+}
@@ -0,0 +1,233 @@
+package appartments
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kgermando/appartment-app-api/database"
+	"github.com/kgermando/appartment-app-api/models"
+	"github.com/kgermando/appartment-app-api/utils"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// Colonnes obligatoires du fichier d'import des appartements
+var appartmentImportColumns = []string{"name", "number", "monthly_rent"}
+
+// Import appartments from a CSV or XLSX file (multipart field "file").
+// Columns: name, number, area, surface, rooms, bathrooms, balcony, furnished,
+// monthly_rent, garantie_month, garantie_montant, echeance, status, manager_email.
+// With ?dry_run=true the file is only validated.
+func ImportAppartments(c *fiber.Ctx) error {
+	db := database.DB
+
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run", "false"))
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No file provided - use the 'file' field",
+			"data":    nil,
+		})
+	}
+
+	content, err := utils.ReadSpreadsheet(file)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to read file",
+			"error":   err.Error(),
+		})
+	}
+
+	rows := utils.SpreadsheetRows(content)
+	if len(rows) == 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "The file contains no data row",
+			"data":    nil,
+		})
+	}
+	for _, column := range appartmentImportColumns {
+		if !rows[0].HasColumn(column) {
+			return c.Status(400).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Missing required column '" + column + "'",
+				"data":    appartmentImportColumns,
+			})
+		}
+	}
+
+	// Résoudre les gestionnaires par email et les appartements déjà existants en une fois,
+	// sans tenir compte de la casse comme la clé des doublons
+	var emails, names []string
+	for _, row := range rows {
+		if email := row.Get("manager_email"); email != "" {
+			emails = append(emails, strings.ToLower(email))
+		}
+		names = append(names, strings.ToLower(row.Get("name")))
+	}
+
+	var managers []models.User
+	if err := db.Where("LOWER(email) IN ?", append(emails, "")).Find(&managers).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch managers",
+			"error":   err.Error(),
+		})
+	}
+	managerByEmail := make(map[string]string, len(managers))
+	for _, manager := range managers {
+		managerByEmail[strings.ToLower(manager.Email)] = manager.UUID
+	}
+
+	var existing []models.Appartment
+	if err := db.Select("name", "number").Where("LOWER(name) IN ?", names).Find(&existing).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch Appartments",
+			"error":   err.Error(),
+		})
+	}
+	seen := make(map[string]bool, len(existing))
+	for _, apt := range existing {
+		seen[appartmentKey(apt.Name, apt.Number)] = true
+	}
+
+	report := models.ImportReport{TotalRows: len(rows), DryRun: dryRun, Errors: []models.ImportRowError{}}
+	var appartments []models.Appartment
+
+	for _, row := range rows {
+		var rowErrors []models.ImportRowError
+		fail := func(field, message string) {
+			rowErrors = append(rowErrors, models.ImportRowError{Row: row.Number, Field: field, Message: message})
+		}
+
+		apt := models.Appartment{
+			UUID:   utils.GenerateUUID(),
+			Name:   row.Get("name"),
+			Number: row.Get("number"),
+			Area:   row.Get("area"),
+			Status: strings.ToLower(row.Get("status")),
+		}
+
+		if apt.Name == "" {
+			fail("name", "name is required")
+		}
+		if apt.Number == "" {
+			fail("number", "number is required")
+		}
+		if apt.Name != "" && apt.Number != "" {
+			key := appartmentKey(apt.Name, apt.Number)
+			if seen[key] {
+				fail("number", "appartment "+apt.Name+" "+apt.Number+" already exists")
+			}
+			seen[key] = true
+		}
+
+		var err error
+		if surface, err := utils.ParseMoney(row.Get("surface")); err != nil {
+			fail("surface", err.Error())
+		} else if surface.IsNegative() {
+			fail("surface", "surface must be a positive number")
+		} else {
+			apt.Surface = surface.Float64()
+		}
+		if v := row.Get("rooms"); v != "" {
+			if apt.Rooms, err = strconv.Atoi(v); err != nil || apt.Rooms < 0 {
+				fail("rooms", "rooms must be a positive integer")
+			}
+		} else {
+			apt.Rooms = 1
+		}
+		if v := row.Get("bathrooms"); v != "" {
+			if apt.Bathrooms, err = strconv.Atoi(v); err != nil || apt.Bathrooms < 0 {
+				fail("bathrooms", "bathrooms must be a positive integer")
+			}
+		} else {
+			apt.Bathrooms = 1
+		}
+		if apt.Balcony, err = utils.ParseYesNo(row.Get("balcony")); err != nil {
+			fail("balcony", err.Error())
+		}
+		if apt.Furnished, err = utils.ParseYesNo(row.Get("furnished")); err != nil {
+			fail("furnished", err.Error())
+		}
+		if apt.MonthlyRent, err = utils.ParseMoney(row.Get("monthly_rent")); err != nil || !apt.MonthlyRent.IsPositive() {
+			fail("monthly_rent", "monthly_rent must be greater than 0")
+		}
+		if v := row.Get("garantie_month"); v != "" {
+			if months, err := utils.ParseMoney(v); err != nil {
+				fail("garantie_month", err.Error())
+			} else if months.IsNegative() {
+				fail("garantie_month", "garantie_month must be a positive number")
+			} else {
+				apt.GarantieMonth = months.Float64()
+			}
+		} else {
+			apt.GarantieMonth = 2
+		}
+		if v := row.Get("garantie_montant"); v != "" {
+			if apt.Garantie, err = utils.ParseMoney(v); err != nil || apt.Garantie.IsNegative() {
+				fail("garantie_montant", "garantie_montant must be a positive number")
+			}
+		} else {
+			apt.Garantie = apt.MonthlyRent.Mul(decimal.NewFromFloat(apt.GarantieMonth))
+		}
+		if v := row.Get("echeance"); v != "" {
+			if apt.Echeance, err = utils.ParseDate(v); err != nil {
+				fail("echeance", err.Error())
+			}
+		}
+
+		if apt.Status == "" {
+			apt.Status = "available"
+		} else if !appartmentStatuses[apt.Status] {
+			fail("status", "status must be one of available, occupied, maintenance, unavailable")
+		}
+
+		if email := strings.ToLower(row.Get("manager_email")); email != "" {
+			managerUUID, ok := managerByEmail[email]
+			if !ok {
+				fail("manager_email", "no user found with email "+email)
+			}
+			apt.ManagerUUID = managerUUID
+		}
+
+		if len(rowErrors) > 0 {
+			report.InvalidRows++
+			report.Errors = append(report.Errors, rowErrors...)
+			continue
+		}
+
+		report.ValidRows++
+		appartments = append(appartments, apt)
+	}
+
+	if !dryRun && len(appartments) > 0 {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return tx.Omit("Manager", "Caisses", "Photos").CreateInBatches(&appartments, 100).Error
+		})
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Failed to import Appartments",
+				"error":   err.Error(),
+			})
+		}
+		report.Imported = len(appartments)
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Appartments import processed",
+		"data":    report,
+	})
+}
+
+// appartmentKey identifie un appartement par son nom et son numéro
+func appartmentKey(name, number string) string {
+	return strings.ToLower(strings.TrimSpace(name)) + "|" + strings.ToLower(strings.TrimSpace(number))
+}
@@ -0,0 +1,415 @@
+package appartments
+
+import (
+	"bytes"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"path"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kgermando/appartment-app-api/database"
+	"github.com/kgermando/appartment-app-api/models"
+	"github.com/kgermando/appartment-app-api/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	maxPhotoSize       = 10 * 1024 * 1024 // 10 Mo par photo
+	maxPhotoPixels     = 40_000_000       // 40 mégapixels : un PNG de quelques Ko peut annoncer des dimensions énormes
+	thumbnailMaxWidth  = 400
+	thumbnailMaxHeight = 300
+	thumbnailQuality   = 80
+)
+
+var allowedPhotoTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// attachCoverThumbnails renseigne CoverThumbnailURL pour une liste d'appartements en une seule requête
+func attachCoverThumbnails(db *gorm.DB, appartments []models.Appartment) error {
+	if len(appartments) == 0 {
+		return nil
+	}
+
+	uuids := make([]string, 0, len(appartments))
+	for _, apt := range appartments {
+		uuids = append(uuids, apt.UUID)
+	}
+
+	var covers []models.AppartmentPhoto
+	if err := db.Where("appartment_uuid IN ? AND is_cover = ?", uuids, true).Find(&covers).Error; err != nil {
+		return err
+	}
+
+	coverByAppartment := make(map[string]string, len(covers))
+	for _, cover := range covers {
+		coverByAppartment[cover.AppartmentUUID] = cover.ThumbnailURL
+	}
+
+	for i := range appartments {
+		appartments[i].CoverThumbnailURL = coverByAppartment[appartments[i].UUID]
+	}
+
+	return nil
+}
+
+// Get all photos of an appartment
+func GetAppartmentPhotos(c *fiber.Ctx) error {
+	uuid := c.Params("uuid")
+	db := database.DB
+
+	var photos []models.AppartmentPhoto
+	db.Where("appartment_uuid = ?", uuid).Order("position ASC").Find(&photos)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "All appartment photos",
+		"data":    photos,
+	})
+}
+
+// Upload one or more photos (multipart field "photos")
+func UploadAppartmentPhotos(c *fiber.Ctx) error {
+	uuid := c.Params("uuid")
+	db := database.DB
+
+	var appartment models.Appartment
+	if err := db.Where("uuid = ?", uuid).First(&appartment).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Appartment not found",
+			"data":    nil,
+		})
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to parse multipart form",
+			"error":   err.Error(),
+		})
+	}
+
+	files := form.File["photos"]
+	if len(files) == 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No photo provided - use the 'photos' field",
+			"data":    nil,
+		})
+	}
+
+	// Position de départ et présence d'une couverture
+	var lastPosition int
+	db.Model(&models.AppartmentPhoto{}).
+		Where("appartment_uuid = ?", uuid).
+		Select("COALESCE(MAX(position), 0)").Row().Scan(&lastPosition)
+
+	var coverCount int64
+	db.Model(&models.AppartmentPhoto{}).
+		Where("appartment_uuid = ? AND is_cover = ?", uuid, true).
+		Count(&coverCount)
+
+	var photos []models.AppartmentPhoto
+	var written []string
+
+	cleanup := func() {
+		for _, p := range written {
+			utils.RemoveUpload(p)
+		}
+	}
+
+	for i, file := range files {
+		if file.Size > maxPhotoSize {
+			cleanup()
+			return c.Status(400).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Photo too large (max 10 MB): " + file.Filename,
+				"data":    nil,
+			})
+		}
+
+		f, err := file.Open()
+		if err != nil {
+			cleanup()
+			return c.Status(400).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Failed to read photo: " + file.Filename,
+				"error":   err.Error(),
+			})
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			cleanup()
+			return c.Status(400).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Failed to read photo: " + file.Filename,
+				"error":   err.Error(),
+			})
+		}
+
+		contentType := http.DetectContentType(data)
+		ext, ok := allowedPhotoTypes[contentType]
+		if !ok {
+			cleanup()
+			return c.Status(400).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Unsupported image type for " + file.Filename + " - allowed: JPEG, PNG, GIF",
+				"data":    nil,
+			})
+		}
+
+		// Dimensions lues dans l'en-tête avant de décoder : le décodage alloue tous les pixels
+		config, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			cleanup()
+			return c.Status(400).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid image: " + file.Filename,
+				"error":   err.Error(),
+			})
+		}
+		if config.Width <= 0 || config.Height <= 0 || int64(config.Width)*int64(config.Height) > maxPhotoPixels {
+			cleanup()
+			return c.Status(400).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Photo dimensions too large (max 40 megapixels): " + file.Filename,
+				"data":    nil,
+			})
+		}
+
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			cleanup()
+			return c.Status(400).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid image: " + file.Filename,
+				"error":   err.Error(),
+			})
+		}
+
+		thumbnail, err := utils.EncodeJPEG(utils.Thumbnail(img, thumbnailMaxWidth, thumbnailMaxHeight), thumbnailQuality)
+		if err != nil {
+			cleanup()
+			return c.Status(500).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Failed to generate thumbnail",
+				"error":   err.Error(),
+			})
+		}
+
+		photoUUID := utils.GenerateUUID()
+		photoPath := path.Join("appartments", uuid, photoUUID+ext)
+		thumbnailPath := path.Join("appartments", uuid, photoUUID+"_thumb.jpg")
+
+		if err := utils.SaveUpload(photoPath, data); err != nil {
+			cleanup()
+			return c.Status(500).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Failed to store photo",
+				"error":   err.Error(),
+			})
+		}
+		written = append(written, photoPath)
+
+		if err := utils.SaveUpload(thumbnailPath, thumbnail); err != nil {
+			cleanup()
+			return c.Status(500).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Failed to store thumbnail",
+				"error":   err.Error(),
+			})
+		}
+		written = append(written, thumbnailPath)
+
+		photos = append(photos, models.AppartmentPhoto{
+			UUID:           photoUUID,
+			AppartmentUUID: uuid,
+			FileName:       file.Filename,
+			ContentType:    contentType,
+			Size:           int64(len(data)),
+			Width:          img.Bounds().Dx(),
+			Height:         img.Bounds().Dy(),
+			Path:           photoPath,
+			ThumbnailPath:  thumbnailPath,
+			URL:            utils.UploadURL(photoPath),
+			ThumbnailURL:   utils.UploadURL(thumbnailPath),
+			Position:       lastPosition + i + 1,
+			IsCover:        coverCount == 0 && i == 0, // La première photo devient la couverture
+		})
+	}
+
+	if err := db.Create(&photos).Error; err != nil {
+		cleanup()
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to save photos",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Photos uploaded success",
+		"data":    photos,
+	})
+}
+
+// Set the cover photo of an appartment
+func SetAppartmentPhotoCover(c *fiber.Ctx) error {
+	uuid := c.Params("uuid")
+	photoUUID := c.Params("photo_uuid")
+	db := database.DB
+
+	var photo models.AppartmentPhoto
+	if err := db.Where("uuid = ? AND appartment_uuid = ?", photoUUID, uuid).First(&photo).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No Photo found",
+			"data":    nil,
+		})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.AppartmentPhoto{}).
+			Where("appartment_uuid = ? AND uuid <> ?", uuid, photoUUID).
+			Update("is_cover", false).Error; err != nil {
+			return err
+		}
+		return tx.Model(&photo).Update("is_cover", true).Error
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to set cover photo",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Cover photo updated success",
+		"data":    photo,
+	})
+}
+
+// Reorder the photos of an appartment
+func ReorderAppartmentPhotos(c *fiber.Ctx) error {
+	uuid := c.Params("uuid")
+	db := database.DB
+
+	type ReorderInput struct {
+		PhotoUUIDs []string `json:"photo_uuids"`
+	}
+
+	var input ReorderInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Review your input",
+			"data":    nil,
+		})
+	}
+
+	// La liste doit contenir toutes les photos de l'appartement, chacune une seule fois,
+	// pour que les positions restent uniques
+	var existing []string
+	db.Model(&models.AppartmentPhoto{}).Where("appartment_uuid = ?", uuid).Pluck("uuid", &existing)
+	listed := make(map[string]bool, len(input.PhotoUUIDs))
+	for _, photoUUID := range input.PhotoUUIDs {
+		listed[photoUUID] = true
+	}
+	complete := len(input.PhotoUUIDs) > 0 && len(listed) == len(input.PhotoUUIDs) && len(listed) == len(existing)
+	for _, photoUUID := range existing {
+		complete = complete && listed[photoUUID]
+	}
+	if !complete {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "photo_uuids must list every photo of this appartment exactly once",
+			"data":    nil,
+		})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for i, photoUUID := range input.PhotoUUIDs {
+			if err := tx.Model(&models.AppartmentPhoto{}).
+				Where("uuid = ? AND appartment_uuid = ?", photoUUID, uuid).
+				Update("position", i+1).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to reorder photos",
+			"error":   err.Error(),
+		})
+	}
+
+	var photos []models.AppartmentPhoto
+	db.Where("appartment_uuid = ?", uuid).Order("position ASC").Find(&photos)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Photos reordered success",
+		"data":    photos,
+	})
+}
+
+// Delete a photo
+func DeleteAppartmentPhoto(c *fiber.Ctx) error {
+	uuid := c.Params("uuid")
+	photoUUID := c.Params("photo_uuid")
+	db := database.DB
+
+	var photo models.AppartmentPhoto
+	if err := db.Where("uuid = ? AND appartment_uuid = ?", photoUUID, uuid).First(&photo).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No Photo found",
+			"data":    nil,
+		})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Delete(&photo).Error; err != nil {
+			return err
+		}
+
+		// Promouvoir la photo suivante si la couverture est supprimée
+		if photo.IsCover {
+			var next models.AppartmentPhoto
+			if err := tx.Where("appartment_uuid = ?", uuid).Order("position ASC").First(&next).Error; err == nil {
+				return tx.Model(&next).Update("is_cover", true).Error
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to delete photo",
+			"error":   err.Error(),
+		})
+	}
+
+	utils.RemoveUpload(photo.Path)
+	utils.RemoveUpload(photo.ThumbnailPath)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Photo deleted success",
+		"data":    nil,
+	})
+}
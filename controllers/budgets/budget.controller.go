@@ -0,0 +1,295 @@
+package budgets
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kgermando/appartment-app-api/database"
+	"github.com/kgermando/appartment-app-api/models"
+	"github.com/kgermando/appartment-app-api/utils"
+	"gorm.io/gorm/clause"
+)
+
+// budgetReportLine compare le budget d'une catégorie et d'un immeuble au réalisé
+type budgetReportLine struct {
+	CategoryUUID string       `json:"category_uuid"`
+	CategoryName string       `json:"category_name"`
+	Type         string       `json:"type"`
+	Building     string       `json:"building"`
+	BudgetUSD    utils.Amount `json:"budget_usd"`
+	BudgetCDF    utils.Amount `json:"budget_cdf"`
+	VarianceUSD  utils.Amount `json:"variance_usd"` // Budget - réalisé
+	VarianceCDF  utils.Amount `json:"variance_cdf"`
+	UsagePercent float64      `json:"usage_percent"` // Réalisé / budget en USD
+
+	Actual map[string]utils.Amount `gorm:"-" json:"actual"` // Réalisé par devise
+}
+
+// Get the budgets, filters: year, month, building, category_uuid
+func GetBudgets(c *fiber.Ctx) error {
+	db := database.DB
+
+	query := db.Preload("Category")
+	if v := c.Query("year"); v != "" {
+		query = query.Where("year = ?", v)
+	}
+	if v := c.Query("month"); v != "" {
+		query = query.Where("month = ?", v)
+	}
+	if v := c.Query("building"); v != "" {
+		query = query.Where("building = ?", v)
+	}
+	if v := c.Query("category_uuid"); v != "" {
+		query = query.Where("category_uuid = ?", v)
+	}
+
+	var budgets []models.Budget
+	if err := query.Order("year DESC, month DESC, building").Find(&budgets).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch budgets",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "All budgets",
+		"data":    budgets,
+	})
+}
+
+// Create or replace the budget of a category, building and month
+func SaveBudget(c *fiber.Ctx) error {
+	db := database.DB
+
+	p := &models.Budget{}
+	if err := c.BodyParser(&p); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid input",
+			"data":    nil,
+		})
+	}
+
+	if p.CategoryUUID == "" || p.Building == "" || p.Year < 2000 || p.Month < 1 || p.Month > 12 {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Form not complete - category_uuid, building, year and month (1-12) are required",
+			"data":    nil,
+		})
+	}
+	if p.AmountUSD.IsNegative() || p.AmountCDF.IsNegative() {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Budget amounts cannot be negative",
+			"data":    nil,
+		})
+	}
+
+	var category models.Category
+	db.Where("uuid = ?", p.CategoryUUID).First(&category)
+	if category.UUID == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Category not found",
+			"data":    nil,
+		})
+	}
+
+	budget := &models.Budget{
+		UUID:         utils.GenerateUUID(),
+		CategoryUUID: p.CategoryUUID,
+		Building:     p.Building,
+		Year:         p.Year,
+		Month:        p.Month,
+		AmountUSD:    p.AmountUSD,
+		AmountCDF:    p.AmountCDF,
+		Notes:        p.Notes,
+	}
+
+	err := db.Omit("Category").Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "category_uuid"}, {Name: "building"}, {Name: "year"}, {Name: "month"}},
+		DoUpdates: clause.AssignmentColumns([]string{"amount_usd", "amount_cdf", "notes", "updated_at"}),
+	}).Create(budget).Error
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to save budget",
+			"error":   err.Error(),
+		})
+	}
+
+	db.Preload("Category").
+		Where("category_uuid = ? AND building = ? AND year = ? AND month = ?", budget.CategoryUUID, budget.Building, budget.Year, budget.Month).
+		First(budget)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Budget saved success",
+		"data":    budget,
+	})
+}
+
+// Delete a budget
+func DeleteBudget(c *fiber.Ctx) error {
+	db := database.DB
+
+	var budget models.Budget
+	db.Where("uuid = ?", c.Params("uuid")).First(&budget)
+	if budget.UUID == "" {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No budget found",
+			"data":    nil,
+		})
+	}
+
+	db.Delete(&budget)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Budget deleted success",
+		"data":    nil,
+	})
+}
+
+// Budget versus actual for a year (?year=, required) or a month (?month=),
+// optionally for one building. Actuals include the sub-categories.
+func GetBudgetReport(c *fiber.Ctx) error {
+	db := database.DB
+
+	year, err := strconv.Atoi(c.Query("year"))
+	if err != nil || year < 2000 {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "A valid year is required",
+			"data":    nil,
+		})
+	}
+	month, _ := strconv.Atoi(c.Query("month", "0"))
+	if month < 0 || month > 12 {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid month, expected 1 to 12",
+			"data":    nil,
+		})
+	}
+	building := c.Query("building")
+
+	budgetQuery := db.Model(&models.Budget{}).
+		Select("budgets.category_uuid, categories.name AS category_name, categories.type, budgets.building, "+
+			"SUM(budgets.amount_usd) AS budget_usd, SUM(budgets.amount_cdf) AS budget_cdf").
+		Joins("JOIN categories ON categories.uuid = budgets.category_uuid").
+		Where("budgets.year = ?", year).
+		Group("budgets.category_uuid, categories.name, categories.type, budgets.building").
+		Order("categories.type DESC, categories.name, budgets.building")
+	if month > 0 {
+		budgetQuery = budgetQuery.Where("budgets.month = ?", month)
+	}
+	if building != "" {
+		budgetQuery = budgetQuery.Where("budgets.building = ?", building)
+	}
+
+	var lines []budgetReportLine
+	if err := budgetQuery.Scan(&lines).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch budgets",
+			"error":   err.Error(),
+		})
+	}
+
+	start, end := models.PeriodBounds(year, 1)
+	end = start.AddDate(1, 0, 0)
+	if month > 0 {
+		start, end = models.PeriodBounds(year, month)
+	}
+
+	// Réalisé par catégorie, par immeuble et par devise sur la période
+	actualQuery := db.Model(&models.Caisse{}).Scopes(models.PostedCaisses).
+		Select("caisses.category_uuid, appartments.name AS building, caisse_lines.currency, "+
+			"COALESCE(SUM(caisse_lines.amount), 0) AS amount").
+		Joins("JOIN appartments ON appartments.uuid = caisses.appartment_uuid").
+		Joins("JOIN caisse_lines ON caisse_lines.caisse_uuid = caisses.uuid").
+		Where("caisses.transaction_date >= ? AND caisses.transaction_date < ?", start, end).
+		Group("caisses.category_uuid, appartments.name, caisse_lines.currency")
+	if building != "" {
+		actualQuery = actualQuery.Where("appartments.name = ?", building)
+	}
+
+	var actuals []struct {
+		CategoryUUID string
+		Building     string
+		Currency     string
+		Amount       utils.Amount
+	}
+	if err := actualQuery.Scan(&actuals).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to compute actuals",
+			"error":   err.Error(),
+		})
+	}
+
+	// Parent de chaque catégorie pour reporter le réalisé des sous-catégories
+	var categories []models.Category
+	db.Unscoped().Select("uuid", "parent_uuid").Find(&categories)
+	parents := make(map[string]string, len(categories))
+	for _, category := range categories {
+		if category.ParentUUID != nil {
+			parents[category.UUID] = *category.ParentUUID
+		}
+	}
+
+	totals := budgetReportLine{Actual: make(map[string]utils.Amount)}
+	for i := range lines {
+		line := &lines[i]
+		line.Actual = make(map[string]utils.Amount)
+		for _, actual := range actuals {
+			if actual.Building != line.Building || !isUnder(parents, actual.CategoryUUID, line.CategoryUUID) {
+				continue
+			}
+			line.Actual[actual.Currency] = line.Actual[actual.Currency].Add(actual.Amount)
+		}
+		line.VarianceUSD = line.BudgetUSD.Sub(line.Actual[utils.CurrencyUSD])
+		line.VarianceCDF = line.BudgetCDF.Sub(line.Actual[utils.CurrencyCDF])
+		line.UsagePercent = line.Actual[utils.CurrencyUSD].Percent(line.BudgetUSD)
+
+		totals.BudgetUSD = totals.BudgetUSD.Add(line.BudgetUSD)
+		totals.BudgetCDF = totals.BudgetCDF.Add(line.BudgetCDF)
+		for currency, amount := range line.Actual {
+			totals.Actual[currency] = totals.Actual[currency].Add(amount)
+		}
+	}
+	totals.VarianceUSD = totals.BudgetUSD.Sub(totals.Actual[utils.CurrencyUSD])
+	totals.VarianceCDF = totals.BudgetCDF.Sub(totals.Actual[utils.CurrencyCDF])
+	totals.UsagePercent = totals.Actual[utils.CurrencyUSD].Percent(totals.BudgetUSD)
+
+	if lines == nil {
+		lines = []budgetReportLine{}
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Budget report",
+		"data": fiber.Map{
+			"year":     year,
+			"month":    month,
+			"building": building,
+			"lines":    lines,
+			"totals":   totals,
+		},
+	})
+}
+
+// isUnder indique si la catégorie est l'ancêtre donné ou une de ses sous-catégories
+func isUnder(parents map[string]string, categoryUUID, ancestorUUID string) bool {
+	for depth := 0; categoryUUID != "" && depth < 20; depth++ {
+		if categoryUUID == ancestorUUID {
+			return true
+		}
+		categoryUUID = parents[categoryUUID]
+	}
+	return false
+}
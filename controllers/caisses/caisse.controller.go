@@ -1,83 +1,43 @@
 package caisses
 
 import (
-	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/kgermando/appartment-app-api/database"
 	"github.com/kgermando/appartment-app-api/models"
 	"github.com/kgermando/appartment-app-api/utils"
+	"gorm.io/gorm"
 )
 
 // Paginate by SuperAdmin
 func GetPaginatedCaissesSuperAdmin(c *fiber.Ctx) error {
 	db := database.DB 
 
-	// Parse query parameters for pagination
-	page, err := strconv.Atoi(c.Query("page", "1"))
-	if err != nil || page <= 0 {
-		page = 1
-	}
-	limit, err := strconv.Atoi(c.Query("limit", "15"))
-	if err != nil || limit <= 0 {
-		limit = 15
+	// Parse pagination, search and date range filters
+	filter, err := utils.ParseQueryFilter(c, 15)
+	if err != nil {
+		return caisseError(c, err, "Failed to fetch Caisses")
 	}
-	offset := (page - 1) * limit
-
-	// Parse search query
-	search := c.Query("search", "")
-
-	// Parse date range filters
-	startDate := c.Query("start_date", "")
-	endDate := c.Query("end_date", "")
 
 	var caisses []models.Caisse
 	var totalRecords int64
 
-	// Build query with date filter
-	query := db.Model(&models.Caisse{}). 
-		Where("type ILIKE ? OR signature ILIKE ? OR motif ILIKE ?", "%"+search+"%", "%"+search+"%", "%"+search+"%")
-
-	// Add date range filter if provided
-	if startDate != "" {
-		if parsedStartDate, err := time.Parse("2006-01-02", startDate); err == nil {
-			query = query.Where("created_at >= ?", parsedStartDate)
-		}
-	}
-	if endDate != "" {
-		if parsedEndDate, err := time.Parse("2006-01-02", endDate); err == nil {
-			// Add 24 hours to include the entire end date
-			endDateTime := parsedEndDate.Add(24 * time.Hour)
-			query = query.Where("created_at < ?", endDateTime)
-		}
-	}
+	// Build query with search and date filters
+	query := filter.ApplySearch(db.Model(&models.Caisse{}), "type", "signature", "motif")
+	query = filter.ApplyPeriod(query, "transaction_date")
 
 	// Count total records matching the search and date filters
 	query.Count(&totalRecords)
 
 	// Apply the same filters for fetching data
-	dataQuery := db.
-		Where("type ILIKE ? OR signature ILIKE ? OR motif ILIKE ?", "%"+search+"%", "%"+search+"%", "%"+search+"%")
+	dataQuery := filter.ApplySearch(db, "type", "signature", "motif")
+	dataQuery = filter.ApplyPeriod(dataQuery, "transaction_date")
 
-	// Add date range filter for data query
-	if startDate != "" {
-		if parsedStartDate, err := time.Parse("2006-01-02", startDate); err == nil {
-			dataQuery = dataQuery.Where("created_at >= ?", parsedStartDate)
-		}
-	}
-	if endDate != "" {
-		if parsedEndDate, err := time.Parse("2006-01-02", endDate); err == nil {
-			endDateTime := parsedEndDate.Add(24 * time.Hour)
-			dataQuery = dataQuery.Where("created_at < ?", endDateTime)
-		}
-	}
-
-	err = dataQuery.
-		Offset(offset).
-		Limit(limit).
+	err = filter.Paginate(dataQuery).
 		Order("caisses.updated_at DESC").
 		Preload("Appartment").
+		Preload("Lines").
 		Find(&caisses).Error
 
 	if err != nil {
@@ -88,69 +48,17 @@ func GetPaginatedCaissesSuperAdmin(c *fiber.Ctx) error {
 		})
 	}
 
-	// Calculate total pages
-	totalPages := int((totalRecords + int64(limit) - 1) / int64(limit))
-
-	//  Prepare pagination metadata
-	pagination := map[string]interface{}{
-		"total_records": totalRecords,
-		"total_pages":   totalPages,
-		"current_page":  page,
-		"page_size":     limit,
-	}
-
-	// Calculate totals for Income and Expense in USD and CDF
-	var totalIncomeUSD, totalExpenseUSD, totalIncomeCDF, totalExpenseCDF float64
-
-	// Build query for totals with same filters
-	totalsQuery := db.Model(&models.Caisse{})
-
-	// Add date range filter for totals
-	if startDate != "" {
-		if parsedStartDate, err := time.Parse("2006-01-02", startDate); err == nil {
-			totalsQuery = totalsQuery.Where("created_at >= ?", parsedStartDate)
-		}
-	}
-	if endDate != "" {
-		if parsedEndDate, err := time.Parse("2006-01-02", endDate); err == nil {
-			endDateTime := parsedEndDate.Add(24 * time.Hour)
-			totalsQuery = totalsQuery.Where("created_at < ?", endDateTime)
-		}
-	}
+	// Prepare pagination metadata
+	pagination := filter.Pagination(totalRecords)
 
-	// Calculate total Income USD
-	totalsQuery.Where("type = ?", "Income").Select("COALESCE(SUM(device_usd), 0)").Scan(&totalIncomeUSD)
-
-	// Calculate total Expense USD
-	totalsQuery.Where("type = ?", "Expense").Select("COALESCE(SUM(device_usd), 0)").Scan(&totalExpenseUSD)
-
-	// Reset query for CDF calculations
-	totalsQuery = db.Model(&models.Caisse{})
-
-	if startDate != "" {
-		if parsedStartDate, err := time.Parse("2006-01-02", startDate); err == nil {
-			totalsQuery = totalsQuery.Where("created_at >= ?", parsedStartDate)
-		}
-	}
-	if endDate != "" {
-		if parsedEndDate, err := time.Parse("2006-01-02", endDate); err == nil {
-			endDateTime := parsedEndDate.Add(24 * time.Hour)
-			totalsQuery = totalsQuery.Where("created_at < ?", endDateTime)
-		}
-	}
-
-	// Calculate total Income CDF
-	totalsQuery.Where("type = ?", "Income").Select("COALESCE(SUM(device_cdf), 0)").Scan(&totalIncomeCDF)
-
-	// Calculate total Expense CDF
-	totalsQuery.Where("type = ?", "Expense").Select("COALESCE(SUM(device_cdf), 0)").Scan(&totalExpenseCDF)
-
-	// Prepare totals metadata
-	totals := map[string]interface{}{
-		"total_income_usd":  totalIncomeUSD,
-		"total_expense_usd": totalExpenseUSD,
-		"total_income_cdf":  totalIncomeCDF,
-		"total_expense_cdf": totalExpenseCDF,
+	// Totals for Income and Expense per currency, from the caisse lines
+	totals, err := caisseTotals(db, "", filter)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch Caisses",
+			"error":   err.Error(),
+		})
 	}
 
 	// Return response
@@ -170,71 +78,30 @@ func GetPaginatedCaisses(c *fiber.Ctx) error {
 
 	appartmentUUID := c.Params("appartment_uuid")
 
-	// Parse query parameters for pagination
-	page, err := strconv.Atoi(c.Query("page", "1"))
-	if err != nil || page <= 0 {
-		page = 1
-	}
-	limit, err := strconv.Atoi(c.Query("limit", "15"))
-	if err != nil || limit <= 0 {
-		limit = 15
+	// Parse pagination, search and date range filters
+	filter, err := utils.ParseQueryFilter(c, 15)
+	if err != nil {
+		return caisseError(c, err, "Failed to fetch Caisses")
 	}
-	offset := (page - 1) * limit
-
-	// Parse search query
-	search := c.Query("search", "")
-
-	// Parse date range filters
-	startDate := c.Query("start_date", "")
-	endDate := c.Query("end_date", "")
 
 	var caisses []models.Caisse
 	var totalRecords int64
 
-	// Build query with date filter
-	query := db.Model(&models.Caisse{}).
-		Where("appartment_uuid = ?", appartmentUUID).
-		Where("type ILIKE ? OR signature ILIKE ? OR motif ILIKE ?", "%"+search+"%", "%"+search+"%", "%"+search+"%")
-
-	// Add date range filter if provided
-	if startDate != "" {
-		if parsedStartDate, err := time.Parse("2006-01-02", startDate); err == nil {
-			query = query.Where("created_at >= ?", parsedStartDate)
-		}
-	}
-	if endDate != "" {
-		if parsedEndDate, err := time.Parse("2006-01-02", endDate); err == nil {
-			// Add 24 hours to include the entire end date
-			endDateTime := parsedEndDate.Add(24 * time.Hour)
-			query = query.Where("created_at < ?", endDateTime)
-		}
-	}
+	// Build query with search and date filters
+	query := filter.ApplySearch(db.Model(&models.Caisse{}).Where("appartment_uuid = ?", appartmentUUID), "type", "signature", "motif")
+	query = filter.ApplyPeriod(query, "transaction_date")
 
 	// Count total records matching the search and date filters
 	query.Count(&totalRecords)
 
 	// Apply the same filters for fetching data
-	dataQuery := db.Where("appartment_uuid = ?", appartmentUUID).
-		Where("type ILIKE ? OR signature ILIKE ? OR motif ILIKE ?", "%"+search+"%", "%"+search+"%", "%"+search+"%")
+	dataQuery := filter.ApplySearch(db.Where("appartment_uuid = ?", appartmentUUID), "type", "signature", "motif")
+	dataQuery = filter.ApplyPeriod(dataQuery, "transaction_date")
 
-	// Add date range filter for data query
-	if startDate != "" {
-		if parsedStartDate, err := time.Parse("2006-01-02", startDate); err == nil {
-			dataQuery = dataQuery.Where("created_at >= ?", parsedStartDate)
-		}
-	}
-	if endDate != "" {
-		if parsedEndDate, err := time.Parse("2006-01-02", endDate); err == nil {
-			endDateTime := parsedEndDate.Add(24 * time.Hour)
-			dataQuery = dataQuery.Where("created_at < ?", endDateTime)
-		}
-	}
-
-	err = dataQuery.
-		Offset(offset).
-		Limit(limit).
+	err = filter.Paginate(dataQuery).
 		Order("caisses.updated_at DESC").
 		Preload("Appartment").
+		Preload("Lines").
 		Find(&caisses).Error
 
 	if err != nil {
@@ -245,71 +112,17 @@ func GetPaginatedCaisses(c *fiber.Ctx) error {
 		})
 	}
 
-	// Calculate total pages
-	totalPages := int((totalRecords + int64(limit) - 1) / int64(limit))
-
-	//  Prepare pagination metadata
-	pagination := map[string]interface{}{
-		"total_records": totalRecords,
-		"total_pages":   totalPages,
-		"current_page":  page,
-		"page_size":     limit,
-	}
-
-	// Calculate totals for Income and Expense in USD and CDF
-	var totalIncomeUSD, totalExpenseUSD, totalIncomeCDF, totalExpenseCDF float64
-
-	// Build query for totals with same filters
-	totalsQuery := db.Model(&models.Caisse{}).
-		Where("appartment_uuid = ?", appartmentUUID)
-
-	// Add date range filter for totals
-	if startDate != "" {
-		if parsedStartDate, err := time.Parse("2006-01-02", startDate); err == nil {
-			totalsQuery = totalsQuery.Where("created_at >= ?", parsedStartDate)
-		}
-	}
-	if endDate != "" {
-		if parsedEndDate, err := time.Parse("2006-01-02", endDate); err == nil {
-			endDateTime := parsedEndDate.Add(24 * time.Hour)
-			totalsQuery = totalsQuery.Where("created_at < ?", endDateTime)
-		}
-	}
-
-	// Calculate total Income USD
-	totalsQuery.Where("type = ?", "Income").Select("COALESCE(SUM(device_usd), 0)").Scan(&totalIncomeUSD)
-
-	// Calculate total Expense USD
-	totalsQuery.Where("type = ?", "Expense").Select("COALESCE(SUM(device_usd), 0)").Scan(&totalExpenseUSD)
-
-	// Reset query for CDF calculations
-	totalsQuery = db.Model(&models.Caisse{}).
-		Where("appartment_uuid = ?", appartmentUUID)
-
-	if startDate != "" {
-		if parsedStartDate, err := time.Parse("2006-01-02", startDate); err == nil {
-			totalsQuery = totalsQuery.Where("created_at >= ?", parsedStartDate)
-		}
-	}
-	if endDate != "" {
-		if parsedEndDate, err := time.Parse("2006-01-02", endDate); err == nil {
-			endDateTime := parsedEndDate.Add(24 * time.Hour)
-			totalsQuery = totalsQuery.Where("created_at < ?", endDateTime)
-		}
-	}
-
-	// Calculate total Income CDF
-	totalsQuery.Where("type = ?", "Income").Select("COALESCE(SUM(device_cdf), 0)").Scan(&totalIncomeCDF)
-
-	// Calculate total Expense CDF
-	totalsQuery.Where("type = ?", "Expense").Select("COALESCE(SUM(device_cdf), 0)").Scan(&totalExpenseCDF)
+	// Prepare pagination metadata
+	pagination := filter.Pagination(totalRecords)
 
-	// Prepare totals metadata
-	totals := map[string]interface{}{
-		"total_income_usd":  totalIncomeUSD,
-		"total_expense_usd": totalExpenseUSD,
-		"total_income_cdf":  totalIncomeCDF,
-		"total_expense_cdf": totalExpenseCDF,
+	// Totals for Income and Expense per currency, from the caisse lines
+	totals, err := caisseTotals(db, appartmentUUID, filter)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch Caisses",
+			"error":   err.Error(),
+		})
 	}
 
 	// Return response
@@ -326,7 +139,7 @@ func GetPaginatedCaisses(c *fiber.Ctx) error {
 func GetAllCaisses(c *fiber.Ctx) error {
 	db := database.DB
 	var caisses []models.Caisse
-	db.Preload("Appartment").Find(&caisses)
+	db.Preload("Appartment").Preload("Lines").Find(&caisses)
 	return c.JSON(fiber.Map{
 		"status":  "success",
 		"message": "All caisses",
@@ -339,7 +152,7 @@ func GetAllCaissesByAppartmentUUID(c *fiber.Ctx) error {
 	appartmentUUID := c.Params("appartment_uuid")
 
 	var caisses []models.Caisse
-	db.Where("appartment_uuid = ?", appartmentUUID).Preload("Appartment").Find(&caisses)
+	db.Where("appartment_uuid = ?", appartmentUUID).Preload("Appartment").Preload("Lines").Find(&caisses)
 	return c.JSON(fiber.Map{
 		"status":  "success",
 		"message": "All caisses",
@@ -352,7 +165,7 @@ func GetCaisse(c *fiber.Ctx) error {
 	uuid := c.Params("uuid")
 	db := database.DB
 	var caisse models.Caisse
-	db.Where("uuid = ?", uuid).Preload("Appartment").First(&caisse)
+	db.Where("uuid = ?", uuid).Preload("Appartment").Preload("Lines").First(&caisse)
 	if caisse.UUID == "" {
 		return c.Status(404).JSON(
 			fiber.Map{
@@ -373,7 +186,12 @@ func GetCaisse(c *fiber.Ctx) error {
 
 // Create data
 func CreateCaisse(c *fiber.Ctx) error {
-	p := &models.Caisse{}
+	// Les dates sont lues en texte (YYYY-MM-DD accepté) puis validées
+	p := &struct {
+		models.Caisse
+		TransactionDate string `json:"transaction_date"`
+		ValueDate       string `json:"value_date"`
+	}{}
 
 	if err := c.BodyParser(&p); err != nil {
 		return err
@@ -400,18 +218,86 @@ func CreateCaisse(c *fiber.Ctx) error {
 		)
 	}
 
+	if p.CategoryUUID == "" {
+		return c.Status(400).JSON(
+			fiber.Map{
+				"status":  "error",
+				"message": "Category is required",
+				"data":    nil,
+			},
+		)
+	}
+
+	// Comptabilisée par défaut, "draft" pour un brouillon à valider plus tard
+	if p.Status != "" && p.Status != models.CaisseDraft && p.Status != models.CaissePosted {
+		return c.Status(400).JSON(
+			fiber.Map{
+				"status":  "error",
+				"message": "Status must be either 'draft' or 'posted'",
+				"data":    nil,
+			},
+		)
+	}
+
+	transactionDate, valueDate, dateErr := caisseDates(p.TransactionDate, p.ValueDate)
+	if dateErr != nil {
+		return c.Status(dateErr.Code).JSON(
+			fiber.Map{
+				"status":  "error",
+				"message": dateErr.Message,
+				"data":    nil,
+			},
+		)
+	}
+
 	caisse := &models.Caisse{
-		AppartmentUUID: p.AppartmentUUID,
-		Type:           p.Type,
-		DeviceCDF:      p.DeviceCDF,
-		DeviceUSD:      p.DeviceUSD,
-		Motif:          p.Motif,
-		Signature:      p.Signature,
+		AppartmentUUID:  p.AppartmentUUID,
+		AccountUUID:     p.AccountUUID,
+		CategoryUUID:    p.CategoryUUID,
+		Type:            p.Type,
+		DeviceCDF:       p.DeviceCDF,
+		DeviceUSD:       p.DeviceUSD,
+		Motif:           p.Motif,
+		Signature:       p.Signature,
+		TransactionDate: transactionDate,
+		ValueDate:       valueDate,
+		Status:          models.CaisseDraft,
 	}
 
 	caisse.UUID = utils.GenerateUUID()
 
-	database.DB.Create(caisse)
+	// Montants par devise : les lignes saisies, ou à défaut device_usd et device_cdf
+	if err := caisse.SetLines(p.Lines); err != nil {
+		return caisseError(c, err, "Failed to create Caisse")
+	}
+
+	// Numéro de pièce et chaînage attribués dans la même transaction que la création
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := resolveCaisseAccount(tx, caisse); err != nil {
+			return err
+		}
+		if err := checkCaisseCategory(tx, caisse); err != nil {
+			return err
+		}
+		if p.Status != models.CaisseDraft {
+			if err := models.EnsurePeriodOpen(tx, caisse.TransactionDate); err != nil {
+				return err
+			}
+			if err := models.AssignVoucher(tx, caisse); err != nil {
+				return err
+			}
+		}
+		if err := tx.Omit("Appartment", "Category", "Lines").Create(caisse).Error; err != nil {
+			return err
+		}
+		if err := models.SaveCaisseLines(tx, caisse); err != nil {
+			return err
+		}
+		return models.RefreshDailySummaries(tx, caisse.AppartmentUUID, caisse.TransactionDate)
+	})
+	if err != nil {
+		return caisseError(c, err, "Failed to create Caisse")
+	}
 
 	return c.JSON(
 		fiber.Map{
@@ -428,12 +314,17 @@ func UpdateCaisse(c *fiber.Ctx) error {
 	db := database.DB
 
 	type UpdateDataInput struct {
-		AppartmentUUID string  `json:"appartment_uuid"`
-		Type           string  `json:"type"`
-		DeviceCDF      float64 `json:"device_cdf"`
-		DeviceUSD      float64 `json:"device_usd"`
-		Motif          string  `json:"motif"`
-		Signature      string  `json:"signature"`
+		AppartmentUUID  string              `json:"appartment_uuid"`
+		AccountUUID     string              `json:"account_uuid"`
+		CategoryUUID    string              `json:"category_uuid"`
+		Type            string              `json:"type"`
+		DeviceCDF       utils.Amount        `json:"device_cdf"`
+		DeviceUSD       utils.Amount        `json:"device_usd"`
+		Motif           string              `json:"motif"`
+		Signature       string              `json:"signature"`
+		TransactionDate string              `json:"transaction_date"`
+		ValueDate       string              `json:"value_date"`
+		Lines           []models.CaisseLine `json:"lines"`
 	}
 
 	var updateData UpdateDataInput
@@ -461,15 +352,131 @@ func UpdateCaisse(c *fiber.Ctx) error {
 
 	caisse := new(models.Caisse)
 
-	db.Where("uuid = ?", uuid).First(&caisse)
-	caisse.AppartmentUUID = updateData.AppartmentUUID
+	db.Where("uuid = ?", uuid).Preload("Lines").First(&caisse)
+	if caisse.UUID == "" {
+		return c.Status(404).JSON(
+			fiber.Map{
+				"status":  "error",
+				"message": "No Caisse found",
+				"data":    nil,
+			},
+		)
+	}
+
+	// Un brouillon peut changer d'appartement et de compte, une pièce reste dans la caisse où elle a été numérotée
+	if caisse.Status == models.CaisseDraft && updateData.AppartmentUUID != "" && updateData.AppartmentUUID != caisse.AppartmentUUID {
+		caisse.AppartmentUUID = updateData.AppartmentUUID
+		caisse.AccountUUID = ""
+	}
+	if caisse.Status == models.CaisseDraft && updateData.AccountUUID != "" {
+		caisse.AccountUUID = updateData.AccountUUID
+	}
+	if updateData.AccountUUID != "" && updateData.AccountUUID != caisse.AccountUUID {
+		return c.Status(400).JSON(
+			fiber.Map{
+				"status":  "error",
+				"message": "A voucher cannot be moved to another account",
+				"data":    nil,
+			},
+		)
+	}
+	if updateData.AppartmentUUID != "" && updateData.AppartmentUUID != caisse.AppartmentUUID {
+		return c.Status(400).JSON(
+			fiber.Map{
+				"status":  "error",
+				"message": "A voucher cannot be moved to another appartment",
+				"data":    nil,
+			},
+		)
+	}
+
+	// Une entrée comptabilisée n'est plus modifiable après le délai de correction,
+	// elle doit être contre-passée
+	if err := checkCaisseEditable(caisse); err != nil {
+		return c.Status(409).JSON(
+			fiber.Map{
+				"status":  "error",
+				"message": err.Message,
+				"data":    nil,
+			},
+		)
+	}
+
 	caisse.Type = updateData.Type
+	if updateData.CategoryUUID != "" {
+		caisse.CategoryUUID = updateData.CategoryUUID
+	}
+	// Une entrée qui change de date doit rester dans une période ouverte, l'ancienne comme la nouvelle
+	previousDate := caisse.TransactionDate
+	if updateData.TransactionDate != "" || updateData.ValueDate != "" {
+		transactionDate := updateData.TransactionDate
+		if transactionDate == "" {
+			transactionDate = caisse.TransactionDate.Format(time.RFC3339)
+		}
+		date, valueDate, dateErr := caisseDates(transactionDate, updateData.ValueDate)
+		if dateErr != nil {
+			return c.Status(dateErr.Code).JSON(
+				fiber.Map{
+					"status":  "error",
+					"message": dateErr.Message,
+					"data":    nil,
+				},
+			)
+		}
+		caisse.TransactionDate = date
+		caisse.ValueDate = valueDate
+	}
+
 	caisse.DeviceCDF = updateData.DeviceCDF
 	caisse.DeviceUSD = updateData.DeviceUSD
+	if err := caisse.SetLines(updateData.Lines); err != nil {
+		return caisseError(c, err, "Failed to update Caisse")
+	}
 	caisse.Motif = updateData.Motif
 	caisse.Signature = updateData.Signature
 
-	db.Save(&caisse)
+	// Seule la dernière pièce de la chaîne peut être corrigée, son empreinte est recalculée
+	errLocked := fiber.NewError(409, "Only the last voucher of the cash book can be modified, reverse this entry instead")
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := resolveCaisseAccount(tx, caisse); err != nil {
+			return err
+		}
+		if err := checkCaisseCategory(tx, caisse); err != nil {
+			return err
+		}
+		if caisse.Status == models.CaissePosted {
+			if err := models.EnsurePeriodOpen(tx, previousDate); err != nil {
+				return err
+			}
+			if err := models.EnsurePeriodOpen(tx, caisse.TransactionDate); err != nil {
+				return err
+			}
+			last, err := models.IsLastVoucher(tx, caisse)
+			if err != nil {
+				return err
+			}
+			if !last {
+				return errLocked
+			}
+			// Les nouvelles lignes prennent le taux en vigueur, l'entrée garde le sien
+			if err := models.ApplyExchangeRate(tx, caisse); err != nil {
+				return err
+			}
+			caisse.HashVersion = models.CaisseHashVersion
+			caisse.Hash = caisse.ComputeHash()
+		}
+		if err := tx.Omit("Appartment", "Category", "Lines").Save(&caisse).Error; err != nil {
+			return err
+		}
+		if err := models.SaveCaisseLines(tx, caisse); err != nil {
+			return err
+		}
+		// L'ancien jour de l'entrée comme le nouveau
+		return models.RefreshDailySummaries(tx, caisse.AppartmentUUID, previousDate, caisse.TransactionDate)
+	})
+	if err != nil {
+		return caisseError(c, err, "Failed to update Caisse")
+	}
 
 	return c.JSON(
 		fiber.Map{
@@ -498,6 +505,17 @@ func DeleteCaisse(c *fiber.Ctx) error {
 		)
 	}
 
+	// Les entrées comptabilisées sont conservées, elles s'annulent par contre-passation
+	if caisse.Status != models.CaisseDraft {
+		return c.Status(409).JSON(
+			fiber.Map{
+				"status":  "error",
+				"message": "Only draft entries can be deleted, reverse this entry instead",
+				"data":    nil,
+			},
+		)
+	}
+
 	db.Delete(&caisse)
 
 	return c.JSON(
@@ -508,3 +526,50 @@ func DeleteCaisse(c *fiber.Ctx) error {
 		},
 	)
 }
+
+// caisseTotals additionne en une seule requête les lignes des entrées comptabilisées de la période,
+// par type et par devise. total_income_usd et les autres totaux USD et CDF sont ceux des lignes.
+func caisseTotals(db *gorm.DB, appartmentUUID string, filter *utils.QueryFilter) (map[string]interface{}, error) {
+	income := make(map[string]utils.Amount)
+	expense := make(map[string]utils.Amount)
+	for _, currency := range utils.EnabledCurrencies() {
+		income[currency] = utils.Zero
+		expense[currency] = utils.Zero
+	}
+
+	query := db.Model(&models.Caisse{}).Scopes(models.PostedCaisses).
+		Joins("JOIN caisse_lines ON caisse_lines.caisse_uuid = caisses.uuid")
+	if appartmentUUID != "" {
+		query = query.Where("caisses.appartment_uuid = ?", appartmentUUID)
+	}
+	query = filter.ApplyPeriod(query, "caisses.transaction_date")
+
+	var sums []struct {
+		Type     string
+		Currency string
+		Total    utils.Amount
+	}
+	err := query.Select("caisses.type, caisse_lines.currency, COALESCE(SUM(caisse_lines.amount), 0) AS total").
+		Group("caisses.type, caisse_lines.currency").
+		Scan(&sums).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, sum := range sums {
+		switch sum.Type {
+		case "Income":
+			income[sum.Currency] = sum.Total
+		case "Expense":
+			expense[sum.Currency] = sum.Total
+		}
+	}
+
+	return map[string]interface{}{
+		"total_income_usd":    income[utils.CurrencyUSD],
+		"total_expense_usd":   expense[utils.CurrencyUSD],
+		"total_income_cdf":    income[utils.CurrencyCDF],
+		"total_expense_cdf":   expense[utils.CurrencyCDF],
+		"income_by_currency":  income,
+		"expense_by_currency": expense,
+	}, nil
+}
@@ -0,0 +1,68 @@
+package caisses
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kgermando/appartment-app-api/utils"
+)
+
+// defaultBackdateDays est le nombre de jours dans le passé acceptés pour la date d'opération
+const defaultBackdateDays = 31
+
+// caisseBackdateDays lit le nombre de jours acceptés dans CAISSE_BACKDATE_DAYS
+func caisseBackdateDays() int {
+	if v := utils.Env("CAISSE_BACKDATE_DAYS"); v != "" {
+		if days, err := strconv.Atoi(v); err == nil && days >= 0 {
+			return days
+		}
+	}
+	return defaultBackdateDays
+}
+
+// parseCaisseDate lit une date d'opération (YYYY-MM-DD, DD/MM/YYYY ou RFC 3339).
+// Sans fuseau, la date est celle de l'organisation.
+func parseCaisseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return utils.ParseDateIn(value, utils.OrgLocation())
+}
+
+// caisseDates valide la date d'opération et la date de valeur d'une entrée. Sans date,
+// l'opération est du jour. La date d'opération ne peut être ni future ni antérieure de
+// plus de CAISSE_BACKDATE_DAYS jours (jours du fuseau de l'organisation), la date de valeur reste dans le même intervalle
+// autour de la date d'opération.
+func caisseDates(transactionDate, valueDate string) (time.Time, *time.Time, *fiber.Error) {
+	now := time.Now()
+	transaction := now
+	if transactionDate != "" {
+		t, err := parseCaisseDate(transactionDate)
+		if err != nil {
+			return time.Time{}, nil, fiber.NewError(400, "transaction_date: "+err.Error())
+		}
+		transaction = t
+	}
+
+	backdate := time.Duration(caisseBackdateDays()) * 24 * time.Hour
+	today := utils.StartOfDay(now, utils.OrgLocation())
+	if !transaction.Before(today.AddDate(0, 0, 1)) {
+		return time.Time{}, nil, fiber.NewError(400, "transaction_date cannot be in the future")
+	}
+	if transaction.Before(today.Add(-backdate)) {
+		return time.Time{}, nil, fiber.NewError(400, "transaction_date cannot be more than "+strconv.Itoa(caisseBackdateDays())+" days in the past")
+	}
+
+	if valueDate == "" {
+		return transaction, nil, nil
+	}
+	value, err := parseCaisseDate(valueDate)
+	if err != nil {
+		return time.Time{}, nil, fiber.NewError(400, "value_date: "+err.Error())
+	}
+	if value.Before(transaction.Add(-backdate)) || value.After(transaction.Add(backdate)) {
+		return time.Time{}, nil, fiber.NewError(400, "value_date must be within "+strconv.Itoa(caisseBackdateDays())+" days of transaction_date")
+	}
+	return transaction, &value, nil
+}
@@ -0,0 +1,226 @@
+package caisses
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kgermando/appartment-app-api/database"
+	"github.com/kgermando/appartment-app-api/models"
+	"github.com/kgermando/appartment-app-api/utils"
+	"gorm.io/gorm"
+)
+
+// caisseExportRow est une ligne du livre de caisse exporté
+type caisseExportRow struct {
+	TransactionDate  time.Time
+	AppartmentName   string
+	AppartmentNumber string
+	Type             string
+	DeviceUSD        utils.Amount
+	DeviceCDF        utils.Amount
+	OtherAmounts     string // Montants dans les autres devises, ex. 120.00 EUR
+	Motif            string
+	Signature        string
+}
+
+var caisseExportColumns = []utils.ExportColumn{
+	{Title: "Date", Width: 28},
+	{Title: "Appartement", Width: 40},
+	{Title: "Numéro", Width: 18},
+	{Title: "Type", Width: 20},
+	{Title: "Montant USD", Width: 28},
+	{Title: "Montant CDF", Width: 32},
+	{Title: "Autres devises", Width: 36},
+	{Title: "Motif", Width: 76},
+	{Title: "Signature", Width: 35},
+}
+
+// Export by SuperAdmin
+func ExportCaissesSuperAdmin(c *fiber.Ctx) error {
+	return exportCaisses(c, "")
+}
+
+// Export the cash book of an appartment
+func ExportCaisses(c *fiber.Ctx) error {
+	return exportCaisses(c, c.Params("appartment_uuid"))
+}
+
+// filterCaisses applique la recherche et la période de la requête
+func filterCaisses(filter *utils.QueryFilter, query *gorm.DB) *gorm.DB {
+	query = filter.ApplySearch(query, "caisses.type", "caisses.signature", "caisses.motif")
+	return filter.ApplyPeriod(query, "caisses.transaction_date")
+}
+
+// exportCaisses streams the cash book as CSV, XLSX or PDF, with the same filters
+// as the paginated endpoints. Rows are read with a cursor so large periods
+// are never loaded in memory at once. PDF exports are limited to utils.MaxPDFRows rows.
+func exportCaisses(c *fiber.Ctx, appartmentUUID string) error {
+	db := database.DB
+
+	format := strings.ToLower(c.Query("format", utils.ExportCSV))
+	if !utils.IsExportFormat(format) {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "format must be one of csv, xlsx or pdf",
+			"data":    nil,
+		})
+	}
+	filter, err := utils.ParseQueryFilter(c, 0)
+	if err != nil {
+		return caisseError(c, err, "Failed to export Caisses")
+	}
+
+	// scoped retourne les entrées comptabilisées exportées, sur une nouvelle requête à chaque appel
+	scoped := func() *gorm.DB {
+		query := db.Model(&models.Caisse{}).Scopes(models.PostedCaisses)
+		if appartmentUUID != "" {
+			query = query.Where("caisses.appartment_uuid = ?", appartmentUUID)
+		}
+		return filterCaisses(filter, query)
+	}
+
+	if format == utils.ExportPDF {
+		var count int64
+		if err := scoped().Count(&count).Error; err != nil {
+			return caisseError(c, err, "Failed to export Caisses")
+		}
+		if err := utils.CheckExportSize(format, count); err != nil {
+			return caisseError(c, err, "Failed to export Caisses")
+		}
+	}
+
+	query := scoped().
+		Select("caisses.transaction_date, appartments.name AS appartment_name, appartments.number AS appartment_number, " +
+			"caisses.type, caisses.device_usd, caisses.device_cdf, " +
+			"COALESCE((SELECT string_agg(l.amount::text || ' ' || l.currency, ' + ' ORDER BY l.currency) FROM caisse_lines l " +
+			"WHERE l.caisse_uuid = caisses.uuid AND l.currency NOT IN ('USD', 'CDF')), '') AS other_amounts, " +
+			"caisses.motif, caisses.signature").
+		Joins("LEFT JOIN appartments ON appartments.uuid = caisses.appartment_uuid").
+		Order("caisses.transaction_date ASC")
+
+	// Totaux des autres devises, par type
+	var otherTotals []struct {
+		Type     string
+		Currency string
+		Total    utils.Amount
+	}
+	if err := scoped().
+		Select("caisses.type, caisse_lines.currency, COALESCE(SUM(caisse_lines.amount), 0) AS total").
+		Joins("JOIN caisse_lines ON caisse_lines.caisse_uuid = caisses.uuid").
+		Where("caisse_lines.currency NOT IN ?", []string{utils.CurrencyUSD, utils.CurrencyCDF}).
+		Group("caisses.type, caisse_lines.currency").
+		Order("caisse_lines.currency").
+		Scan(&otherTotals).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to export Caisses",
+			"error":   err.Error(),
+		})
+	}
+	otherIncome := make(map[string]utils.Amount)
+	otherExpense := make(map[string]utils.Amount)
+	var otherCurrencies []string
+	for _, total := range otherTotals {
+		if _, ok := otherIncome[total.Currency]; !ok {
+			otherCurrencies = append(otherCurrencies, total.Currency)
+			otherIncome[total.Currency] = utils.Zero
+			otherExpense[total.Currency] = utils.Zero
+		}
+		switch total.Type {
+		case "Income":
+			otherIncome[total.Currency] = total.Total
+		case "Expense":
+			otherExpense[total.Currency] = total.Total
+		}
+	}
+	// formatOther écrit les montants des autres devises, ex. 120.00 EUR + 35.00 ZAR
+	formatOther := func(amount func(currency string) utils.Amount) string {
+		var parts []string
+		for _, currency := range otherCurrencies {
+			parts = append(parts, amount(currency).String()+" "+currency)
+		}
+		return strings.Join(parts, " + ")
+	}
+
+	title := "Livre de caisse"
+	if appartmentUUID != "" {
+		var appartment models.Appartment
+		if err := db.Where("uuid = ?", appartmentUUID).First(&appartment).Error; err != nil {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Appartment not found",
+				"data":    nil,
+			})
+		}
+		title += " - " + appartment.Name + " " + appartment.Number
+	}
+
+	var period []string
+	if v := c.Query("start_date"); v != "" {
+		period = append(period, "Du "+v)
+	}
+	if v := c.Query("end_date"); v != "" {
+		period = append(period, "au "+v)
+	}
+	subtitle := strings.Join(period, " ")
+
+	// La requête est lancée avant l'envoi : une erreur peut encore devenir une réponse 500
+	rows, err := query.Rows()
+	if err != nil {
+		return caisseError(c, err, "Failed to export Caisses")
+	}
+
+	err = utils.StreamTable(c, format, "caisses", title, subtitle, caisseExportColumns, func(table utils.TableWriter) error {
+		defer rows.Close()
+
+		var incomeUSD, incomeCDF, expenseUSD, expenseCDF utils.Amount
+		for rows.Next() {
+			var row caisseExportRow
+			if err := db.ScanRows(rows, &row); err != nil {
+				return err
+			}
+
+			switch row.Type {
+			case "Income":
+				incomeUSD = incomeUSD.Add(row.DeviceUSD)
+				incomeCDF = incomeCDF.Add(row.DeviceCDF)
+			case "Expense":
+				expenseUSD = expenseUSD.Add(row.DeviceUSD)
+				expenseCDF = expenseCDF.Add(row.DeviceCDF)
+			}
+
+			if err := table.WriteRow([]interface{}{
+				row.TransactionDate.In(filter.Location), row.AppartmentName, row.AppartmentNumber, row.Type,
+				row.DeviceUSD, row.DeviceCDF, row.OtherAmounts, row.Motif, row.Signature,
+			}); err != nil {
+				return err
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		totalIncomeOther := formatOther(func(currency string) utils.Amount { return otherIncome[currency] })
+		totalExpenseOther := formatOther(func(currency string) utils.Amount { return otherExpense[currency] })
+		balanceOther := formatOther(func(currency string) utils.Amount {
+			return otherIncome[currency].Sub(otherExpense[currency])
+		})
+
+		for _, footer := range [][]interface{}{
+			{"Total entrées", "", "", "Income", incomeUSD, incomeCDF, totalIncomeOther, "", ""},
+			{"Total sorties", "", "", "Expense", expenseUSD, expenseCDF, totalExpenseOther, "", ""},
+			{"Solde", "", "", "", incomeUSD.Sub(expenseUSD), incomeCDF.Sub(expenseCDF), balanceOther, "", ""},
+		} {
+			if err := table.WriteFooter(footer); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		rows.Close()
+		return caisseError(c, err, "Failed to export Caisses")
+	}
+	return nil
+}
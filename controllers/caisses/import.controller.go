@@ -0,0 +1,228 @@
+package caisses
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kgermando/appartment-app-api/database"
+	"github.com/kgermando/appartment-app-api/models"
+	"github.com/kgermando/appartment-app-api/utils"
+	"gorm.io/gorm"
+)
+
+// Colonnes obligatoires du fichier d'import de la caisse
+var caisseImportColumns = []string{"appartment_name", "appartment_number", "type", "category", "motif"}
+
+// Import caisse entries from a CSV or XLSX file (multipart field "file").
+// Columns: appartment_name, appartment_number, type, category (code or name),
+// device_cdf, device_usd, currency and amount (another enabled currency), motif, signature, date.
+// With ?dry_run=true the file is only validated.
+func ImportCaisses(c *fiber.Ctx) error {
+	db := database.DB
+
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run", "false"))
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No file provided - use the 'file' field",
+			"data":    nil,
+		})
+	}
+
+	content, err := utils.ReadSpreadsheet(file)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to read file",
+			"error":   err.Error(),
+		})
+	}
+
+	rows := utils.SpreadsheetRows(content)
+	if len(rows) == 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "The file contains no data row",
+			"data":    nil,
+		})
+	}
+	for _, column := range caisseImportColumns {
+		if !rows[0].HasColumn(column) {
+			return c.Status(400).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Missing required column '" + column + "'",
+				"data":    caisseImportColumns,
+			})
+		}
+	}
+
+	// Résoudre les appartements par nom + numéro en une fois
+	// La clé de recherche ignore la casse, comme la correspondance des lignes
+	var names []string
+	for _, row := range rows {
+		names = append(names, strings.ToLower(row.Get("appartment_name")))
+	}
+
+	var appartments []models.Appartment
+	if err := db.Select("uuid", "name", "number").Where("LOWER(name) IN ?", names).Find(&appartments).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch Appartments",
+			"error":   err.Error(),
+		})
+	}
+	appartmentByKey := make(map[string]string, len(appartments))
+	for _, apt := range appartments {
+		appartmentByKey[strings.ToLower(apt.Name)+"|"+strings.ToLower(apt.Number)] = apt.UUID
+	}
+
+	// Catégories actives par code et par nom
+	var categories []models.Category
+	if err := db.Where("active = ?", true).Find(&categories).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch categories",
+			"error":   err.Error(),
+		})
+	}
+	categoryByKey := make(map[string]models.Category, 2*len(categories))
+	for _, category := range categories {
+		categoryByKey[strings.ToLower(category.Code)] = category
+		categoryByKey[strings.ToLower(category.Name)] = category
+	}
+
+	report := models.ImportReport{TotalRows: len(rows), DryRun: dryRun, Errors: []models.ImportRowError{}}
+	var caisses []models.Caisse
+
+	for _, row := range rows {
+		var rowErrors []models.ImportRowError
+		fail := func(field, message string) {
+			rowErrors = append(rowErrors, models.ImportRowError{Row: row.Number, Field: field, Message: message})
+		}
+
+		caisse := models.Caisse{
+			UUID:      utils.GenerateUUID(),
+			Motif:     row.Get("motif"),
+			Signature: row.Get("signature"),
+		}
+
+		name, number := row.Get("appartment_name"), row.Get("appartment_number")
+		appartmentUUID, ok := appartmentByKey[strings.ToLower(name)+"|"+strings.ToLower(number)]
+		if !ok {
+			fail("appartment_number", "no appartment found for "+name+" "+number)
+		}
+		caisse.AppartmentUUID = appartmentUUID
+
+		switch strings.ToLower(row.Get("type")) {
+		case "income":
+			caisse.Type = "Income"
+		case "expense":
+			caisse.Type = "Expense"
+		default:
+			fail("type", "type must be either 'Income' or 'Expense'")
+		}
+
+		if category, ok := categoryByKey[strings.ToLower(row.Get("category"))]; !ok {
+			fail("category", "unknown category '"+row.Get("category")+"'")
+		} else if caisse.Type != "" && category.Type != caisse.Type {
+			fail("category", "category "+category.Name+" is for "+category.Type+" entries")
+		} else {
+			caisse.CategoryUUID = category.UUID
+		}
+
+		var err error
+		if caisse.DeviceCDF, err = utils.ParseMoney(row.Get("device_cdf")); err != nil || caisse.DeviceCDF.IsNegative() {
+			fail("device_cdf", "device_cdf must be a positive number")
+		}
+		if caisse.DeviceUSD, err = utils.ParseMoney(row.Get("device_usd")); err != nil || caisse.DeviceUSD.IsNegative() {
+			fail("device_usd", "device_usd must be a positive number")
+		}
+		var lines []models.CaisseLine
+		if !caisse.DeviceUSD.IsZero() {
+			lines = append(lines, models.CaisseLine{Currency: utils.CurrencyUSD, Amount: caisse.DeviceUSD})
+		}
+		if !caisse.DeviceCDF.IsZero() {
+			lines = append(lines, models.CaisseLine{Currency: utils.CurrencyCDF, Amount: caisse.DeviceCDF})
+		}
+		if currency := row.Get("currency"); currency != "" {
+			if amount, err := utils.ParseMoney(row.Get("amount")); err != nil || !amount.IsPositive() {
+				fail("amount", "amount must be greater than 0")
+			} else {
+				lines = append(lines, models.CaisseLine{Currency: currency, Amount: amount})
+			}
+		}
+		if len(lines) == 0 {
+			fail("", "device_cdf, device_usd or amount must be greater than 0")
+		} else if err := caisse.SetLines(lines); err != nil {
+			fail("currency", err.Error())
+		}
+
+		if caisse.Motif == "" {
+			fail("motif", "motif is required")
+		}
+
+		// La date du fichier est la date d'opération, les reprises d'historique ne sont pas limitées dans le passé
+		caisse.TransactionDate = time.Now()
+		if v := row.Get("date"); v != "" {
+			if caisse.TransactionDate, err = utils.ParseDateIn(v, utils.OrgLocation()); err != nil {
+				fail("date", err.Error())
+			} else if caisse.TransactionDate.After(time.Now()) {
+				fail("date", "date cannot be in the future")
+			}
+		}
+		if err := models.EnsurePeriodOpen(db, caisse.TransactionDate); err != nil {
+			fail("date", err.Error())
+		}
+
+		if len(rowErrors) > 0 {
+			report.InvalidRows++
+			report.Errors = append(report.Errors, rowErrors...)
+			continue
+		}
+
+		report.ValidRows++
+		caisses = append(caisses, caisse)
+	}
+
+	if !dryRun && len(caisses) > 0 {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// Les pièces sont numérotées une à une, dans l'ordre du fichier
+			for i := range caisses {
+				if err := resolveCaisseAccount(tx, &caisses[i]); err != nil {
+					return err
+				}
+				if err := models.AssignVoucher(tx, &caisses[i]); err != nil {
+					return err
+				}
+				if err := tx.Omit("Appartment", "Category", "Lines").Create(&caisses[i]).Error; err != nil {
+					return err
+				}
+				if err := models.SaveCaisseLines(tx, &caisses[i]); err != nil {
+					return err
+				}
+				if err := models.RefreshDailySummaries(tx, caisses[i].AppartmentUUID, caisses[i].TransactionDate); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Failed to import Caisses",
+				"error":   err.Error(),
+			})
+		}
+		report.Imported = len(caisses)
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Caisses import processed",
+		"data":    report,
+	})
+}
@@ -0,0 +1,316 @@
+package caisses
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/gofiber/fiber/v2"
+	"github.com/kgermando/appartment-app-api/database"
+	"github.com/kgermando/appartment-app-api/models"
+	"github.com/kgermando/appartment-app-api/utils"
+	"gorm.io/gorm"
+)
+
+// Download the rent receipt (quittance) of an income entry.
+// The receipt is numbered on first download; period_start and period_end
+// (YYYY-MM-DD) set the period covered, by default the month of the payment.
+// Dates are shown in the organisation time zone, or in the one given by tz.
+func GetCaisseReceipt(c *fiber.Ctx) error {
+	loc, err := utils.RequestLocation(c)
+	if err != nil {
+		return receiptError(c, err)
+	}
+	receipt, caisse, tenant, err := issueReceipt(c, loc)
+	if err != nil {
+		return receiptError(c, err)
+	}
+
+	var buf bytes.Buffer
+	if err := renderReceipt(&buf, receipt, caisse, tenant, loc); err != nil {
+		return receiptError(c, err)
+	}
+
+	c.Set(fiber.HeaderContentType, "application/pdf")
+	c.Set(fiber.HeaderContentDisposition, `inline; filename="quittance-`+receipt.Number+`.pdf"`)
+	return c.Send(buf.Bytes())
+}
+
+// Email the rent receipt to the tenant, or to the address given in the body
+func EmailCaisseReceipt(c *fiber.Ctx) error {
+	var input struct {
+		Email string `json:"email"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid input",
+				"data":    nil,
+			})
+		}
+	}
+
+	loc, err := utils.RequestLocation(c)
+	if err != nil {
+		return receiptError(c, err)
+	}
+	receipt, caisse, tenant, err := issueReceipt(c, loc)
+	if err != nil {
+		return receiptError(c, err)
+	}
+
+	email := strings.TrimSpace(input.Email)
+	if email == "" && tenant != nil {
+		email = tenant.Email
+	}
+	if email == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No email address for this tenant, please provide one",
+			"data":    nil,
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := renderReceipt(&buf, receipt, caisse, tenant, loc); err != nil {
+		return receiptError(c, err)
+	}
+
+	body := "<p>Bonjour,</p><p>Veuillez trouver ci-joint la quittance de loyer n° " + receipt.Number +
+		" pour l'appartement " + caisse.Appartment.Name + " " + caisse.Appartment.Number + ".</p>"
+	if err := utils.SendMail([]string{email}, "Quittance de loyer "+receipt.Number, body, utils.MailAttachment{
+		Filename:    "quittance-" + receipt.Number + ".pdf",
+		ContentType: "application/pdf",
+		Data:        buf.Bytes(),
+	}); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Receipt email was not sent",
+			"error":   err.Error(),
+		})
+	}
+
+	now := time.Now()
+	database.DB.Model(receipt).Updates(map[string]interface{}{"emailed_to": email, "emailed_at": now})
+	receipt.EmailedTo = email
+	receipt.EmailedAt = &now
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Receipt sent to " + email,
+		"data":    receipt,
+	})
+}
+
+// issueReceipt retrouve la quittance de l'entrée de caisse ou en crée une nouvelle
+// avec le prochain numéro de l'année. Le mois du paiement est celui du fuseau loc.
+func issueReceipt(c *fiber.Ctx, loc *time.Location) (*models.Receipt, *models.Caisse, *models.Tenant, error) {
+	db := database.DB
+
+	var caisse models.Caisse
+	if err := db.Where("uuid = ?", c.Params("uuid")).Preload("Appartment").Preload("Lines").First(&caisse).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, nil, fiber.NewError(404, "No Caisse found")
+		}
+		return nil, nil, nil, err
+	}
+	if caisse.Type != "Income" {
+		return nil, nil, nil, fiber.NewError(400, "Receipts can only be issued for Income entries")
+	}
+	// Ni brouillon, ni contre-passation, ni paiement contre-passé
+	if caisse.Status != models.CaissePosted || caisse.ReversalOfUUID != "" {
+		return nil, nil, nil, fiber.NewError(400, "Receipts can only be issued for posted payments")
+	}
+	if caisse.AppartmentUUID == "" || caisse.Appartment.UUID == "" {
+		return nil, nil, nil, fiber.NewError(400, "This entry is not tied to an appartment")
+	}
+
+	var receipt models.Receipt
+	err := db.Where("caisse_uuid = ?", caisse.UUID).First(&receipt).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, nil, err
+	}
+
+	if receipt.UUID == "" {
+		periodStart, periodEnd, err := receiptPeriod(c, caisse.TransactionDate.In(loc))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		tenant := receiptTenant(db, caisse)
+		receipt = models.Receipt{
+			UUID:        utils.GenerateUUID(),
+			CaisseUUID:  caisse.UUID,
+			Year:        caisse.TransactionDate.In(utils.OrgLocation()).Year(),
+			PeriodStart: periodStart,
+			PeriodEnd:   periodEnd,
+		}
+		if tenant != nil {
+			receipt.TenantUUID = tenant.UUID
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			sequence, number, err := models.NextReceiptNumber(tx, receipt.Year)
+			if err != nil {
+				return err
+			}
+			receipt.Sequence = sequence
+			receipt.Number = number
+			return tx.Create(&receipt).Error
+		})
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return &receipt, &caisse, tenant, nil
+	}
+
+	var tenant *models.Tenant
+	if receipt.TenantUUID != "" {
+		var t models.Tenant
+		if db.Where("uuid = ?", receipt.TenantUUID).First(&t).Error == nil {
+			tenant = &t
+		}
+	}
+	return &receipt, &caisse, tenant, nil
+}
+
+// receiptPeriod lit la période couverte, par défaut le mois du paiement. Les bornes sont
+// des dates calendaires, enregistrées à minuit UTC comme period_start et period_end.
+func receiptPeriod(c *fiber.Ctx, paidAt time.Time) (time.Time, time.Time, error) {
+	start := time.Date(paidAt.Year(), paidAt.Month(), 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, -1)
+
+	if v := c.Query("period_start"); v != "" {
+		parsed, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return start, end, fiber.NewError(400, "Invalid period_start, expected YYYY-MM-DD")
+		}
+		start = parsed
+		if c.Query("period_end") == "" {
+			end = start.AddDate(0, 1, -1)
+		}
+	}
+	if v := c.Query("period_end"); v != "" {
+		parsed, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return start, end, fiber.NewError(400, "Invalid period_end, expected YYYY-MM-DD")
+		}
+		end = parsed
+	}
+	if end.Before(start) {
+		return start, end, fiber.NewError(400, "period_end must be after period_start")
+	}
+	return start, end, nil
+}
+
+// receiptTenant retrouve le locataire du bail en cours à la date du paiement
+func receiptTenant(db *gorm.DB, caisse models.Caisse) *models.Tenant {
+	var lease models.Lease
+	err := db.Preload("Tenant").
+		Where("appartment_uuid = ? AND status IN ?", caisse.AppartmentUUID, []string{models.LeaseActive, models.LeaseTerminated}).
+		Where("start_date <= ? AND (end_date IS NULL OR end_date >= ?)", caisse.TransactionDate, caisse.TransactionDate).
+		Order("start_date DESC").
+		First(&lease).Error
+	if err != nil {
+		// Paiement enregistré avant la date de début : prendre le bail actif
+		err = db.Preload("Tenant").
+			Where("appartment_uuid = ? AND status = ?", caisse.AppartmentUUID, models.LeaseActive).
+			First(&lease).Error
+	}
+	if err != nil || lease.Tenant.UUID == "" {
+		return nil
+	}
+	return &lease.Tenant
+}
+
+func receiptError(c *fiber.Ctx, err error) error {
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		return c.Status(fe.Code).JSON(fiber.Map{
+			"status":  "error",
+			"message": fe.Message,
+			"data":    nil,
+		})
+	}
+	return c.Status(500).JSON(fiber.Map{
+		"status":  "error",
+		"message": "Failed to generate receipt",
+		"error":   err.Error(),
+	})
+}
+
+// renderReceipt dessine la quittance au format A5 paysage
+func renderReceipt(w io.Writer, receipt *models.Receipt, caisse *models.Caisse, tenant *models.Tenant, loc *time.Location) error {
+	pdf := fpdf.New("L", "mm", "A5", "")
+	pdf.SetMargins(12, 12, 12)
+	pdf.SetAutoPageBreak(false, 0)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(120, 9, tr("QUITTANCE DE LOYER"), "", 0, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "B", 11)
+	pdf.CellFormat(0, 9, tr("N° "+receipt.Number), "", 1, "R", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	pdf.CellFormat(0, 5, tr("Date du paiement : "+caisse.TransactionDate.In(loc).Format("02/01/2006")), "", 1, "R", false, 0, "")
+	pdf.Ln(3)
+
+	line := func(label, value string) {
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(45, 7, tr(label), "", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.MultiCell(0, 7, tr(value), "", "L", false)
+	}
+
+	apt := caisse.Appartment
+	appartment := apt.Name + " n° " + apt.Number
+	if apt.Area != "" {
+		appartment += ", " + apt.Area
+	}
+	line("Appartement :", appartment)
+
+	tenantName := "...................................................."
+	if tenant != nil {
+		tenantName = tenant.Fullname
+		if tenant.Telephone != "" {
+			tenantName += " (" + tenant.Telephone + ")"
+		}
+	}
+	line("Reçu de :", tenantName)
+
+	// Un montant par devise, USD et CDF en premier
+	var amounts, words []string
+	totals := caisse.TotalsByCurrency()
+	for _, currency := range caisse.Currencies() {
+		if total := totals[currency]; !total.IsZero() {
+			amounts = append(amounts, utils.FormatAmount(total)+" "+currency)
+			words = append(words, utils.AmountInWords(total, currency))
+		}
+	}
+	if len(amounts) == 0 {
+		amounts = []string{utils.FormatAmount(utils.Zero) + " USD"}
+		words = []string{utils.AmountInWords(utils.Zero, "USD")}
+	}
+	line("Montant :", strings.Join(amounts, " + "))
+	line("La somme de :", strings.Join(words, " et "))
+	line("Période :", "du "+receipt.PeriodStart.UTC().Format("02/01/2006")+" au "+receipt.PeriodEnd.UTC().Format("02/01/2006"))
+	if caisse.Motif != "" {
+		line("Motif :", caisse.Motif)
+	}
+
+	pdf.SetY(-38)
+	pdf.SetFont("Helvetica", "I", 8)
+	pdf.MultiCell(110, 4, tr("Cette quittance annule tous les reçus qui auraient pu être donnés pour acompte versé sur la période indiquée."), "", "L", false)
+
+	pdf.SetXY(-82, -38)
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(70, 6, tr("Signature"), "", 2, "C", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(70, 14, tr(caisse.Signature), "B", 0, "C", false, 0, "")
+
+	return pdf.Output(w)
+}
@@ -0,0 +1,295 @@
+package caisses
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kgermando/appartment-app-api/database"
+	"github.com/kgermando/appartment-app-api/models"
+	"github.com/kgermando/appartment-app-api/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// defaultEditWindow est le délai pendant lequel une entrée comptabilisée reste corrigeable
+const defaultEditWindow = 24 * time.Hour
+
+// caisseEditWindow lit le délai de correction dans CAISSE_EDIT_WINDOW (ex. 30m, 24h, 0)
+func caisseEditWindow() time.Duration {
+	if v := utils.Env("CAISSE_EDIT_WINDOW"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			return d
+		}
+	}
+	return defaultEditWindow
+}
+
+// checkCaisseEditable vérifie qu'une entrée peut encore être modifiée : les brouillons
+// toujours, les entrées comptabilisées pendant le délai de correction seulement
+func checkCaisseEditable(caisse *models.Caisse) *fiber.Error {
+	switch caisse.Status {
+	case models.CaisseDraft:
+		return nil
+	case models.CaisseReversed:
+		return fiber.NewError(409, "This entry has been reversed and can no longer be modified")
+	}
+	if caisse.ReversalOfUUID != "" {
+		return fiber.NewError(409, "Reversal entries cannot be modified")
+	}
+
+	postedAt := caisse.CreatedAt
+	if caisse.PostedAt != nil {
+		postedAt = *caisse.PostedAt
+	}
+	if time.Since(postedAt) > caisseEditWindow() {
+		return fiber.NewError(409, "Posted entries are immutable after "+caisseEditWindow().String()+", reverse this entry instead")
+	}
+	return nil
+}
+
+// Post a draft entry: it receives its voucher number and joins the cash book
+func PostCaisse(c *fiber.Ctx) error {
+	db := database.DB
+
+	var caisse models.Caisse
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("uuid = ?", c.Params("uuid")).Preload("Lines").First(&caisse).Error; err != nil {
+			return err
+		}
+		if caisse.Status != models.CaisseDraft {
+			return fiber.NewError(409, "Only draft entries can be posted")
+		}
+		if err := models.EnsurePeriodOpen(tx, caisse.TransactionDate); err != nil {
+			return err
+		}
+		if err := resolveCaisseAccount(tx, &caisse); err != nil {
+			return err
+		}
+		if err := checkCaisseCategory(tx, &caisse); err != nil {
+			return err
+		}
+		if err := models.AssignVoucher(tx, &caisse); err != nil {
+			return err
+		}
+		if err := tx.Omit("Appartment", "Category", "Lines").Save(&caisse).Error; err != nil {
+			return err
+		}
+		// Les taux des lignes sont figés à la comptabilisation
+		if err := models.SaveCaisseLines(tx, &caisse); err != nil {
+			return err
+		}
+		return models.RefreshDailySummaries(tx, caisse.AppartmentUUID, caisse.TransactionDate)
+	})
+	if err != nil {
+		return caisseError(c, err, "Failed to post Caisse")
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Caisse posted success",
+		"data":    caisse,
+	})
+}
+
+// Reverse a posted entry: a counter-entry with opposite amounts is posted and
+// linked to the original, which keeps its amounts and is marked reversed
+func ReverseCaisse(c *fiber.Ctx) error {
+	db := database.DB
+
+	var input struct {
+		Reason    string `json:"reason"`
+		Signature string `json:"signature"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid input",
+			"data":    nil,
+		})
+	}
+	input.Reason = strings.TrimSpace(input.Reason)
+	if input.Reason == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "A reason is required to reverse an entry",
+			"data":    nil,
+		})
+	}
+
+	var original, reversal models.Caisse
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Verrouiller l'entrée : deux contre-passations simultanées de la même pièce attendent
+		// l'une l'autre, la seconde voit alors le statut reversed
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("uuid = ?", c.Params("uuid")).First(&original).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("caisse_uuid = ?", original.UUID).Find(&original.Lines).Error; err != nil {
+			return err
+		}
+		switch {
+		case original.Status == models.CaisseDraft:
+			return fiber.NewError(409, "Draft entries are deleted, not reversed")
+		case original.Status == models.CaisseReversed:
+			return fiber.NewError(409, "This entry has already been reversed")
+		case original.ReversalOfUUID != "":
+			return fiber.NewError(409, "A reversal entry cannot be reversed")
+		}
+
+		// La contre-passation est datée du jour, l'entrée d'origine peut être dans une période clôturée
+		if err := models.EnsurePeriodOpen(tx, time.Now()); err != nil {
+			return err
+		}
+
+		signature := input.Signature
+		if signature == "" {
+			signature = original.Signature
+		}
+
+		reversal = models.Caisse{
+			UUID:             utils.GenerateUUID(),
+			AppartmentUUID:   original.AppartmentUUID,
+			AccountUUID:      original.AccountUUID,
+			CategoryUUID:     original.CategoryUUID,
+			RegisterUUID:     original.RegisterUUID,
+			Type:             original.Type,
+			DeviceCDF:        original.DeviceCDF.Neg(),
+			DeviceUSD:        original.DeviceUSD.Neg(),
+			Motif:            "Contre-passation de la pièce " + original.Voucher + " : " + input.Reason,
+			Signature:        signature,
+			TransactionDate:  time.Now(),
+			ExchangeRate:     original.ExchangeRate,
+			ExchangeRateUUID: original.ExchangeRateUUID,
+			ReversalOfUUID:   original.UUID,
+			ReversalReason:   input.Reason,
+		}
+		// Chaque ligne est contre-passée dans sa devise, au taux de la ligne d'origine
+		for _, line := range original.Lines {
+			reversal.Lines = append(reversal.Lines, models.CaisseLine{
+				UUID:             utils.GenerateUUID(),
+				CaisseUUID:       reversal.UUID,
+				Currency:         line.Currency,
+				Amount:           line.Amount.Neg(),
+				ExchangeRate:     line.ExchangeRate,
+				ExchangeRateUUID: line.ExchangeRateUUID,
+			})
+		}
+		reversal.SyncLineTotals()
+		if err := models.AssignVoucher(tx, &reversal); err != nil {
+			return err
+		}
+		if err := tx.Omit("Appartment", "Category", "Lines").Create(&reversal).Error; err != nil {
+			return err
+		}
+		if err := models.SaveCaisseLines(tx, &reversal); err != nil {
+			return err
+		}
+		if err := models.RefreshDailySummaries(tx, reversal.AppartmentUUID, reversal.TransactionDate); err != nil {
+			return err
+		}
+
+		// Le statut et le lien ne font pas partie de l'empreinte : la chaîne reste intacte
+		original.Status = models.CaisseReversed
+		original.ReversedByUUID = reversal.UUID
+		original.ReversalReason = input.Reason
+		return tx.Model(&original).Updates(map[string]interface{}{
+			"status":           original.Status,
+			"reversed_by_uuid": original.ReversedByUUID,
+			"reversal_reason":  original.ReversalReason,
+		}).Error
+	})
+	if err != nil {
+		return caisseError(c, err, "Failed to reverse Caisse")
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Caisse reversed success",
+		"data": fiber.Map{
+			"original": original,
+			"reversal": reversal,
+		},
+	})
+}
+
+// resolveCaisseAccount vérifie le compte de trésorerie de l'entrée. Sans compte,
+// l'entrée va dans la petite caisse de son appartement.
+func resolveCaisseAccount(tx *gorm.DB, caisse *models.Caisse) error {
+	if caisse.AccountUUID == "" {
+		account, err := models.EnsureAppartmentAccount(tx, caisse.AppartmentUUID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fiber.NewError(400, "Appartment not found")
+		}
+		if err != nil {
+			return err
+		}
+		caisse.AccountUUID = account.UUID
+		return nil
+	}
+
+	var account models.TreasuryAccount
+	if err := tx.Where("uuid = ?", caisse.AccountUUID).First(&account).Error; err != nil {
+		return fiber.NewError(400, "Treasury account not found")
+	}
+	if !account.Active {
+		return fiber.NewError(400, "Treasury account "+account.Name+" is closed")
+	}
+	return nil
+}
+
+// checkCaisseCategory vérifie que la catégorie existe, est active et du même type que l'entrée
+func checkCaisseCategory(tx *gorm.DB, caisse *models.Caisse) error {
+	if caisse.CategoryUUID == "" {
+		return fiber.NewError(400, "Category is required")
+	}
+	var category models.Category
+	if err := tx.Where("uuid = ?", caisse.CategoryUUID).First(&category).Error; err != nil {
+		return fiber.NewError(400, "Category not found")
+	}
+	if !category.Active {
+		return fiber.NewError(400, "Category "+category.Name+" is no longer in use")
+	}
+	if category.Type != caisse.Type {
+		return fiber.NewError(400, "Category "+category.Name+" is for "+category.Type+" entries")
+	}
+	return nil
+}
+
+// caisseError répond avec le code d'une *fiber.Error, 400 pour des montants invalides,
+// 409 pour une période clôturée, 404 si l'entrée n'existe pas, 500 sinon
+func caisseError(c *fiber.Ctx, err error, message string) error {
+	var fe *fiber.Error
+	switch {
+	case errors.As(err, &fe):
+		return c.Status(fe.Code).JSON(fiber.Map{
+			"status":  "error",
+			"message": fe.Message,
+			"data":    nil,
+		})
+	case errors.Is(err, models.ErrInvalidCaisseLines):
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+			"data":    nil,
+		})
+	case errors.Is(err, models.ErrPeriodClosed):
+		return c.Status(409).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error() + ", record a correction in an open period instead",
+			"data":    nil,
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No Caisse found",
+			"data":    nil,
+		})
+	}
+	return c.Status(500).JSON(fiber.Map{
+		"status":  "error",
+		"message": message,
+		"error":   err.Error(),
+	})
+}
@@ -0,0 +1,50 @@
+package caisses
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kgermando/appartment-app-api/database"
+	"github.com/kgermando/appartment-app-api/models"
+)
+
+// Verify the voucher numbering and hash chain of the cash books.
+// Optional filters: register_uuid and year.
+func VerifyCaisseChain(c *fiber.Ctx) error {
+	year, err := strconv.Atoi(c.Query("year", "0"))
+	if err != nil || year < 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid year",
+			"data":    nil,
+		})
+	}
+
+	reports, err := models.VerifyCaisseChains(database.DB, c.Query("register_uuid"), year)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to verify cash books",
+			"error":   err.Error(),
+		})
+	}
+
+	valid := true
+	for _, report := range reports {
+		valid = valid && report.Valid
+	}
+
+	message := "All cash books are intact"
+	if !valid {
+		message = "Broken links found in the cash books"
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": message,
+		"data": fiber.Map{
+			"valid":   valid,
+			"reports": reports,
+		},
+	})
+}
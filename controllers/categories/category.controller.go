@@ -0,0 +1,235 @@
+package categories
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kgermando/appartment-app-api/database"
+	"github.com/kgermando/appartment-app-api/models"
+	"github.com/kgermando/appartment-app-api/utils"
+)
+
+var nonCodeChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Get the category tree, filters: type (Income, Expense), active
+func GetCategoryTree(c *fiber.Ctx) error {
+	db := database.DB
+
+	query := db.Model(&models.Category{})
+	if t := c.Query("type"); t != "" {
+		query = query.Where("type = ?", t)
+	}
+	if active := c.Query("active"); active != "" {
+		query = query.Where("active = ?", active == "true")
+	}
+
+	var categories []models.Category
+	if err := query.Order("type DESC, name").Find(&categories).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch categories",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Category tree",
+		"data":    buildTree(categories, nil),
+	})
+}
+
+// buildTree assemble la liste à plat en arbre à partir des catégories de parent donné
+func buildTree(categories []models.Category, parentUUID *string) []models.Category {
+	tree := []models.Category{}
+	for _, category := range categories {
+		isChild := (parentUUID == nil && category.ParentUUID == nil) ||
+			(parentUUID != nil && category.ParentUUID != nil && *category.ParentUUID == *parentUUID)
+		if !isChild {
+			continue
+		}
+		uuid := category.UUID
+		category.Children = buildTree(categories, &uuid)
+		tree = append(tree, category)
+	}
+	return tree
+}
+
+// Get one category with its direct children
+func GetCategory(c *fiber.Ctx) error {
+	var category models.Category
+	database.DB.Preload("Children").Where("uuid = ?", c.Params("uuid")).First(&category)
+	if category.UUID == "" {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No category found",
+			"data":    nil,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Category found",
+		"data":    category,
+	})
+}
+
+// Create a category. A sub-category has the type of its parent.
+func CreateCategory(c *fiber.Ctx) error {
+	db := database.DB
+
+	p := &models.Category{}
+	if err := c.BodyParser(&p); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid input",
+			"data":    nil,
+		})
+	}
+
+	if p.ParentUUID != nil && *p.ParentUUID == "" {
+		p.ParentUUID = nil
+	}
+	if p.ParentUUID != nil {
+		var parent models.Category
+		db.Where("uuid = ?", *p.ParentUUID).First(&parent)
+		if parent.UUID == "" {
+			return c.Status(400).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Parent category not found",
+				"data":    nil,
+			})
+		}
+		p.Type = parent.Type
+	}
+
+	if p.Name == "" || (p.Type != "Income" && p.Type != "Expense") {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Name is required and type must be either 'Income' or 'Expense'",
+			"data":    nil,
+		})
+	}
+
+	code := p.Code
+	if code == "" {
+		code = p.Name
+	}
+	code = strings.Trim(nonCodeChars.ReplaceAllString(strings.ToLower(code), "_"), "_")
+
+	var count int64
+	db.Unscoped().Model(&models.Category{}).Where("code = ?", code).Count(&count)
+	if count > 0 {
+		return c.Status(409).JSON(fiber.Map{
+			"status":  "error",
+			"message": "A category with code '" + code + "' already exists",
+			"data":    nil,
+		})
+	}
+
+	category := &models.Category{
+		UUID:       utils.GenerateUUID(),
+		Code:       code,
+		Name:       p.Name,
+		Type:       p.Type,
+		ParentUUID: p.ParentUUID,
+		Active:     true,
+	}
+
+	if err := db.Create(category).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to create category",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Category created success",
+		"data":    category,
+	})
+}
+
+// Update a category: name, parent and active. Categories are deactivated, never
+// deleted, so past entries keep their classification.
+func UpdateCategory(c *fiber.Ctx) error {
+	db := database.DB
+
+	type UpdateDataInput struct {
+		Name       string  `json:"name"`
+		ParentUUID *string `json:"parent_uuid"`
+		Active     *bool   `json:"active"`
+	}
+
+	var updateData UpdateDataInput
+	if err := c.BodyParser(&updateData); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Review your input",
+			"data":    nil,
+		})
+	}
+
+	var category models.Category
+	db.Where("uuid = ?", c.Params("uuid")).First(&category)
+	if category.UUID == "" {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No category found",
+			"data":    nil,
+		})
+	}
+
+	if updateData.ParentUUID != nil {
+		if *updateData.ParentUUID == "" {
+			category.ParentUUID = nil
+		} else {
+			// Le nouveau parent ne peut pas être la catégorie elle-même ou une de ses sous-catégories
+			descendants, err := models.CategoryDescendants(db, category.UUID)
+			if err != nil {
+				return c.Status(500).JSON(fiber.Map{
+					"status":  "error",
+					"message": "Failed to check category tree",
+					"error":   err.Error(),
+				})
+			}
+			for _, uuid := range descendants {
+				if uuid == *updateData.ParentUUID {
+					return c.Status(400).JSON(fiber.Map{
+						"status":  "error",
+						"message": "A category cannot be moved under itself",
+						"data":    nil,
+					})
+				}
+			}
+
+			var parent models.Category
+			db.Where("uuid = ?", *updateData.ParentUUID).First(&parent)
+			if parent.UUID == "" || parent.Type != category.Type {
+				return c.Status(400).JSON(fiber.Map{
+					"status":  "error",
+					"message": "Parent category not found or of another type",
+					"data":    nil,
+				})
+			}
+			category.ParentUUID = &parent.UUID
+		}
+	}
+
+	if updateData.Name != "" {
+		category.Name = updateData.Name
+	}
+	if updateData.Active != nil {
+		category.Active = *updateData.Active
+	}
+
+	db.Omit("Children").Save(&category)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Category updated success",
+		"data":    category,
+	})
+}
@@ -0,0 +1,268 @@
+package dashboard
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kgermando/appartment-app-api/models"
+	"github.com/kgermando/appartment-app-api/utils"
+	"gorm.io/gorm"
+)
+
+// flowTotals regroupe les entrées et les sorties d'un groupe, par devise et consolidées
+type flowTotals struct {
+	Income       map[string]utils.Amount
+	Expense      map[string]utils.Amount
+	TotalIncome  utils.Amount
+	TotalExpense utils.Amount
+}
+
+// newFlowTotals retourne des totaux nuls, chaque devise activée étant présente
+func newFlowTotals() *flowTotals {
+	totals := &flowTotals{
+		Income:       make(map[string]utils.Amount),
+		Expense:      make(map[string]utils.Amount),
+		TotalIncome:  utils.Zero,
+		TotalExpense: utils.Zero,
+	}
+	for _, currency := range utils.EnabledCurrencies() {
+		totals.Income[currency] = utils.Zero
+		totals.Expense[currency] = utils.Zero
+	}
+	return totals
+}
+
+// flowTotalsOf retourne les totaux d'un groupe, nuls s'il n'a aucune entrée
+func flowTotalsOf(groups map[string]*flowTotals, key string) *flowTotals {
+	if totals, ok := groups[key]; ok {
+		return totals
+	}
+	return newFlowTotals()
+}
+
+// groupedCaisseTotals additionne en une seule requête les lignes des entrées de la requête
+// par groupe (expression SQL, ex. caisses.appartment_uuid, "" pour un seul groupe de clé ""),
+// par type et par devise, avec leur total consolidé dans la devise de reporting
+func groupedCaisseTotals(query *gorm.DB, group, currency string) (map[string]*flowTotals, error) {
+	key, groupBy := groupKey(group, "caisses.type, caisse_lines.currency")
+	rows, err := query.Joins("JOIN caisse_lines ON caisse_lines.caisse_uuid = caisses.uuid").
+		Select(key + ", caisses.type, caisse_lines.currency, " +
+			"COALESCE(SUM(caisse_lines.amount), 0), " + consolidatedSQL(currency)).
+		Group(groupBy).
+		Rows()
+	if err != nil {
+		return nil, fiber.NewError(500, "Failed to compute totals: "+err.Error())
+	}
+	defer rows.Close()
+
+	groups := make(map[string]*flowTotals)
+	var missing int64
+	for rows.Next() {
+		var key, caisseType, lineCurrency string
+		var amount, consolidated utils.Amount
+		var rowMissing int64
+		if err := rows.Scan(&key, &caisseType, &lineCurrency, &amount, &consolidated, &rowMissing); err != nil {
+			return nil, fiber.NewError(500, "Failed to compute totals: "+err.Error())
+		}
+		missing += rowMissing
+
+		totals, ok := groups[key]
+		if !ok {
+			totals = newFlowTotals()
+			groups[key] = totals
+		}
+		switch caisseType {
+		case "Income":
+			totals.Income[lineCurrency] = amount
+			totals.TotalIncome = totals.TotalIncome.Add(consolidated)
+		case "Expense":
+			totals.Expense[lineCurrency] = amount
+			totals.TotalExpense = totals.TotalExpense.Add(consolidated)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fiber.NewError(500, "Failed to compute totals: "+err.Error())
+	}
+	if missing > 0 {
+		return nil, missingRateError(missing, currency)
+	}
+	return groups, nil
+}
+
+// groupKey retourne la colonne group_key à sélectionner et la clause GROUP BY du groupe
+// suivi des colonnes données. Postgres refuse de grouper par une constante : sans groupe,
+// la clé est ” hors du GROUP BY.
+func groupKey(group, columns string) (string, string) {
+	if group == "" {
+		return "'' AS group_key", columns
+	}
+	if columns != "" {
+		return "COALESCE(" + group + ", '') AS group_key", group + ", " + columns
+	}
+	return "COALESCE(" + group + ", '') AS group_key", group
+}
+
+// apartmentCounts est le nombre d'appartements d'un groupe par statut
+type apartmentCounts struct {
+	GroupKey    string
+	Total       int64
+	Available   int64
+	Occupied    int64
+	Maintenance int64
+}
+
+// groupedApartmentCounts compte en une seule requête les appartements de la requête
+// par groupe (expression SQL, ex. manager_uuid, "" pour un seul groupe de clé "") et par statut
+func groupedApartmentCounts(query *gorm.DB, group string) (map[string]apartmentCounts, error) {
+	key, groupBy := groupKey(group, "")
+	query = query.Select(key + ", COUNT(*) AS total, " +
+		"COUNT(*) FILTER (WHERE status = 'available') AS available, " +
+		"COUNT(*) FILTER (WHERE status = 'occupied') AS occupied, " +
+		"COUNT(*) FILTER (WHERE status = 'maintenance') AS maintenance")
+	if groupBy != "" {
+		query = query.Group(groupBy)
+	}
+
+	var counts []apartmentCounts
+	err := query.Scan(&counts).Error
+	if err != nil {
+		return nil, fiber.NewError(500, "Failed to count apartments: "+err.Error())
+	}
+
+	groups := make(map[string]apartmentCounts, len(counts))
+	for _, count := range counts {
+		groups[count.GroupKey] = count
+	}
+	return groups, nil
+}
+
+// Regroupements des totaux de la caisse
+const (
+	byNone       = ""
+	byAppartment = "appartment"
+	byManager    = "manager"
+	byBuilding   = "building"
+	byMonth      = "month"
+)
+
+// Expressions SQL de chaque regroupement, sur les entrées et sur les résumés journaliers.
+// Le mois d'une entrée dépend du fuseau : voir caisseGroup.
+var (
+	caisseGroups = map[string]string{
+		byNone:       "",
+		byAppartment: "caisses.appartment_uuid",
+		byManager:    "appartments.manager_uuid",
+		byBuilding:   "appartments.name",
+	}
+	summaryGroups = map[string]string{
+		byNone:       "",
+		byAppartment: "daily_summaries.appartment_uuid",
+		byManager:    "appartments.manager_uuid",
+		byBuilding:   "appartments.name",
+		byMonth:      "to_char(daily_summaries.date, 'MM')",
+	}
+)
+
+// caisseGroup retourne l'expression SQL du regroupement des entrées, les mois étant ceux du fuseau donné
+func caisseGroup(group string, loc *time.Location) string {
+	if group == byMonth {
+		return "to_char(" + models.LocalDateSQL(loc) + ", 'MM')"
+	}
+	return caisseGroups[group]
+}
+
+// alignedToDays indique si une borne de période tombe à minuit dans le fuseau donné
+func alignedToDays(t *time.Time, loc *time.Location) bool {
+	return t == nil || utils.StartOfDay(*t, loc).Equal(*t)
+}
+
+// periodTotals additionne les entrées comptabilisées de la période [start, end[ (bornes
+// facultatives), des appartements du gestionnaire si userUUID est renseigné, par groupe,
+// les jours et les mois étant ceux du fuseau loc. Les résumés journaliers sont lus quand loc
+// est le fuseau de l'organisation et que la période commence et finit à minuit,
+// les entrées elles-mêmes sinon.
+func periodTotals(db *gorm.DB, userUUID string, start, end *time.Time, group, currency string, loc *time.Location) (map[string]*flowTotals, error) {
+	if utils.SameLocation(loc, utils.OrgLocation()) && alignedToDays(start, loc) && alignedToDays(end, loc) {
+		return summaryTotals(db, userUUID, start, end, group, currency, loc)
+	}
+	return groupedCaisseTotals(postedCaisses(db, userUUID, start, end), caisseGroup(group, loc), currency)
+}
+
+// summaryTotals additionne les résumés journaliers de la période par groupe et par devise,
+// les bornes étant des minuits du fuseau loc des résumés
+func summaryTotals(db *gorm.DB, userUUID string, start, end *time.Time, group, currency string, loc *time.Location) (map[string]*flowTotals, error) {
+	query := db.Table("daily_summaries").
+		Joins("LEFT JOIN appartments ON appartments.uuid = daily_summaries.appartment_uuid")
+	if userUUID != "" {
+		query = query.Where("appartments.manager_uuid = ?", userUUID)
+	}
+	if start != nil {
+		query = query.Where("daily_summaries.date >= ?", start.In(loc).Format("2006-01-02"))
+	}
+	if end != nil {
+		query = query.Where("daily_summaries.date < ?", end.In(loc).Format("2006-01-02"))
+	}
+
+	suffix := "usd"
+	if currency == utils.CurrencyCDF {
+		suffix = "cdf"
+	}
+	key, groupBy := groupKey(summaryGroups[group], "daily_summaries.currency")
+	rows, err := query.Select(key + ", daily_summaries.currency, " +
+		"COALESCE(SUM(daily_summaries.income), 0), COALESCE(SUM(daily_summaries.expense), 0), " +
+		"COALESCE(SUM(daily_summaries.income_in_" + suffix + "), 0), COALESCE(SUM(daily_summaries.expense_in_" + suffix + "), 0), " +
+		"COALESCE(SUM(daily_summaries.missing_" + suffix + "), 0)").
+		Group(groupBy).
+		Rows()
+	if err != nil {
+		return nil, fiber.NewError(500, "Failed to compute totals: "+err.Error())
+	}
+	defer rows.Close()
+
+	groups := make(map[string]*flowTotals)
+	var missing int64
+	for rows.Next() {
+		var key, lineCurrency string
+		var income, expense, consolidatedIncome, consolidatedExpense utils.Amount
+		var rowMissing int64
+		if err := rows.Scan(&key, &lineCurrency, &income, &expense, &consolidatedIncome, &consolidatedExpense, &rowMissing); err != nil {
+			return nil, fiber.NewError(500, "Failed to compute totals: "+err.Error())
+		}
+		missing += rowMissing
+
+		totals, ok := groups[key]
+		if !ok {
+			totals = newFlowTotals()
+			groups[key] = totals
+		}
+		totals.Income[lineCurrency] = income
+		totals.Expense[lineCurrency] = expense
+		totals.TotalIncome = totals.TotalIncome.Add(consolidatedIncome)
+		totals.TotalExpense = totals.TotalExpense.Add(consolidatedExpense)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fiber.NewError(500, "Failed to compute totals: "+err.Error())
+	}
+	if missing > 0 {
+		return nil, missingRateError(missing, currency)
+	}
+	return groups, nil
+}
+
+// postedCaisses retourne les entrées comptabilisées de la période [start, end[,
+// des appartements du gestionnaire si userUUID est renseigné. Les appartements sont joints
+// pour grouper par gestionnaire.
+func postedCaisses(db *gorm.DB, userUUID string, start, end *time.Time) *gorm.DB {
+	query := db.Model(&models.Caisse{}).Scopes(models.PostedCaisses).
+		Joins("LEFT JOIN appartments ON caisses.appartment_uuid = appartments.uuid")
+	if userUUID != "" {
+		query = query.Where("appartments.manager_uuid = ?", userUUID)
+	}
+	if start != nil {
+		query = query.Where("caisses.transaction_date >= ?", *start)
+	}
+	if end != nil {
+		query = query.Where("caisses.transaction_date < ?", *end)
+	}
+	return query
+}
@@ -0,0 +1,153 @@
+package dashboard
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/kgermando/appartment-app-api/database"
+	"github.com/kgermando/appartment-app-api/models"
+	"github.com/kgermando/appartment-app-api/utils"
+	"gorm.io/gorm"
+)
+
+// Totals per category (with sub-categories rolled up into their parent).
+// Filters: user_uuid, start_date, end_date, type (Income, Expense), currency.
+func GetCategoryTotals(c *fiber.Ctx) error {
+	totals, err := categoryTotals(c)
+	if err != nil {
+		return dashboardError(c, err)
+	}
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Category totals retrieved successfully",
+		"data":    totals,
+	})
+}
+
+// categoryTotals additionne les entrées comptabilisées par catégorie puis
+// reporte les totaux des sous-catégories sur leurs parents
+func categoryTotals(c *fiber.Ctx) ([]*models.CategoryTotal, error) {
+	db := database.DB
+
+	filter, err := utils.ParseQueryFilter(c, 0)
+	if err != nil {
+		return nil, err
+	}
+	caisseType := c.Query("type", "")
+
+	currency, err := reportingCurrency(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var categories []models.Category
+	categoryQuery := db.Order("name")
+	if caisseType != "" {
+		categoryQuery = categoryQuery.Where("type = ?", caisseType)
+	}
+	if err := categoryQuery.Find(&categories).Error; err != nil {
+		return nil, fiber.NewError(500, "Failed to fetch categories: "+err.Error())
+	}
+
+	query := db.Model(&models.Caisse{}).Scopes(models.PostedCaisses)
+	if filter.ManagerUUID != "" {
+		query = filter.ApplyManager(query.Joins("JOIN appartments ON caisses.appartment_uuid = appartments.uuid"), "appartments.manager_uuid")
+	}
+	if caisseType != "" {
+		query = query.Where("caisses.type = ?", caisseType)
+	}
+	query = filter.ApplyPeriod(query, "caisses.transaction_date")
+
+	nodes := make(map[string]*models.CategoryTotal, len(categories))
+	for _, category := range categories {
+		node := &models.CategoryTotal{
+			CategoryUUID:    category.UUID,
+			Code:            category.Code,
+			Name:            category.Name,
+			Type:            category.Type,
+			TotalByCurrency: make(map[string]utils.Amount),
+		}
+		for _, enabled := range utils.EnabledCurrencies() {
+			node.TotalByCurrency[enabled] = utils.Zero
+		}
+		nodes[category.UUID] = node
+	}
+
+	// Montants par catégorie et par devise des lignes
+	rows, err := query.Session(&gorm.Session{}).
+		Joins("JOIN caisse_lines ON caisse_lines.caisse_uuid = caisses.uuid").
+		Select("caisses.category_uuid, caisse_lines.currency, COALESCE(SUM(caisse_lines.amount), 0), " + consolidatedSQL(currency)).
+		Group("caisses.category_uuid, caisse_lines.currency").
+		Rows()
+	if err != nil {
+		return nil, fiber.NewError(500, "Failed to compute category totals: "+err.Error())
+	}
+	defer rows.Close()
+
+	var missing int64
+	for rows.Next() {
+		var categoryUUID, lineCurrency string
+		var amount, consolidated utils.Amount
+		var rowMissing int64
+		if err := rows.Scan(&categoryUUID, &lineCurrency, &amount, &consolidated, &rowMissing); err != nil {
+			return nil, fiber.NewError(500, "Failed to compute category totals: "+err.Error())
+		}
+		missing += rowMissing
+		if node, ok := nodes[categoryUUID]; ok {
+			node.TotalByCurrency[lineCurrency] = amount
+			node.Total = node.Total.Add(consolidated)
+		}
+	}
+	if missing > 0 {
+		return nil, missingRateError(missing, currency)
+	}
+
+	// Nombre d'entrées par catégorie, une entrée pouvant avoir des lignes en plusieurs devises
+	var counts []struct {
+		CategoryUUID string
+		Entries      int64
+	}
+	if err := query.Session(&gorm.Session{}).
+		Select("caisses.category_uuid, COUNT(*) AS entries").
+		Group("caisses.category_uuid").
+		Scan(&counts).Error; err != nil {
+		return nil, fiber.NewError(500, "Failed to compute category totals: "+err.Error())
+	}
+	for _, count := range counts {
+		if node, ok := nodes[count.CategoryUUID]; ok {
+			node.Entries = count.Entries
+		}
+	}
+	for _, node := range nodes {
+		node.TotalUSD = node.TotalByCurrency[utils.CurrencyUSD]
+		node.TotalCDF = node.TotalByCurrency[utils.CurrencyCDF]
+	}
+
+	roots := []*models.CategoryTotal{}
+	for _, category := range categories {
+		node := nodes[category.UUID]
+		if category.ParentUUID != nil {
+			if parent, ok := nodes[*category.ParentUUID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+	for _, root := range roots {
+		rollUp(root)
+	}
+	return roots, nil
+}
+
+// rollUp ajoute les totaux des sous-catégories à leur parent
+func rollUp(node *models.CategoryTotal) {
+	for _, child := range node.Children {
+		rollUp(child)
+		node.TotalUSD = node.TotalUSD.Add(child.TotalUSD)
+		node.TotalCDF = node.TotalCDF.Add(child.TotalCDF)
+		for currency, amount := range child.TotalByCurrency {
+			node.TotalByCurrency[currency] = node.TotalByCurrency[currency].Add(amount)
+		}
+		node.Total = node.Total.Add(child.Total)
+		node.Entries += child.Entries
+	}
+}
@@ -0,0 +1,374 @@
+package dashboard
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kgermando/appartment-app-api/database"
+	"github.com/kgermando/appartment-app-api/models"
+	"github.com/kgermando/appartment-app-api/utils"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// Compare two periods side by side, in total and optionally by group.
+// Filters: start_date, end_date (the current month by default), compare (year: the same period
+// one year earlier, default; previous: the preceding period of the same length), or an explicit
+// compare_start_date and compare_end_date, group_by (building, appartment, manager),
+// user_uuid, currency, tz.
+func GetPeriodComparison(c *fiber.Ctx) error {
+	data, err := periodComparison(c)
+	if err != nil {
+		return dashboardError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Period comparison retrieved successfully",
+		"data":    data,
+	})
+}
+
+// comparisonGroups sont les regroupements acceptés par group_by
+var comparisonGroups = map[string]string{
+	"":           byNone,
+	"building":   byBuilding,
+	"appartment": byAppartment,
+	"manager":    byManager,
+}
+
+// periodComparison calcule les indicateurs des deux périodes et leurs écarts
+func periodComparison(c *fiber.Ctx) (map[string]interface{}, error) {
+	db := database.DB
+
+	filter, err := utils.ParseQueryFilter(c, 0)
+	if err != nil {
+		return nil, err
+	}
+	currency, err := reportingCurrency(c)
+	if err != nil {
+		return nil, err
+	}
+	group, ok := comparisonGroups[c.Query("group_by")]
+	if !ok {
+		return nil, fiber.NewError(400, "group_by must be one of building, appartment or manager")
+	}
+
+	start, end, err := currentPeriod(filter)
+	if err != nil {
+		return nil, err
+	}
+	previousStart, previousEnd, err := comparisonPeriod(c, filter.Location, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	rentCategories, err := rentCategoryUUIDs(db)
+	if err != nil {
+		return nil, err
+	}
+	current, err := groupedPeriodCounters(db, filter, start, end, group, currency, rentCategories)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := groupedPeriodCounters(db, filter, previousStart, previousEnd, group, currency, rentCategories)
+	if err != nil {
+		return nil, err
+	}
+
+	// Total de tous les groupes
+	var currentTotal, previousTotal periodCounters
+	for _, counters := range current {
+		currentTotal.add(counters)
+	}
+	for _, counters := range previous {
+		previousTotal.add(counters)
+	}
+	response := map[string]interface{}{
+		"currency": currency,
+		"group_by": c.Query("group_by"),
+		"total": comparePeriods(currentTotal.metrics(start, end),
+			previousTotal.metrics(previousStart, previousEnd), currency),
+	}
+	if group == byNone {
+		return response, nil
+	}
+
+	// Groupes présents sur l'une ou l'autre période
+	keys := make(map[string]bool, len(current)+len(previous))
+	for key := range current {
+		keys[key] = true
+	}
+	for key := range previous {
+		keys[key] = true
+	}
+	names, err := comparisonGroupNames(db, group, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]models.PeriodComparison, 0, len(keys))
+	for key := range keys {
+		comparison := comparePeriods(countersOf(current, key).metrics(start, end),
+			countersOf(previous, key).metrics(previousStart, previousEnd), currency)
+		comparison.GroupKey = key
+		comparison.GroupName = names[key]
+		groups = append(groups, comparison)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].GroupName != groups[j].GroupName {
+			return groups[i].GroupName < groups[j].GroupName
+		}
+		return groups[i].GroupKey < groups[j].GroupKey
+	})
+	response["groups"] = groups
+
+	return response, nil
+}
+
+// currentPeriod retourne la période demandée, le mois en cours sans start_date ni end_date
+func currentPeriod(filter *utils.QueryFilter) (time.Time, time.Time, error) {
+	if filter.Start == nil && filter.End == nil {
+		today := utils.StartOfDay(time.Now(), filter.Location)
+		start := today.AddDate(0, 0, 1-today.Day())
+		return start, start.AddDate(0, 1, 0), nil
+	}
+	if filter.Start == nil || filter.End == nil {
+		return time.Time{}, time.Time{}, fiber.NewError(400, "start_date and end_date are both required")
+	}
+	return *filter.Start, *filter.End, nil
+}
+
+// comparisonPeriod retourne la période de comparaison : compare_start_date et compare_end_date
+// si elles sont données, sinon la même période un an plus tôt (compare=year) ou la période
+// précédente de même durée (compare=previous). Une période en mois entiers est décalée
+// en mois, pour comparer un trimestre au trimestre précédent.
+func comparisonPeriod(c *fiber.Ctx, loc *time.Location, start, end time.Time) (time.Time, time.Time, error) {
+	if c.Query("compare_start_date") != "" || c.Query("compare_end_date") != "" {
+		from, err := time.ParseInLocation("2006-01-02", c.Query("compare_start_date"), loc)
+		if err != nil {
+			return start, end, fiber.NewError(400, "Invalid compare_start_date, expected YYYY-MM-DD")
+		}
+		to, err := time.ParseInLocation("2006-01-02", c.Query("compare_end_date"), loc)
+		if err != nil {
+			return start, end, fiber.NewError(400, "Invalid compare_end_date, expected YYYY-MM-DD")
+		}
+		to = to.AddDate(0, 0, 1)
+		if !to.After(from) {
+			return start, end, fiber.NewError(400, "compare_end_date cannot be before compare_start_date")
+		}
+		return from, to, nil
+	}
+
+	switch c.Query("compare", "year") {
+	case "year":
+		return start.AddDate(-1, 0, 0), end.AddDate(-1, 0, 0), nil
+	case "previous":
+		if start.Day() == 1 && end.Day() == 1 {
+			months := (end.Year()-start.Year())*12 + int(end.Month()) - int(start.Month())
+			return start.AddDate(0, -months, 0), start, nil
+		}
+		return start.AddDate(0, 0, -int(periodDays(start, end))), start, nil
+	}
+	return start, end, fiber.NewError(400, "compare must be either 'year' or 'previous'")
+}
+
+// periodCounters sont les totaux d'un groupe sur une période, dont sont tirés ses indicateurs
+type periodCounters struct {
+	Income        utils.Amount
+	Expense       utils.Amount
+	RentDue       utils.Amount
+	RentCollected utils.Amount
+	ApartmentDays int64
+	OccupiedDays  int64
+}
+
+func (p *periodCounters) add(o *periodCounters) {
+	p.Income = p.Income.Add(o.Income)
+	p.Expense = p.Expense.Add(o.Expense)
+	p.RentDue = p.RentDue.Add(o.RentDue)
+	p.RentCollected = p.RentCollected.Add(o.RentCollected)
+	p.ApartmentDays += o.ApartmentDays
+	p.OccupiedDays += o.OccupiedDays
+}
+
+// metrics retourne les indicateurs de la période [start, end[
+func (p *periodCounters) metrics(start, end time.Time) models.PeriodMetrics {
+	metrics := models.PeriodMetrics{
+		Start:          start,
+		End:            end,
+		Income:         p.Income,
+		Expense:        p.Expense,
+		Net:            p.Income.Sub(p.Expense),
+		RentDue:        p.RentDue,
+		RentCollected:  p.RentCollected,
+		CollectionRate: p.RentCollected.Percent(p.RentDue),
+	}
+	if p.ApartmentDays > 0 {
+		metrics.OccupancyRate = math.Round(float64(p.OccupiedDays)/float64(p.ApartmentDays)*10000) / 100
+	}
+	return metrics
+}
+
+// countersOf retourne les totaux d'un groupe, nuls s'il n'a rien sur la période
+func countersOf(groups map[string]*periodCounters, key string) *periodCounters {
+	if counters, ok := groups[key]; ok {
+		return counters
+	}
+	return &periodCounters{}
+}
+
+// groupedPeriodCounters calcule les totaux de chaque groupe sur la période [start, end[ :
+// entrées et sorties consolidées dans la devise de reporting, loyers encaissés en USD,
+// occupation et loyers dus d'après les baux
+func groupedPeriodCounters(db *gorm.DB, filter *utils.QueryFilter, start, end time.Time, group, currency string, rentCategories []string) (map[string]*periodCounters, error) {
+	groups := make(map[string]*periodCounters)
+	counters := func(key string) *periodCounters {
+		if _, ok := groups[key]; !ok {
+			groups[key] = &periodCounters{}
+		}
+		return groups[key]
+	}
+
+	totals, err := periodTotals(db, filter.ManagerUUID, &start, &end, group, currency, filter.Location)
+	if err != nil {
+		return nil, err
+	}
+	for key, flow := range totals {
+		counters(key).Income = flow.TotalIncome
+		counters(key).Expense = flow.TotalExpense
+	}
+
+	rents, err := groupedCaisseTotals(rentPayments(postedCaisses(db, filter.ManagerUUID, &start, &end), rentCategories),
+		caisseGroup(group, filter.Location), utils.CurrencyUSD)
+	if err != nil {
+		return nil, err
+	}
+	for key, flow := range rents {
+		counters(key).RentCollected = flow.TotalIncome
+	}
+
+	if err := addOccupancy(db, filter.ManagerUUID, start, end, group, counters); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// addOccupancy ajoute à chaque groupe les jours d'appartement de la période, les jours couverts
+// par un bail et les loyers des baux au prorata de ces jours. Les appartements comptés sont
+// ceux qui existent aujourd'hui.
+func addOccupancy(db *gorm.DB, userUUID string, start, end time.Time, group string, counters func(string) *periodCounters) error {
+	var apartments []models.Appartment
+	apartmentQuery := db.Select("uuid", "name", "manager_uuid")
+	if userUUID != "" {
+		apartmentQuery = apartmentQuery.Where("manager_uuid = ?", userUUID)
+	}
+	if err := apartmentQuery.Find(&apartments).Error; err != nil {
+		return fiber.NewError(500, "Failed to fetch apartments: "+err.Error())
+	}
+
+	leasesByApartment, err := periodLeases(db, start, end)
+	if err != nil {
+		return err
+	}
+
+	days := periodDays(start, end)
+	for _, apt := range apartments {
+		aptCounters := counters(apartmentGroupKey(apt, group))
+		aptCounters.ApartmentDays += days
+
+		var occupied int64
+		rentDue := decimal.Zero
+		for _, lease := range leasesByApartment[apt.UUID] {
+			covered := leaseDays(lease, start, end)
+			occupied += covered
+			rentDue = rentDue.Add(proratedRent(lease, covered))
+		}
+		aptCounters.RentDue = aptCounters.RentDue.Add(utils.AmountFromDecimal(rentDue))
+		aptCounters.OccupiedDays += min(occupied, days)
+	}
+	return nil
+}
+
+// apartmentGroupKey retourne la clé du groupe d'un appartement, comme les totaux groupés
+func apartmentGroupKey(apt models.Appartment, group string) string {
+	switch group {
+	case byAppartment:
+		return apt.UUID
+	case byManager:
+		return apt.ManagerUUID
+	case byBuilding:
+		return apt.Name
+	}
+	return ""
+}
+
+// comparisonGroupNames retourne le libellé de chaque groupe : appartement, gestionnaire ou immeuble
+func comparisonGroupNames(db *gorm.DB, group string, keys map[string]bool) (map[string]string, error) {
+	names := make(map[string]string, len(keys))
+	uuids := make([]string, 0, len(keys))
+	for key := range keys {
+		uuids = append(uuids, key)
+	}
+
+	switch group {
+	case byAppartment:
+		var apartments []models.Appartment
+		if err := db.Select("uuid", "name", "number").Where("uuid IN ?", uuids).Find(&apartments).Error; err != nil {
+			return nil, fiber.NewError(500, "Failed to fetch apartments: "+err.Error())
+		}
+		for _, apt := range apartments {
+			names[apt.UUID] = apt.Name + " " + apt.Number
+		}
+	case byManager:
+		var managers []models.User
+		if err := db.Select("uuid", "fullname").Where("uuid IN ?", uuids).Find(&managers).Error; err != nil {
+			return nil, fiber.NewError(500, "Failed to fetch managers: "+err.Error())
+		}
+		for _, manager := range managers {
+			names[manager.UUID] = manager.Fullname
+		}
+	case byBuilding:
+		for key := range keys {
+			names[key] = key
+		}
+	}
+	return names, nil
+}
+
+// comparePeriods retourne les indicateurs des deux périodes et leurs écarts
+func comparePeriods(current, previous models.PeriodMetrics, currency string) models.PeriodComparison {
+	return models.PeriodComparison{
+		Currency:       currency,
+		Current:        current,
+		Previous:       previous,
+		Income:         amountDelta(current.Income, previous.Income),
+		Expense:        amountDelta(current.Expense, previous.Expense),
+		Net:            amountDelta(current.Net, previous.Net),
+		OccupancyRate:  rateDelta(current.OccupancyRate, previous.OccupancyRate),
+		CollectionRate: rateDelta(current.CollectionRate, previous.CollectionRate),
+	}
+}
+
+// amountDelta retourne l'écart d'un montant, en pourcentage de la valeur absolue de comparaison
+// pour qu'une hausse reste positive quand le solde de comparaison est négatif
+func amountDelta(current, previous utils.Amount) models.AmountDelta {
+	delta := models.AmountDelta{Change: current.Sub(previous)}
+	if !previous.IsZero() {
+		percent := delta.Change.Percent(previous.Abs())
+		delta.Percent = &percent
+	}
+	return delta
+}
+
+// rateDelta retourne l'écart d'un taux en points, et en pourcentage du taux de comparaison
+func rateDelta(current, previous float64) models.RateDelta {
+	delta := models.RateDelta{Change: math.Round((current-previous)*100) / 100}
+	if previous != 0 {
+		percent := math.Round((current-previous)/previous*10000) / 100
+		delta.Percent = &percent
+	}
+	return delta
+}
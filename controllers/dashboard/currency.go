@@ -0,0 +1,33 @@
+package dashboard
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kgermando/appartment-app-api/models"
+	"github.com/kgermando/appartment-app-api/utils"
+)
+
+// reportingCurrency lit la devise de consolidation (?currency=USD|CDF, USD par défaut)
+func reportingCurrency(c *fiber.Ctx) (string, error) {
+	currency := strings.ToUpper(c.Query("currency", utils.CurrencyUSD))
+	if currency != utils.CurrencyUSD && currency != utils.CurrencyCDF {
+		return "", fiber.NewError(400, "currency must be either 'USD' or 'CDF'")
+	}
+	return currency, nil
+}
+
+// consolidatedSQL retourne la somme des lignes convertie dans la devise de consolidation
+// au taux de chaque ligne, arrondie au centime par ligne comme utils.Money.Convert, et le
+// nombre de lignes qui ne peuvent pas être converties
+func consolidatedSQL(currency string) string {
+	return "COALESCE(SUM(" + models.ConvertedLineSQL(currency) + "), 0), " +
+		"COUNT(*) FILTER (WHERE " + models.MissingRateSQL(currency) + ")"
+}
+
+// missingRateError signale les montants sans taux de change : leur total consolidé serait faux
+func missingRateError(missing int64, currency string) error {
+	return fiber.NewError(409, fmt.Sprintf(
+		"%d amounts have no exchange rate, add the rate in force at their date to consolidate in %s", missing, currency))
+}
@@ -1,506 +1,336 @@
 package dashboard
 
 import (
+	"fmt"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/kgermando/appartment-app-api/database"
 	"github.com/kgermando/appartment-app-api/models"
+	"github.com/kgermando/appartment-app-api/utils"
+	"github.com/shopspring/decimal"
 )
 
 func GetDashboardStats(c *fiber.Ctx) error {
-	db := database.DB
-
-	// Parse query parameters (tous optionnels)
-	userUUID := c.Query("user_uuid", "")
-	startDate := c.Query("start_date", "")
-	endDate := c.Query("end_date", "")
-
-	var stats models.DashboardStats
-
-	// 1. Statistiques générales des appartements
-	apartmentBaseQuery := db.Model(&models.Appartment{})
-	if userUUID != "" {
-		apartmentBaseQuery = apartmentBaseQuery.Where("manager_uuid = ?", userUUID)
+	data, err := dashboardStats(c)
+	if err != nil {
+		return dashboardError(c, err)
 	}
 
-	// Clone queries for each status
-	apartmentBaseQuery.Count(&stats.TotalAppartments)
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Dashboard statistics retrieved successfully",
+		"data":    data,
+	})
+}
 
-	availableQuery := db.Model(&models.Appartment{}).Where("status = ?", "available")
-	if userUUID != "" {
-		availableQuery = availableQuery.Where("manager_uuid = ?", userUUID)
-	}
-	availableQuery.Count(&stats.AvailableApartments)
+// dashboardStats computes the general statistics of the dashboard
+func dashboardStats(c *fiber.Ctx) (models.DashboardStats, error) {
+	db := database.DB
 
-	occupiedQuery := db.Model(&models.Appartment{}).Where("status = ?", "occupied")
-	if userUUID != "" {
-		occupiedQuery = occupiedQuery.Where("manager_uuid = ?", userUUID)
-	}
-	occupiedQuery.Count(&stats.OccupiedApartments)
+	var stats models.DashboardStats
 
-	maintenanceQuery := db.Model(&models.Appartment{}).Where("status = ?", "maintenance")
-	if userUUID != "" {
-		maintenanceQuery = maintenanceQuery.Where("manager_uuid = ?", userUUID)
+	// Parse query parameters (tous optionnels)
+	filter, err := utils.ParseQueryFilter(c, 0)
+	if err != nil {
+		return stats, err
 	}
-	maintenanceQuery.Count(&stats.MaintenanceApartments)
 
-	// 2. Statistiques financières en USD et CDF avec filtres optionnels
-	var totalIncomeUSD, totalExpenseUSD, totalIncomeCDF, totalExpenseCDF float64
-
-	// Build income query (filtre par user_uuid et dates si fournis)
-	incomeQuery := db.Model(&models.Caisse{}).Where("type = ?", "Income")
-	if userUUID != "" {
-		incomeQuery = incomeQuery.Joins("JOIN appartments ON caisses.appartment_uuid = appartments.uuid").
-			Where("appartments.manager_uuid = ?", userUUID)
-	}
-	if startDate != "" {
-		if parsedStartDate, err := time.Parse("2006-01-02", startDate); err == nil {
-			incomeQuery = incomeQuery.Where("caisses.created_at >= ?", parsedStartDate)
-		}
-	}
-	if endDate != "" {
-		if parsedEndDate, err := time.Parse("2006-01-02", endDate); err == nil {
-			endDateTime := parsedEndDate.Add(24 * time.Hour)
-			incomeQuery = incomeQuery.Where("caisses.created_at < ?", endDateTime)
-		}
+	currency, err := reportingCurrency(c)
+	if err != nil {
+		return stats, err
 	}
-	incomeQuery.Select("COALESCE(SUM(device_usd), 0)").Row().Scan(&totalIncomeUSD)
+	stats.Currency = currency
 
-	// Income CDF
-	incomeCDFQuery := db.Model(&models.Caisse{}).Where("type = ?", "Income")
-	if userUUID != "" {
-		incomeCDFQuery = incomeCDFQuery.Joins("JOIN appartments ON caisses.appartment_uuid = appartments.uuid").
-			Where("appartments.manager_uuid = ?", userUUID)
-	}
-	if startDate != "" {
-		if parsedStartDate, err := time.Parse("2006-01-02", startDate); err == nil {
-			incomeCDFQuery = incomeCDFQuery.Where("caisses.created_at >= ?", parsedStartDate)
-		}
-	}
-	if endDate != "" {
-		if parsedEndDate, err := time.Parse("2006-01-02", endDate); err == nil {
-			endDateTime := parsedEndDate.Add(24 * time.Hour)
-			incomeCDFQuery = incomeCDFQuery.Where("caisses.created_at < ?", endDateTime)
-		}
+	// 1. Statistiques générales des appartements, par statut en une requête
+	apartmentQuery := filter.ApplyManager(db.Model(&models.Appartment{}), "manager_uuid")
+	counts, err := groupedApartmentCounts(apartmentQuery, "")
+	if err != nil {
+		return stats, err
 	}
-	incomeCDFQuery.Select("COALESCE(SUM(device_cdf), 0)").Row().Scan(&totalIncomeCDF)
+	stats.TotalAppartments = counts[""].Total
+	stats.AvailableApartments = counts[""].Available
+	stats.OccupiedApartments = counts[""].Occupied
+	stats.MaintenanceApartments = counts[""].Maintenance
 
-	// Build expense query (filtre par user_uuid et dates si fournis)
-	expenseQuery := db.Model(&models.Caisse{}).Where("type = ?", "Expense")
-	if userUUID != "" {
-		expenseQuery = expenseQuery.Joins("JOIN appartments ON caisses.appartment_uuid = appartments.uuid").
-			Where("appartments.manager_uuid = ?", userUUID)
-	}
-	if startDate != "" {
-		if parsedStartDate, err := time.Parse("2006-01-02", startDate); err == nil {
-			expenseQuery = expenseQuery.Where("caisses.created_at >= ?", parsedStartDate)
-		}
-	}
-	if endDate != "" {
-		if parsedEndDate, err := time.Parse("2006-01-02", endDate); err == nil {
-			endDateTime := parsedEndDate.Add(24 * time.Hour)
-			expenseQuery = expenseQuery.Where("caisses.created_at < ?", endDateTime)
-		}
+	// 2. Statistiques financières par devise, consolidées dans la devise de reporting
+	groups, err := periodTotals(db, filter.ManagerUUID, filter.Start, filter.End, byNone, currency, filter.Location)
+	if err != nil {
+		return stats, err
 	}
-	expenseQuery.Select("COALESCE(SUM(device_usd), 0)").Row().Scan(&totalExpenseUSD)
+	totals := flowTotalsOf(groups, "")
+	stats.IncomeByCurrency, stats.TotalIncome = totals.Income, totals.TotalIncome
+	stats.ExpenseByCurrency, stats.TotalExpense = totals.Expense, totals.TotalExpense
+	stats.TotalIncomeUSD, stats.TotalIncomeCDF = totals.Income[utils.CurrencyUSD], totals.Income[utils.CurrencyCDF]
+	stats.TotalExpenseUSD, stats.TotalExpenseCDF = totals.Expense[utils.CurrencyUSD], totals.Expense[utils.CurrencyCDF]
 
-	// Expense CDF
-	expenseCDFQuery := db.Model(&models.Caisse{}).Where("type = ?", "Expense")
-	if userUUID != "" {
-		expenseCDFQuery = expenseCDFQuery.Joins("JOIN appartments ON caisses.appartment_uuid = appartments.uuid").
-			Where("appartments.manager_uuid = ?", userUUID)
-	}
-	if startDate != "" {
-		if parsedStartDate, err := time.Parse("2006-01-02", startDate); err == nil {
-			expenseCDFQuery = expenseCDFQuery.Where("caisses.created_at >= ?", parsedStartDate)
-		}
-	}
-	if endDate != "" {
-		if parsedEndDate, err := time.Parse("2006-01-02", endDate); err == nil {
-			endDateTime := parsedEndDate.Add(24 * time.Hour)
-			expenseCDFQuery = expenseCDFQuery.Where("caisses.created_at < ?", endDateTime)
-		}
-	}
-	expenseCDFQuery.Select("COALESCE(SUM(device_cdf), 0)").Row().Scan(&totalExpenseCDF)
+	return stats, nil
+}
 
-	stats.TotalIncomeUSD = totalIncomeUSD
-	stats.TotalIncomeCDF = totalIncomeCDF
-	stats.TotalExpenseUSD = totalExpenseUSD
-	stats.TotalExpenseCDF = totalExpenseCDF
+// GetApartmentRevenues returns revenue statistics for each apartment
+func GetApartmentRevenues(c *fiber.Ctx) error {
+	data, err := apartmentRevenues(c)
+	if err != nil {
+		return dashboardError(c, err)
+	}
 
 	return c.JSON(fiber.Map{
 		"status":  "success",
-		"message": "Dashboard statistics retrieved successfully",
-		"data":    stats,
+		"message": "Apartment revenues retrieved successfully",
+		"data":    data,
 	})
 }
 
-// GetApartmentRevenues returns revenue statistics for each apartment
-func GetApartmentRevenues(c *fiber.Ctx) error {
+// apartmentRevenues computes the revenues of each apartment
+func apartmentRevenues(c *fiber.Ctx) ([]models.ApartmentRevenue, error) {
 	db := database.DB
 
 	// Parse query parameters
-	userUUID := c.Query("user_uuid", "")
-	startDate := c.Query("start_date", "")
-	endDate := c.Query("end_date", "")
+	filter, err := utils.ParseQueryFilter(c, 0)
+	if err != nil {
+		return nil, err
+	}
 
-	// Build apartment query
-	apartmentQuery := db.Preload("Manager")
-	if userUUID != "" {
-		apartmentQuery = apartmentQuery.Where("manager_uuid = ?", userUUID)
+	currency, err := reportingCurrency(c)
+	if err != nil {
+		return nil, err
 	}
 
+	// Build apartment query
+	apartmentQuery := filter.ApplyManager(db.Preload("Manager"), "manager_uuid")
+
 	var apartments []models.Appartment
 	apartmentQuery.Find(&apartments)
 
+	// Totaux de tous les appartements en une requête, groupés par appartement
+	groups, err := periodTotals(db, filter.ManagerUUID, filter.Start, filter.End, byAppartment, currency, filter.Location)
+	if err != nil {
+		return nil, err
+	}
+
 	var revenues []models.ApartmentRevenue
 
 	for _, apt := range apartments {
-		var totalIncomeUSD, totalIncomeCDF, totalExpenseUSD, totalExpenseCDF float64
-
-		// Build income query with date filters
-		incomeQuery := db.Model(&models.Caisse{}).
-			Where("appartment_uuid = ? AND type = ?", apt.UUID, "Income")
-
-		if startDate != "" {
-			if parsedStartDate, err := time.Parse("2006-01-02", startDate); err == nil {
-				incomeQuery = incomeQuery.Where("created_at >= ?", parsedStartDate)
-			}
-		}
-		if endDate != "" {
-			if parsedEndDate, err := time.Parse("2006-01-02", endDate); err == nil {
-				endDateTime := parsedEndDate.Add(24 * time.Hour)
-				incomeQuery = incomeQuery.Where("created_at < ?", endDateTime)
-			}
-		}
-
-		incomeQuery.Select("COALESCE(SUM(device_usd), 0)").Row().Scan(&totalIncomeUSD)
-
-		// Income CDF
-		incomeCDFQuery := db.Model(&models.Caisse{}).
-			Where("appartment_uuid = ? AND type = ?", apt.UUID, "Income")
-		if startDate != "" {
-			if parsedStartDate, err := time.Parse("2006-01-02", startDate); err == nil {
-				incomeCDFQuery = incomeCDFQuery.Where("created_at >= ?", parsedStartDate)
-			}
-		}
-		if endDate != "" {
-			if parsedEndDate, err := time.Parse("2006-01-02", endDate); err == nil {
-				endDateTime := parsedEndDate.Add(24 * time.Hour)
-				incomeCDFQuery = incomeCDFQuery.Where("created_at < ?", endDateTime)
-			}
-		}
-		incomeCDFQuery.Select("COALESCE(SUM(device_cdf), 0)").Row().Scan(&totalIncomeCDF)
-
-		// Build expense query with date filters
-		expenseQuery := db.Model(&models.Caisse{}).
-			Where("appartment_uuid = ? AND type = ?", apt.UUID, "Expense")
-
-		if startDate != "" {
-			if parsedStartDate, err := time.Parse("2006-01-02", startDate); err == nil {
-				expenseQuery = expenseQuery.Where("created_at >= ?", parsedStartDate)
-			}
-		}
-		if endDate != "" {
-			if parsedEndDate, err := time.Parse("2006-01-02", endDate); err == nil {
-				endDateTime := parsedEndDate.Add(24 * time.Hour)
-				expenseQuery = expenseQuery.Where("created_at < ?", endDateTime)
-			}
-		}
-
-		expenseQuery.Select("COALESCE(SUM(device_usd), 0)").Row().Scan(&totalExpenseUSD)
+		totals := flowTotalsOf(groups, apt.UUID)
+		revenues = append(revenues, models.ApartmentRevenue{
+			UUID:              apt.UUID,
+			Name:              apt.Name,
+			Number:            apt.Number,
+			MonthlyRent:       apt.MonthlyRent,
+			TotalIncomeUSD:    totals.Income[utils.CurrencyUSD],
+			TotalIncomeCDF:    totals.Income[utils.CurrencyCDF],
+			TotalExpenseUSD:   totals.Expense[utils.CurrencyUSD],
+			TotalExpenseCDF:   totals.Expense[utils.CurrencyCDF],
+			IncomeByCurrency:  totals.Income,
+			ExpenseByCurrency: totals.Expense,
+			Currency:          currency,
+			TotalIncome:       totals.TotalIncome,
+			TotalExpense:      totals.TotalExpense,
+			Status:            apt.Status,
+			ManagerName:       apt.Manager.Fullname,
+		})
+	}
 
-		// Expense CDF
-		expenseCDFQuery := db.Model(&models.Caisse{}).
-			Where("appartment_uuid = ? AND type = ?", apt.UUID, "Expense")
-		if startDate != "" {
-			if parsedStartDate, err := time.Parse("2006-01-02", startDate); err == nil {
-				expenseCDFQuery = expenseCDFQuery.Where("created_at >= ?", parsedStartDate)
-			}
-		}
-		if endDate != "" {
-			if parsedEndDate, err := time.Parse("2006-01-02", endDate); err == nil {
-				endDateTime := parsedEndDate.Add(24 * time.Hour)
-				expenseCDFQuery = expenseCDFQuery.Where("created_at < ?", endDateTime)
-			}
-		}
-		expenseCDFQuery.Select("COALESCE(SUM(device_cdf), 0)").Row().Scan(&totalExpenseCDF)
-
-		revenue := models.ApartmentRevenue{
-			UUID:            apt.UUID,
-			Name:            apt.Name,
-			Number:          apt.Number,
-			MonthlyRent:     apt.MonthlyRent,
-			TotalIncomeUSD:  totalIncomeUSD,
-			TotalIncomeCDF:  totalIncomeCDF,
-			TotalExpenseUSD: totalExpenseUSD,
-			TotalExpenseCDF: totalExpenseCDF,
-			Status:          apt.Status,
-			ManagerName:     apt.Manager.Fullname,
-		}
+	return revenues, nil
+}
 
-		revenues = append(revenues, revenue)
+// GetManagerStats returns statistics grouped by manager
+func GetManagerStats(c *fiber.Ctx) error {
+	data, err := managerStats(c)
+	if err != nil {
+		return dashboardError(c, err)
 	}
 
 	return c.JSON(fiber.Map{
 		"status":  "success",
-		"message": "Apartment revenues retrieved successfully",
-		"data":    revenues,
+		"message": "Manager statistics retrieved successfully",
+		"data":    data,
 	})
 }
 
-// GetManagerStats returns statistics grouped by manager
-func GetManagerStats(c *fiber.Ctx) error {
+// managerStats computes the statistics of each manager
+func managerStats(c *fiber.Ctx) ([]models.ManagerStats, error) {
 	db := database.DB
 
 	// Parse query parameters
-	userUUID := c.Query("user_uuid", "")
-	startDate := c.Query("start_date", "")
-	endDate := c.Query("end_date", "")
+	filter, err := utils.ParseQueryFilter(c, 0)
+	if err != nil {
+		return nil, err
+	}
 
-	// Build manager query
-	managerQuery := db.Where("role IN ?", []string{"Manager"})
-	if userUUID != "" {
-		managerQuery = managerQuery.Where("uuid = ?", userUUID)
+	currency, err := reportingCurrency(c)
+	if err != nil {
+		return nil, err
 	}
 
+	// Build manager query
+	managerQuery := filter.ApplyManager(db.Where("role IN ?", []string{"Manager"}), "uuid")
+
 	var managers []models.User
 	managerQuery.Find(&managers)
 
+	// Appartements par statut et totaux de tous les gestionnaires, une requête chacun
+	apartmentQuery := filter.ApplyManager(db.Model(&models.Appartment{}), "manager_uuid")
+	counts, err := groupedApartmentCounts(apartmentQuery, "manager_uuid")
+	if err != nil {
+		return nil, err
+	}
+	groups, err := periodTotals(db, filter.ManagerUUID, filter.Start, filter.End, byManager, currency, filter.Location)
+	if err != nil {
+		return nil, err
+	}
+
 	var managerStats []models.ManagerStats
 
 	for _, manager := range managers {
-		var stats models.ManagerStats
-		stats.ManagerUUID = manager.UUID
-		stats.ManagerName = manager.Fullname
-
-		// Count apartments by status for this manager
-		db.Model(&models.Appartment{}).
-			Where("manager_uuid = ?", manager.UUID).
-			Count(&stats.TotalApartments)
-
-		db.Model(&models.Appartment{}).
-			Where("manager_uuid = ? AND status = ?", manager.UUID, "available").
-			Count(&stats.AvailableApartments)
-
-		db.Model(&models.Appartment{}).
-			Where("manager_uuid = ? AND status = ?", manager.UUID, "occupied").
-			Count(&stats.OccupiedApartments)
-
-		// Calculate financial stats for this manager with date filters
-		var totalIncomeUSD, totalIncomeCDF, totalExpenseUSD, totalExpenseCDF float64
-
-		// Income USD query
-		incomeQuery := db.Table("caisses").
-			Joins("JOIN appartments ON caisses.appartment_uuid = appartments.uuid").
-			Where("appartments.manager_uuid = ? AND caisses.type = ?", manager.UUID, "Income")
-
-		if startDate != "" {
-			if parsedStartDate, err := time.Parse("2006-01-02", startDate); err == nil {
-				incomeQuery = incomeQuery.Where("caisses.created_at >= ?", parsedStartDate)
-			}
-		}
-		if endDate != "" {
-			if parsedEndDate, err := time.Parse("2006-01-02", endDate); err == nil {
-				endDateTime := parsedEndDate.Add(24 * time.Hour)
-				incomeQuery = incomeQuery.Where("caisses.created_at < ?", endDateTime)
-			}
-		}
-		incomeQuery.Select("COALESCE(SUM(caisses.device_usd), 0)").Row().Scan(&totalIncomeUSD)
-
-		// Income CDF query
-		incomeCDFQuery := db.Table("caisses").
-			Joins("JOIN appartments ON caisses.appartment_uuid = appartments.uuid").
-			Where("appartments.manager_uuid = ? AND caisses.type = ?", manager.UUID, "Income")
-
-		if startDate != "" {
-			if parsedStartDate, err := time.Parse("2006-01-02", startDate); err == nil {
-				incomeCDFQuery = incomeCDFQuery.Where("caisses.created_at >= ?", parsedStartDate)
-			}
-		}
-		if endDate != "" {
-			if parsedEndDate, err := time.Parse("2006-01-02", endDate); err == nil {
-				endDateTime := parsedEndDate.Add(24 * time.Hour)
-				incomeCDFQuery = incomeCDFQuery.Where("caisses.created_at < ?", endDateTime)
-			}
-		}
-		incomeCDFQuery.Select("COALESCE(SUM(caisses.device_cdf), 0)").Row().Scan(&totalIncomeCDF)
-
-		// Expense USD query
-		expenseQuery := db.Table("caisses").
-			Joins("JOIN appartments ON caisses.appartment_uuid = appartments.uuid").
-			Where("appartments.manager_uuid = ? AND caisses.type = ?", manager.UUID, "Expense")
-
-		if startDate != "" {
-			if parsedStartDate, err := time.Parse("2006-01-02", startDate); err == nil {
-				expenseQuery = expenseQuery.Where("caisses.created_at >= ?", parsedStartDate)
-			}
-		}
-		if endDate != "" {
-			if parsedEndDate, err := time.Parse("2006-01-02", endDate); err == nil {
-				endDateTime := parsedEndDate.Add(24 * time.Hour)
-				expenseQuery = expenseQuery.Where("caisses.created_at < ?", endDateTime)
-			}
-		}
-		expenseQuery.Select("COALESCE(SUM(caisses.device_usd), 0)").Row().Scan(&totalExpenseUSD)
-
-		// Expense CDF query
-		expenseCDFQuery := db.Table("caisses").
-			Joins("JOIN appartments ON caisses.appartment_uuid = appartments.uuid").
-			Where("appartments.manager_uuid = ? AND caisses.type = ?", manager.UUID, "Expense")
-
-		if startDate != "" {
-			if parsedStartDate, err := time.Parse("2006-01-02", startDate); err == nil {
-				expenseCDFQuery = expenseCDFQuery.Where("caisses.created_at >= ?", parsedStartDate)
-			}
-		}
-		if endDate != "" {
-			if parsedEndDate, err := time.Parse("2006-01-02", endDate); err == nil {
-				endDateTime := parsedEndDate.Add(24 * time.Hour)
-				expenseCDFQuery = expenseCDFQuery.Where("caisses.created_at < ?", endDateTime)
-			}
-		}
-		expenseCDFQuery.Select("COALESCE(SUM(caisses.device_cdf), 0)").Row().Scan(&totalExpenseCDF)
+		count := counts[manager.UUID]
+		totals := flowTotalsOf(groups, manager.UUID)
+		managerStats = append(managerStats, models.ManagerStats{
+			ManagerUUID:         manager.UUID,
+			ManagerName:         manager.Fullname,
+			TotalApartments:     count.Total,
+			AvailableApartments: count.Available,
+			OccupiedApartments:  count.Occupied,
+			TotalIncomeUSD:      totals.Income[utils.CurrencyUSD],
+			TotalIncomeCDF:      totals.Income[utils.CurrencyCDF],
+			TotalExpenseUSD:     totals.Expense[utils.CurrencyUSD],
+			TotalExpenseCDF:     totals.Expense[utils.CurrencyCDF],
+			IncomeByCurrency:    totals.Income,
+			ExpenseByCurrency:   totals.Expense,
+			Currency:            currency,
+			TotalIncome:         totals.TotalIncome,
+			TotalExpense:        totals.TotalExpense,
+		})
+	}
 
-		stats.TotalIncomeUSD = totalIncomeUSD
-		stats.TotalIncomeCDF = totalIncomeCDF
-		stats.TotalExpenseUSD = totalExpenseUSD
-		stats.TotalExpenseCDF = totalExpenseCDF
+	return managerStats, nil
+}
 
-		managerStats = append(managerStats, stats)
+// GetMonthlyTrends returns income and expense trends by month
+func GetMonthlyTrends(c *fiber.Ctx) error {
+	data, err := monthlyTrends(c)
+	if err != nil {
+		return dashboardError(c, err)
 	}
 
 	return c.JSON(fiber.Map{
 		"status":  "success",
-		"message": "Manager statistics retrieved successfully",
-		"data":    managerStats,
+		"message": "Monthly trends retrieved successfully",
+		"data":    data,
 	})
 }
 
-// GetMonthlyTrends returns income and expense trends by month
-func GetMonthlyTrends(c *fiber.Ctx) error {
+// monthlyTrends computes the income and expense of each month
+func monthlyTrends(c *fiber.Ctx) ([]models.MonthlyTrend, error) {
 	db := database.DB
 
 	// Parse query parameters
-	userUUID := c.Query("user_uuid", "")
-	startDate := c.Query("start_date", "")
-	endDate := c.Query("end_date", "")
+	filter, err := utils.ParseQueryFilter(c, 0)
+	if err != nil {
+		return nil, err
+	}
 
-	// Get year from query parameter, default to current year
-	year := time.Now().Year()
+	currency, err := reportingCurrency(c)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get year from query parameter, default to current year in the requested time zone
+	year := time.Now().In(filter.Location).Year()
 	if yearParam := c.Query("year"); yearParam != "" {
 		if parsedYear, err := strconv.Atoi(yearParam); err == nil && parsedYear > 0 {
 			year = parsedYear
 		}
 	}
 
-	var trends []models.MonthlyTrend
+	// Période de l'année, réduite par start_date et end_date
+	rangeStart := time.Date(year, time.January, 1, 0, 0, 0, 0, filter.Location)
+	rangeEnd := rangeStart.AddDate(1, 0, 0)
+	if filter.Start != nil && filter.Start.After(rangeStart) {
+		rangeStart = *filter.Start
+	}
+	if filter.End != nil && filter.End.Before(rangeEnd) {
+		rangeEnd = *filter.End
+	}
 
-	// Loop through each month
-	for month := 1; month <= 12; month++ {
-		monthStartDate := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
-		monthEndDate := monthStartDate.AddDate(0, 1, 0).Add(-time.Second)
+	// Les douze mois en une requête, groupés par mois
+	groups, err := periodTotals(db, filter.ManagerUUID, &rangeStart, &rangeEnd, byMonth, currency, filter.Location)
+	if err != nil {
+		return nil, err
+	}
 
-		// Apply custom date range if provided
-		actualStartDate := monthStartDate
-		actualEndDate := monthEndDate
+	var trends []models.MonthlyTrend
 
-		if startDate != "" {
-			if parsedStartDate, err := time.Parse("2006-01-02", startDate); err == nil {
-				if parsedStartDate.After(monthStartDate) {
-					actualStartDate = parsedStartDate
-				}
-			}
-		}
-		if endDate != "" {
-			if parsedEndDate, err := time.Parse("2006-01-02", endDate); err == nil {
-				parsedEndDateTime := parsedEndDate.Add(24 * time.Hour)
-				if parsedEndDateTime.Before(monthEndDate) {
-					actualEndDate = parsedEndDateTime
-				}
-			}
-		}
+	for month := 1; month <= 12; month++ {
+		monthStartDate := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, filter.Location)
 
 		// Skip month if it's outside the date range
-		if actualStartDate.After(actualEndDate) {
+		if !monthStartDate.AddDate(0, 1, 0).After(rangeStart) || !monthStartDate.Before(rangeEnd) {
 			continue
 		}
 
-		var incomeUSD, expenseUSD float64
-
-		// Income query
-		incomeQuery := db.Model(&models.Caisse{}).
-			Where("type = ? AND created_at >= ? AND created_at <= ?", "Income", actualStartDate, actualEndDate)
-
-		if userUUID != "" {
-			incomeQuery = incomeQuery.Joins("JOIN appartments ON caisses.appartment_uuid = appartments.uuid").
-				Where("appartments.manager_uuid = ?", userUUID)
-		}
-		incomeQuery.Select("COALESCE(SUM(device_usd), 0)").Row().Scan(&incomeUSD)
-
-		// Expense query
-		expenseQuery := db.Model(&models.Caisse{}).
-			Where("type = ? AND created_at >= ? AND created_at <= ?", "Expense", actualStartDate, actualEndDate)
-
-		if userUUID != "" {
-			expenseQuery = expenseQuery.Joins("JOIN appartments ON caisses.appartment_uuid = appartments.uuid").
-				Where("appartments.manager_uuid = ?", userUUID)
-		}
-		expenseQuery.Select("COALESCE(SUM(device_usd), 0)").Row().Scan(&expenseUSD)
+		totals := flowTotalsOf(groups, fmt.Sprintf("%02d", month))
+		trends = append(trends, models.MonthlyTrend{
+			Month:             time.Month(month).String(),
+			Year:              year,
+			IncomeUSD:         totals.Income[utils.CurrencyUSD],
+			IncomeCDF:         totals.Income[utils.CurrencyCDF],
+			ExpenseUSD:        totals.Expense[utils.CurrencyUSD],
+			ExpenseCDF:        totals.Expense[utils.CurrencyCDF],
+			IncomeByCurrency:  totals.Income,
+			ExpenseByCurrency: totals.Expense,
+			Currency:          currency,
+			Income:            totals.TotalIncome,
+			Expense:           totals.TotalExpense,
+		})
+	}
 
-		trend := models.MonthlyTrend{
-			Month:      time.Month(month).String(),
-			Year:       year,
-			IncomeUSD:  incomeUSD,
-			ExpenseUSD: expenseUSD,
-		}
+	return trends, nil
+}
 
-		trends = append(trends, trend)
+// GetOccupancyStats returns detailed occupancy statistics
+func GetOccupancyStats(c *fiber.Ctx) error {
+	data, err := occupancyStats(c)
+	if err != nil {
+		return dashboardError(c, err)
 	}
 
 	return c.JSON(fiber.Map{
 		"status":  "success",
-		"message": "Monthly trends retrieved successfully",
-		"data":    trends,
+		"message": "Occupancy statistics retrieved successfully",
+		"data":    data,
 	})
 }
 
-// GetOccupancyStats returns detailed occupancy statistics
-func GetOccupancyStats(c *fiber.Ctx) error {
+// occupancyStats computes the occupancy statistics
+func occupancyStats(c *fiber.Ctx) (models.OccupancyStats, error) {
 	db := database.DB
 
-	// Parse query parameters
-	userUUID := c.Query("user_uuid", "")
-
 	var stats models.OccupancyStats
 
-	// Build base query with user filter
-	baseQuery := db.Model(&models.Appartment{})
-	if userUUID != "" {
-		baseQuery = baseQuery.Where("manager_uuid = ?", userUUID)
+	// Parse query parameters
+	filter, err := utils.ParseQueryFilter(c, 0)
+	if err != nil {
+		return stats, err
 	}
 
+	// Build base query with user filter
+	baseQuery := filter.ApplyManager(db.Model(&models.Appartment{}), "manager_uuid")
+
 	// Count apartments by status
 	baseQuery.Count(&stats.TotalApartments)
 
-	occupiedQuery := db.Model(&models.Appartment{}).Where("status = ?", "occupied")
-	if userUUID != "" {
-		occupiedQuery = occupiedQuery.Where("manager_uuid = ?", userUUID)
-	}
+	occupiedQuery := filter.ApplyManager(db.Model(&models.Appartment{}).Where("status = ?", "occupied"), "manager_uuid")
 	occupiedQuery.Count(&stats.OccupiedApartments)
 
-	availableQuery := db.Model(&models.Appartment{}).Where("status = ?", "available")
-	if userUUID != "" {
-		availableQuery = availableQuery.Where("manager_uuid = ?", userUUID)
-	}
+	availableQuery := filter.ApplyManager(db.Model(&models.Appartment{}).Where("status = ?", "available"), "manager_uuid")
 	availableQuery.Count(&stats.AvailableApartments)
 
-	maintenanceQuery := db.Model(&models.Appartment{}).Where("status = ?", "maintenance")
-	if userUUID != "" {
-		maintenanceQuery = maintenanceQuery.Where("manager_uuid = ?", userUUID)
-	}
+	maintenanceQuery := filter.ApplyManager(db.Model(&models.Appartment{}).Where("status = ?", "maintenance"), "manager_uuid")
 	maintenanceQuery.Count(&stats.MaintenanceApartments)
 
 	// Calculate occupancy and availability rates
@@ -510,260 +340,244 @@ func GetOccupancyStats(c *fiber.Ctx) error {
 	}
 
 	// Calculate average rent
-	avgRentQuery := db.Model(&models.Appartment{})
-	if userUUID != "" {
-		avgRentQuery = avgRentQuery.Where("manager_uuid = ?", userUUID)
-	}
+	avgRentQuery := filter.ApplyManager(db.Model(&models.Appartment{}), "manager_uuid")
 	avgRentQuery.Select("COALESCE(AVG(monthly_rent), 0)").Row().Scan(&stats.AverageRent)
 
 	// Calculate total potential revenue (all apartments)
-	potentialRevenueQuery := db.Model(&models.Appartment{})
-	if userUUID != "" {
-		potentialRevenueQuery = potentialRevenueQuery.Where("manager_uuid = ?", userUUID)
-	}
+	potentialRevenueQuery := filter.ApplyManager(db.Model(&models.Appartment{}), "manager_uuid")
 	potentialRevenueQuery.Select("COALESCE(SUM(monthly_rent), 0)").Row().Scan(&stats.TotalPotentialRevenue)
 
 	// Calculate lost revenue from vacant apartments
-	lostRevenueQuery := db.Model(&models.Appartment{}).Where("status != ?", "occupied")
-	if userUUID != "" {
-		lostRevenueQuery = lostRevenueQuery.Where("manager_uuid = ?", userUUID)
-	}
+	lostRevenueQuery := filter.ApplyManager(db.Model(&models.Appartment{}).Where("status != ?", "occupied"), "manager_uuid")
 	lostRevenueQuery.Select("COALESCE(SUM(monthly_rent), 0)").Row().Scan(&stats.LostRevenue)
 
+	return stats, nil
+}
+
+// GetTopManagers returns the top performing managers
+func GetTopManagers(c *fiber.Ctx) error {
+	data, err := topManagers(c)
+	if err != nil {
+		return dashboardError(c, err)
+	}
+
 	return c.JSON(fiber.Map{
 		"status":  "success",
-		"message": "Occupancy statistics retrieved successfully",
-		"data":    stats,
+		"message": "Top managers retrieved successfully",
+		"data":    data,
 	})
 }
 
-// GetTopManagers returns the top performing managers
-func GetTopManagers(c *fiber.Ctx) error {
+// topManagers computes the performance of each manager
+func topManagers(c *fiber.Ctx) ([]models.TopManager, error) {
 	db := database.DB
 
 	// Parse query parameters
-	userUUID := c.Query("user_uuid", "")
-	startDate := c.Query("start_date", "")
-	endDate := c.Query("end_date", "")
+	filter, err := utils.ParseQueryFilter(c, 0)
+	if err != nil {
+		return nil, err
+	}
 
-	// Build manager query
-	managerQuery := db.Where("role IN ?", []string{"Manager"})
-	if userUUID != "" {
-		managerQuery = managerQuery.Where("uuid = ?", userUUID)
+	currency, err := reportingCurrency(c)
+	if err != nil {
+		return nil, err
 	}
 
+	// Build manager query
+	managerQuery := filter.ApplyManager(db.Where("role IN ?", []string{"Manager"}), "uuid")
+
 	var managers []models.User
 	managerQuery.Find(&managers)
 
+	// Appartements et totaux de tous les gestionnaires, consolidés dans la devise de reporting
+	apartmentQuery := filter.ApplyManager(db.Model(&models.Appartment{}), "manager_uuid")
+	counts, err := groupedApartmentCounts(apartmentQuery, "manager_uuid")
+	if err != nil {
+		return nil, err
+	}
+	groups, err := periodTotals(db, filter.ManagerUUID, filter.Start, filter.End, byManager, currency, filter.Location)
+	if err != nil {
+		return nil, err
+	}
+
 	var topManagers []models.TopManager
 
 	for _, manager := range managers {
-		var topMgr models.TopManager
-		topMgr.ManagerUUID = manager.UUID
-		topMgr.ManagerName = manager.Fullname
-
-		// Count apartments
-		db.Model(&models.Appartment{}).
-			Where("manager_uuid = ?", manager.UUID).
-			Count(&topMgr.ApartmentCount)
-
-		// Calculate financial stats with date filters
-		var totalRevenue, totalExpense float64
-		var occupiedCount int64
-
-		// Revenue query with date filters
-		revenueQuery := db.Table("caisses").
-			Joins("JOIN appartments ON caisses.appartment_uuid = appartments.uuid").
-			Where("appartments.manager_uuid = ? AND caisses.type = ?", manager.UUID, "Income")
-
-		if startDate != "" {
-			if parsedStartDate, err := time.Parse("2006-01-02", startDate); err == nil {
-				revenueQuery = revenueQuery.Where("caisses.created_at >= ?", parsedStartDate)
-			}
-		}
-		if endDate != "" {
-			if parsedEndDate, err := time.Parse("2006-01-02", endDate); err == nil {
-				endDateTime := parsedEndDate.Add(24 * time.Hour)
-				revenueQuery = revenueQuery.Where("caisses.created_at < ?", endDateTime)
-			}
+		count := counts[manager.UUID]
+		totals := flowTotalsOf(groups, manager.UUID)
+
+		topMgr := models.TopManager{
+			ManagerUUID:       manager.UUID,
+			ManagerName:       manager.Fullname,
+			Currency:          currency,
+			TotalRevenue:      totals.TotalIncome,
+			NetProfit:         totals.TotalIncome.Sub(totals.TotalExpense),
+			RevenueUSD:        totals.Income[utils.CurrencyUSD],
+			RevenueCDF:        totals.Income[utils.CurrencyCDF],
+			ExpenseUSD:        totals.Expense[utils.CurrencyUSD],
+			ExpenseCDF:        totals.Expense[utils.CurrencyCDF],
+			RevenueByCurrency: totals.Income,
+			ExpenseByCurrency: totals.Expense,
+			ApartmentCount:    count.Total,
 		}
-		revenueQuery.Select("COALESCE(SUM(caisses.device_usd), 0)").Row().Scan(&totalRevenue)
-
-		// Expense query with date filters
-		expenseQuery := db.Table("caisses").
-			Joins("JOIN appartments ON caisses.appartment_uuid = appartments.uuid").
-			Where("appartments.manager_uuid = ? AND caisses.type = ?", manager.UUID, "Expense")
-
-		if startDate != "" {
-			if parsedStartDate, err := time.Parse("2006-01-02", startDate); err == nil {
-				expenseQuery = expenseQuery.Where("caisses.created_at >= ?", parsedStartDate)
-			}
-		}
-		if endDate != "" {
-			if parsedEndDate, err := time.Parse("2006-01-02", endDate); err == nil {
-				endDateTime := parsedEndDate.Add(24 * time.Hour)
-				expenseQuery = expenseQuery.Where("caisses.created_at < ?", endDateTime)
-			}
-		}
-		expenseQuery.Select("COALESCE(SUM(caisses.device_usd), 0)").Row().Scan(&totalExpense)
-
-		db.Model(&models.Appartment{}).
-			Where("manager_uuid = ? AND status = ?", manager.UUID, "occupied").
-			Count(&occupiedCount)
-
-		topMgr.TotalRevenue = totalRevenue
-		topMgr.NetProfit = totalRevenue - totalExpense
 
 		// Calculate occupancy rate
 		if topMgr.ApartmentCount > 0 {
-			topMgr.OccupancyRate = (float64(occupiedCount) / float64(topMgr.ApartmentCount)) * 100
+			topMgr.OccupancyRate = (float64(count.Occupied) / float64(topMgr.ApartmentCount)) * 100
 		}
 
 		// Calculate efficiency (net profit per apartment)
 		if topMgr.ApartmentCount > 0 {
-			topMgr.Efficiency = topMgr.NetProfit / float64(topMgr.ApartmentCount)
+			topMgr.Efficiency = topMgr.NetProfit.Div(decimal.NewFromInt(topMgr.ApartmentCount))
 		}
 
 		topManagers = append(topManagers, topMgr)
 	}
 
-	return c.JSON(fiber.Map{
-		"status":  "success",
-		"message": "Top managers retrieved successfully",
-		"data":    topManagers,
+	// Classement par revenu consolidé : les entrées en francs comptent autant que les dollars
+	sort.SliceStable(topManagers, func(i, j int) bool {
+		return topManagers[i].TotalRevenue.GreaterThan(topManagers[j].TotalRevenue)
 	})
+
+	return topManagers, nil
 }
 
 // Get appartment payment statistics by month
 func GetAppartmentStats(c *fiber.Ctx) error {
+	response, err := appartmentStats(c)
+	if err != nil {
+		return dashboardError(c, err)
+	}
 
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Appartment payment statistics retrieved successfully",
+		"data":    response,
+	})
+}
+
+// appartmentStats computes the monthly payment statistics of the apartments
+func appartmentStats(c *fiber.Ctx) (map[string]interface{}, error) {
 	db := database.DB
 
-	userUUID := c.Query("user_uuid", "")
+	filter, err := utils.ParseQueryFilter(c, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	currency, err := reportingCurrency(c)
+	if err != nil {
+		return nil, err
+	}
 
-	// Obtenir l'année courante ou depuis les paramètres de requête
-	year := time.Now().Year()
+	// Obtenir l'année courante (dans le fuseau demandé) ou depuis les paramètres de requête
+	year := time.Now().In(filter.Location).Year()
 	if yearParam := c.Query("year"); yearParam != "" {
 		if parsedYear, err := strconv.Atoi(yearParam); err == nil && parsedYear > 0 {
 			year = parsedYear
 		}
 	}
 
-	// Récupérer les appartements avec filtre optionnel
-	var appartments []models.Appartment
-	appartmentQuery := db.Model(&models.Appartment{})
-	if userUUID != "" {
-		appartmentQuery = appartmentQuery.Where("manager_uuid = ?", userUUID)
+	// Compter les appartements avec filtre optionnel
+	var appartmentCount int64
+	if err := filter.ApplyManager(db.Model(&models.Appartment{}), "manager_uuid").Count(&appartmentCount).Error; err != nil {
+		return nil, fiber.NewError(500, "Failed to fetch appartments: "+err.Error())
 	}
 
-	if err := appartmentQuery.Find(&appartments).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Failed to fetch appartments",
-			"error":   err.Error(),
-		})
+	if appartmentCount == 0 {
+		return nil, fiber.NewError(404, "No appartments found")
 	}
 
-	if len(appartments) == 0 {
-		return c.Status(404).JSON(fiber.Map{
-			"status":  "error",
-			"message": "No appartments found",
-			"data":    nil,
-		})
+	// Les douze mois en une requête, groupés par mois : le nombre de requêtes ne dépend
+	// pas du nombre d'entrées
+	startDate := time.Date(year, 1, 1, 0, 0, 0, 0, filter.Location)
+	endDate := startDate.AddDate(1, 0, 0)
+	groups, err := periodTotals(db, filter.ManagerUUID, &startDate, &endDate, byMonth, currency, filter.Location)
+	if err != nil {
+		return nil, err
 	}
 
 	// Initialiser les statistiques pour les 12 mois
-	monthlyStats := make(map[string]map[string]float64)
+	monthlyStats := make(map[string]map[string]utils.Amount)
 	months := []string{"January", "February", "March", "April", "May", "June",
 		"July", "August", "September", "October", "November", "December"}
 
-	for _, month := range months {
-		monthlyStats[month] = map[string]float64{
-			"income_cdf":  0.0,
-			"income_usd":  0.0,
-			"expense_cdf": 0.0,
-			"expense_usd": 0.0,
+	currencies := utils.EnabledCurrencies()
+	for _, totals := range groups {
+		// Une devise désactivée depuis reste dans les totaux de ses entrées
+		for _, amounts := range []map[string]utils.Amount{totals.Income, totals.Expense} {
+			for cur := range amounts {
+				if !slices.Contains(currencies, cur) {
+					currencies = append(currencies, cur)
+				}
+			}
 		}
 	}
+	sort.Strings(currencies[len(utils.EnabledCurrencies()):])
 
-	// Récupérer toutes les entrées (Income et Expense) de la caisse pour ces appartements
-	var caisses []models.Caisse
-	startDate := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
-	endDate := time.Date(year, 12, 31, 23, 59, 59, 0, time.UTC)
-
-	caisseQuery := db.Where("created_at >= ? AND created_at <= ?", startDate, endDate)
-
-	// Filtrer par appartements si nécessaire
-	if userUUID != "" {
-		var appartmentUUIDs []string
-		for _, apt := range appartments {
-			appartmentUUIDs = append(appartmentUUIDs, apt.UUID)
+	for i, month := range months {
+		totals := flowTotalsOf(groups, fmt.Sprintf("%02d", i+1))
+		monthlyStats[month] = map[string]utils.Amount{
+			"income":  totals.TotalIncome, // Consolidé dans la devise de reporting
+			"expense": totals.TotalExpense,
+		}
+		// Une clé income_<devise> et expense_<devise> par devise
+		for _, cur := range currencies {
+			monthlyStats[month]["income_"+strings.ToLower(cur)] = totals.Income[cur]
+			monthlyStats[month]["expense_"+strings.ToLower(cur)] = totals.Expense[cur]
 		}
-		caisseQuery = caisseQuery.Where("appartment_uuid IN ?", appartmentUUIDs)
 	}
 
-	err := caisseQuery.Find(&caisses).Error
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Failed to fetch payment statistics",
-			"error":   err.Error(),
-		})
+	// Calculer les totaux mensuels et annuels
+	yearlyTotals := map[string]utils.Amount{
+		"total_income":  utils.Zero,
+		"total_expense": utils.Zero,
 	}
-
-	// Calculer les totaux par mois
-	for _, caisse := range caisses {
-		monthIndex := int(caisse.CreatedAt.Month()) - 1
-		if monthIndex >= 0 && monthIndex < 12 {
-			monthName := months[monthIndex]
-			switch caisse.Type {
-			case "Income":
-				monthlyStats[monthName]["income_cdf"] += caisse.DeviceCDF
-				monthlyStats[monthName]["income_usd"] += caisse.DeviceUSD
-			case "Expense":
-				monthlyStats[monthName]["expense_cdf"] += caisse.DeviceCDF
-				monthlyStats[monthName]["expense_usd"] += caisse.DeviceUSD
+	for _, month := range months {
+		// Calculer les totaux pour chaque mois et les totaux annuels, par devise
+		for _, cur := range currencies {
+			for _, key := range []string{"income_" + strings.ToLower(cur), "expense_" + strings.ToLower(cur)} {
+				monthlyStats[month]["total_"+key] = monthlyStats[month][key]
+				yearlyTotals["total_"+key] = yearlyTotals["total_"+key].Add(monthlyStats[month][key])
 			}
 		}
+		yearlyTotals["total_income"] = yearlyTotals["total_income"].Add(monthlyStats[month]["income"])
+		yearlyTotals["total_expense"] = yearlyTotals["total_expense"].Add(monthlyStats[month]["expense"])
 	}
 
-	// Calculer les totaux mensuels et annuels
-	var totalYearIncomeCDF, totalYearIncomeUSD, totalYearExpenseCDF, totalYearExpenseUSD float64
-	for _, month := range months {
-		// Calculer les totaux pour chaque mois
-		monthlyStats[month]["total_income_cdf"] = monthlyStats[month]["income_cdf"]
-		monthlyStats[month]["total_income_usd"] = monthlyStats[month]["income_usd"]
-		monthlyStats[month]["total_expense_cdf"] = monthlyStats[month]["expense_cdf"]
-		monthlyStats[month]["total_expense_usd"] = monthlyStats[month]["expense_usd"]
-
-		// Calculer les totaux annuels
-		totalYearIncomeCDF += monthlyStats[month]["income_cdf"]
-		totalYearIncomeUSD += monthlyStats[month]["income_usd"]
-		totalYearExpenseCDF += monthlyStats[month]["expense_cdf"]
-		totalYearExpenseUSD += monthlyStats[month]["expense_usd"]
+	currencyInfo := map[string]string{
+		"cdf": "Francs Congolais",
+		"usd": "US Dollars",
+	}
+	for _, cur := range currencies {
+		if _, ok := currencyInfo[strings.ToLower(cur)]; !ok {
+			currencyInfo[strings.ToLower(cur)] = cur
+		}
 	}
 
 	// Préparer la réponse
 	response := map[string]interface{}{
-		"year":          year,
-		"monthly_stats": monthlyStats,
-		"yearly_totals": map[string]float64{
-			"total_income_cdf":  totalYearIncomeCDF,
-			"total_income_usd":  totalYearIncomeUSD,
-			"total_expense_cdf": totalYearExpenseCDF,
-			"total_expense_usd": totalYearExpenseUSD,
-		},
-		"appartment_count": len(appartments),
-		"filter_applied":   userUUID != "",
-		"currency_info": map[string]string{
-			"cdf": "Francs Congolais",
-			"usd": "US Dollars",
-		},
+		"year":             year,
+		"monthly_stats":    monthlyStats,
+		"yearly_totals":    yearlyTotals,
+		"currency":         currency,
+		"appartment_count": appartmentCount,
+		"filter_applied":   filter.ManagerUUID != "",
+		"currency_info":    currencyInfo,
 	}
 
-	return c.JSON(fiber.Map{
-		"status":  "success",
-		"message": "Appartment payment statistics retrieved successfully",
-		"data":    response,
+	return response, nil
+}
+
+// dashboardError renvoie une erreur de calcul au format JSON de l'API
+func dashboardError(c *fiber.Ctx, err error) error {
+	code := fiber.StatusInternalServerError
+	if e, ok := err.(*fiber.Error); ok {
+		code = e.Code
+	}
+	return c.Status(code).JSON(fiber.Map{
+		"status":  "error",
+		"message": err.Error(),
+		"data":    nil,
 	})
 }
@@ -0,0 +1,279 @@
+package dashboard
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kgermando/appartment-app-api/database"
+	"github.com/kgermando/appartment-app-api/models"
+	"github.com/kgermando/appartment-app-api/utils"
+	"github.com/shopspring/decimal"
+	"github.com/valyala/fasthttp"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB ouvre la base Postgres de TEST_DATABASE_URL et en fait database.DB.
+// La base doit être jetable : ses tables de caisse sont vidées à chaque amorçage.
+// Les tests et benchmarks qui en ont besoin sont ignorés sans elle.
+func openTestDB(tb testing.TB) *gorm.DB {
+	tb.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		tb.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		DisableForeignKeyConstraintWhenMigrating: true,
+		Logger:                                   logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Appartment{}, &models.Caisse{}, &models.CaisseLine{}, &models.DailySummary{}); err != nil {
+		tb.Fatal(err)
+	}
+
+	previous := database.DB
+	database.DB = db
+	tb.Cleanup(func() { database.DB = previous })
+	return db
+}
+
+// dataset est la taille d'une base d'amorçage
+type dataset struct {
+	managers, apartments, entries int
+}
+
+func (d dataset) String() string {
+	return fmt.Sprintf("managers=%d/apartments=%d/entries=%d", d.managers, d.apartments, d.entries)
+}
+
+// seedCaisses remplace les gestionnaires, les appartements et les entrées de la base :
+// les appartements sont répartis entre les gestionnaires, les entrées comptabilisées entre
+// les appartements et les mois de l'année, chacune avec une ligne USD et une ligne CDF.
+// Les résumés journaliers sont ensuite reconstruits.
+func seedCaisses(tb testing.TB, db *gorm.DB, year int, size dataset) {
+	tb.Helper()
+	if err := db.Exec("TRUNCATE users, appartments, caisses, caisse_lines, daily_summaries").Error; err != nil {
+		tb.Fatal(err)
+	}
+
+	for i := 0; i < size.managers; i++ {
+		manager := models.User{
+			UUID:      fmt.Sprintf("manager-%d", i),
+			Fullname:  fmt.Sprintf("Gestionnaire %d", i+1),
+			Email:     fmt.Sprintf("manager-%d@example.com", i),
+			Telephone: fmt.Sprintf("+24381000%04d", i),
+			Role:      "Manager",
+		}
+		if err := db.Create(&manager).Error; err != nil {
+			tb.Fatal(err)
+		}
+	}
+
+	for i := 0; i < size.apartments; i++ {
+		apt := models.Appartment{
+			UUID:        fmt.Sprintf("apt-%d", i),
+			Name:        "Immeuble",
+			Number:      fmt.Sprint(i + 1),
+			ManagerUUID: fmt.Sprintf("manager-%d", i%size.managers),
+			Status:      "occupied",
+		}
+		if err := db.Omit("Manager").Create(&apt).Error; err != nil {
+			tb.Fatal(err)
+		}
+	}
+
+	rate := decimal.NewFromInt(2800)
+	caisses := make([]models.Caisse, 0, size.entries)
+	var lines []models.CaisseLine
+	for i := 0; i < size.entries; i++ {
+		caisseType := "Income"
+		if i%3 == 0 {
+			caisseType = "Expense"
+		}
+		caisse := models.Caisse{
+			UUID:            fmt.Sprintf("caisse-%d", i),
+			AppartmentUUID:  fmt.Sprintf("apt-%d", i%size.apartments),
+			Type:            caisseType,
+			DeviceUSD:       utils.NewAmount(100),
+			DeviceCDF:       utils.NewAmount(28000),
+			ExchangeRate:    rate,
+			Motif:           "Loyer",
+			Signature:       "Agent",
+			TransactionDate: time.Date(year, time.Month(i%12+1), i%28+1, 12, 0, 0, 0, utils.OrgLocation()),
+			Status:          models.CaissePosted,
+		}
+		caisses = append(caisses, caisse)
+		lines = append(lines,
+			models.CaisseLine{UUID: caisse.UUID + "-usd", CaisseUUID: caisse.UUID, Currency: utils.CurrencyUSD,
+				Amount: utils.NewAmount(100), ExchangeRate: decimal.NewFromInt(1)},
+			models.CaisseLine{UUID: caisse.UUID + "-cdf", CaisseUUID: caisse.UUID, Currency: utils.CurrencyCDF,
+				Amount: utils.NewAmount(28000), ExchangeRate: rate},
+		)
+	}
+	if len(caisses) > 0 {
+		if err := db.Omit("Appartment", "Category", "Lines").CreateInBatches(caisses, 500).Error; err != nil {
+			tb.Fatal(err)
+		}
+		if err := db.CreateInBatches(lines, 500).Error; err != nil {
+			tb.Fatal(err)
+		}
+	}
+	if err := models.RebuildDailySummaries(db); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// countQueries compte les requêtes envoyées à la base jusqu'à la fin du test
+func countQueries(tb testing.TB, db *gorm.DB) *atomic.Int64 {
+	tb.Helper()
+	var n atomic.Int64
+	count := func(*gorm.DB) { n.Add(1) }
+	name := "test:count_queries"
+
+	callbacks := db.Callback()
+	if err := callbacks.Query().After("gorm:query").Register(name, count); err != nil {
+		tb.Fatal(err)
+	}
+	if err := callbacks.Row().After("gorm:row").Register(name, count); err != nil {
+		tb.Fatal(err)
+	}
+	if err := callbacks.Raw().After("gorm:raw").Register(name, count); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		callbacks.Query().Remove(name)
+		callbacks.Row().Remove(name)
+		callbacks.Raw().Remove(name)
+	})
+	return &n
+}
+
+// withQuery appelle fn avec un contexte Fiber de la requête GET /?query
+func withQuery(query string, fn func(c *fiber.Ctx)) {
+	app := fiber.New()
+	var rc fasthttp.RequestCtx
+	rc.Request.SetRequestURI("/?" + query)
+	c := app.AcquireCtx(&rc)
+	defer app.ReleaseCtx(c)
+	fn(c)
+}
+
+// Requêtes des agrégations : les résumés journaliers dans le fuseau de l'organisation,
+// les entrées elles-mêmes dans un autre fuseau
+var dashboardQueries = map[string]string{
+	"summaries": "year=2025",
+	"entries":   "year=2025&tz=Pacific/Auckland",
+}
+
+// dashboardHandlers appelle chaque agrégation des tableaux de bord et retourne le total
+// des entrées USD qu'elle a calculé
+var dashboardHandlers = map[string]func(c *fiber.Ctx) (utils.Amount, error){
+	"appartmentStats": func(c *fiber.Ctx) (utils.Amount, error) {
+		response, err := appartmentStats(c)
+		if err != nil {
+			return utils.Zero, err
+		}
+		return response["yearly_totals"].(map[string]utils.Amount)["total_income_usd"], nil
+	},
+	"apartmentRevenues": func(c *fiber.Ctx) (utils.Amount, error) {
+		revenues, err := apartmentRevenues(c)
+		total := utils.Zero
+		for _, revenue := range revenues {
+			total = total.Add(revenue.TotalIncomeUSD)
+		}
+		return total, err
+	},
+	"managerStats": func(c *fiber.Ctx) (utils.Amount, error) {
+		stats, err := managerStats(c)
+		total := utils.Zero
+		for _, stat := range stats {
+			total = total.Add(stat.TotalIncomeUSD)
+		}
+		return total, err
+	},
+	"topManagers": func(c *fiber.Ctx) (utils.Amount, error) {
+		managers, err := topManagers(c)
+		total := utils.Zero
+		for _, manager := range managers {
+			total = total.Add(manager.RevenueUSD)
+		}
+		return total, err
+	},
+	"monthlyTrends": func(c *fiber.Ctx) (utils.Amount, error) {
+		trends, err := monthlyTrends(c)
+		total := utils.Zero
+		for _, trend := range trends {
+			total = total.Add(trend.IncomeUSD)
+		}
+		return total, err
+	},
+}
+
+// Le nombre de requêtes de chaque agrégation ne dépend ni du nombre de gestionnaires,
+// ni du nombre d'appartements, ni du nombre d'entrées
+func TestDashboardQueryCountIsConstant(t *testing.T) {
+	db := openTestDB(t)
+	queries := countQueries(t, db)
+
+	sizes := []dataset{{1, 2, 12}, {5, 40, 1200}}
+	counts := make(map[string][]int64)
+	for _, size := range sizes {
+		seedCaisses(t, db, 2025, size)
+		want := utils.NewAmount(float64(100 * (size.entries - (size.entries+2)/3)))
+
+		for name, handler := range dashboardHandlers {
+			for query, params := range dashboardQueries {
+				key := name + "/" + query
+				queries.Store(0)
+				withQuery(params, func(c *fiber.Ctx) {
+					total, err := handler(c)
+					if err != nil {
+						t.Fatalf("%s, %s: %v", key, size, err)
+					}
+					if !total.Equal(want) {
+						t.Errorf("%s, %s: USD income = %s, want %s", key, size, total, want)
+					}
+				})
+				counts[key] = append(counts[key], queries.Load())
+			}
+		}
+	}
+
+	for key, n := range counts {
+		if n[0] != n[1] {
+			t.Errorf("%s: %d queries for %s, %d for %s", key, n[0], sizes[0], n[1], sizes[1])
+		}
+	}
+}
+
+func BenchmarkDashboard(b *testing.B) {
+	db := openTestDB(b)
+	queries := countQueries(b, db)
+
+	for _, size := range []dataset{{2, 10, 100}, {10, 50, 1000}, {50, 500, 10000}} {
+		seedCaisses(b, db, 2025, size)
+		for name, handler := range dashboardHandlers {
+			for query, params := range dashboardQueries {
+				b.Run(fmt.Sprintf("%s/%s/%s", name, query, size), func(b *testing.B) {
+					queries.Store(0)
+					withQuery(params, func(c *fiber.Ctx) {
+						b.ResetTimer()
+						for i := 0; i < b.N; i++ {
+							if _, err := handler(c); err != nil {
+								b.Fatal(err)
+							}
+						}
+					})
+					b.ReportMetric(float64(queries.Load())/float64(b.N), "queries/op")
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package dashboard
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kgermando/appartment-app-api/utils"
+)
+
+// ExportReport exports a dashboard report (stats, apartment-revenues, manager-stats,
+// monthly-trends, appartments-stats, occupancy-stats, top-managers) as CSV, XLSX or PDF.
+// Accepts the same filters as the JSON endpoints (user_uuid, start_date, end_date, year, currency).
+// PDF exports are limited to utils.MaxPDFRows rows.
+func ExportReport(c *fiber.Ctx) error {
+	report := c.Params("report")
+	format := strings.ToLower(c.Query("format", utils.ExportCSV))
+
+	if !utils.IsExportFormat(format) {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "format must be one of csv, xlsx or pdf",
+			"data":    nil,
+		})
+	}
+
+	var title string
+	var columns []utils.ExportColumn
+	var rows, footers [][]interface{}
+
+	switch report {
+	case "stats":
+		stats, err := dashboardStats(c)
+		if err != nil {
+			return dashboardError(c, err)
+		}
+		title = "Statistiques générales"
+		columns = []utils.ExportColumn{{Title: "Indicateur", Width: 120}, {Title: "Valeur", Width: 60}}
+		rows = [][]interface{}{
+			{"Appartements", stats.TotalAppartments},
+			{"Disponibles", stats.AvailableApartments},
+			{"Occupés", stats.OccupiedApartments},
+			{"En maintenance", stats.MaintenanceApartments},
+			{"Entrées USD", stats.TotalIncomeUSD},
+			{"Entrées CDF", stats.TotalIncomeCDF},
+			{"Sorties USD", stats.TotalExpenseUSD},
+			{"Sorties CDF", stats.TotalExpenseCDF},
+			{"Entrées consolidées " + stats.Currency, stats.TotalIncome},
+			{"Sorties consolidées " + stats.Currency, stats.TotalExpense},
+		}
+		footers = [][]interface{}{
+			{"Solde USD", stats.TotalIncomeUSD.Sub(stats.TotalExpenseUSD)},
+			{"Solde CDF", stats.TotalIncomeCDF.Sub(stats.TotalExpenseCDF)},
+		}
+		// Les autres devises activées suivent USD et CDF
+		for _, cur := range utils.EnabledCurrencies() {
+			if cur == utils.CurrencyUSD || cur == utils.CurrencyCDF {
+				continue
+			}
+			income, expense := stats.IncomeByCurrency[cur], stats.ExpenseByCurrency[cur]
+			rows = append(rows, []interface{}{"Entrées " + cur, income}, []interface{}{"Sorties " + cur, expense})
+			footers = append(footers, []interface{}{"Solde " + cur, income.Sub(expense)})
+		}
+		footers = append(footers, []interface{}{"Solde consolidé " + stats.Currency, stats.TotalIncome.Sub(stats.TotalExpense)})
+
+	case "apartment-revenues":
+		revenues, err := apartmentRevenues(c)
+		if err != nil {
+			return dashboardError(c, err)
+		}
+		title = "Revenus par appartement"
+		columns = []utils.ExportColumn{
+			{Title: "Appartement"}, {Title: "Numéro", Width: 20}, {Title: "Statut", Width: 25}, {Title: "Gestionnaire"},
+			{Title: "Loyer"}, {Title: "Entrées USD"}, {Title: "Entrées CDF"}, {Title: "Sorties USD"}, {Title: "Sorties CDF"},
+			{Title: "Entrées " + currencyTitle(c)}, {Title: "Sorties " + currencyTitle(c)},
+		}
+		var total [6]utils.Amount
+		for _, r := range revenues {
+			rows = append(rows, []interface{}{
+				r.Name, r.Number, r.Status, r.ManagerName, r.MonthlyRent,
+				r.TotalIncomeUSD, r.TotalIncomeCDF, r.TotalExpenseUSD, r.TotalExpenseCDF, r.TotalIncome, r.TotalExpense,
+			})
+			total[0] = total[0].Add(r.TotalIncomeUSD)
+			total[1] = total[1].Add(r.TotalIncomeCDF)
+			total[2] = total[2].Add(r.TotalExpenseUSD)
+			total[3] = total[3].Add(r.TotalExpenseCDF)
+			total[4] = total[4].Add(r.TotalIncome)
+			total[5] = total[5].Add(r.TotalExpense)
+		}
+		footers = [][]interface{}{{"Total", "", "", "", "", total[0], total[1], total[2], total[3], total[4], total[5]}}
+
+	case "manager-stats":
+		stats, err := managerStats(c)
+		if err != nil {
+			return dashboardError(c, err)
+		}
+		title = "Statistiques par gestionnaire"
+		columns = []utils.ExportColumn{
+			{Title: "Gestionnaire"}, {Title: "Appartements", Width: 25}, {Title: "Disponibles", Width: 25}, {Title: "Occupés", Width: 25},
+			{Title: "Entrées USD"}, {Title: "Entrées CDF"}, {Title: "Sorties USD"}, {Title: "Sorties CDF"},
+			{Title: "Entrées " + currencyTitle(c)}, {Title: "Sorties " + currencyTitle(c)},
+		}
+		var total [6]utils.Amount
+		for _, m := range stats {
+			rows = append(rows, []interface{}{
+				m.ManagerName, m.TotalApartments, m.AvailableApartments, m.OccupiedApartments,
+				m.TotalIncomeUSD, m.TotalIncomeCDF, m.TotalExpenseUSD, m.TotalExpenseCDF, m.TotalIncome, m.TotalExpense,
+			})
+			total[0] = total[0].Add(m.TotalIncomeUSD)
+			total[1] = total[1].Add(m.TotalIncomeCDF)
+			total[2] = total[2].Add(m.TotalExpenseUSD)
+			total[3] = total[3].Add(m.TotalExpenseCDF)
+			total[4] = total[4].Add(m.TotalIncome)
+			total[5] = total[5].Add(m.TotalExpense)
+		}
+		footers = [][]interface{}{{"Total", "", "", "", total[0], total[1], total[2], total[3], total[4], total[5]}}
+
+	case "monthly-trends":
+		trends, err := monthlyTrends(c)
+		if err != nil {
+			return dashboardError(c, err)
+		}
+		title = "Tendances mensuelles"
+		columns = []utils.ExportColumn{
+			{Title: "Mois"}, {Title: "Année"}, {Title: "Entrées USD"}, {Title: "Entrées CDF"}, {Title: "Sorties USD"}, {Title: "Sorties CDF"},
+			{Title: "Entrées " + currencyTitle(c)}, {Title: "Sorties " + currencyTitle(c)}, {Title: "Solde " + currencyTitle(c)},
+		}
+		var total [6]utils.Amount
+		for _, t := range trends {
+			rows = append(rows, []interface{}{
+				t.Month, t.Year, t.IncomeUSD, t.IncomeCDF, t.ExpenseUSD, t.ExpenseCDF, t.Income, t.Expense, t.Income.Sub(t.Expense),
+			})
+			total[0] = total[0].Add(t.IncomeUSD)
+			total[1] = total[1].Add(t.IncomeCDF)
+			total[2] = total[2].Add(t.ExpenseUSD)
+			total[3] = total[3].Add(t.ExpenseCDF)
+			total[4] = total[4].Add(t.Income)
+			total[5] = total[5].Add(t.Expense)
+		}
+		footers = [][]interface{}{{"Total", "", total[0], total[1], total[2], total[3], total[4], total[5], total[4].Sub(total[5])}}
+
+	case "appartments-stats":
+		response, err := appartmentStats(c)
+		if err != nil {
+			return dashboardError(c, err)
+		}
+		title = "Paiements mensuels des appartements"
+		columns = []utils.ExportColumn{
+			{Title: "Mois"}, {Title: "Entrées USD"}, {Title: "Entrées CDF"}, {Title: "Sorties USD"}, {Title: "Sorties CDF"},
+			{Title: "Entrées " + currencyTitle(c)}, {Title: "Sorties " + currencyTitle(c)},
+		}
+		monthlyStats := response["monthly_stats"].(map[string]map[string]utils.Amount)
+		for _, month := range []string{"January", "February", "March", "April", "May", "June",
+			"July", "August", "September", "October", "November", "December"} {
+			m := monthlyStats[month]
+			rows = append(rows, []interface{}{month, m["income_usd"], m["income_cdf"], m["expense_usd"], m["expense_cdf"], m["income"], m["expense"]})
+		}
+		totals := response["yearly_totals"].(map[string]utils.Amount)
+		footers = [][]interface{}{{
+			"Total", totals["total_income_usd"], totals["total_income_cdf"], totals["total_expense_usd"], totals["total_expense_cdf"],
+			totals["total_income"], totals["total_expense"],
+		}}
+
+	case "occupancy-stats":
+		stats, err := occupancyStats(c)
+		if err != nil {
+			return dashboardError(c, err)
+		}
+		title = "Statistiques d'occupation"
+		columns = []utils.ExportColumn{{Title: "Indicateur", Width: 120}, {Title: "Valeur", Width: 60}}
+		rows = [][]interface{}{
+			{"Appartements", stats.TotalApartments},
+			{"Occupés", stats.OccupiedApartments},
+			{"Disponibles", stats.AvailableApartments},
+			{"En maintenance", stats.MaintenanceApartments},
+			{"Taux d'occupation (%)", stats.OccupancyRate},
+			{"Taux de disponibilité (%)", stats.AvailabilityRate},
+			{"Loyer moyen", stats.AverageRent},
+			{"Revenu potentiel", stats.TotalPotentialRevenue},
+			{"Revenu perdu", stats.LostRevenue},
+		}
+
+	case "top-managers":
+		managers, err := topManagers(c)
+		if err != nil {
+			return dashboardError(c, err)
+		}
+		title = "Classement des gestionnaires"
+		columns = []utils.ExportColumn{
+			{Title: "Gestionnaire"}, {Title: "Appartements"}, {Title: "Revenu USD"}, {Title: "Revenu CDF"},
+			{Title: "Revenu " + currencyTitle(c)}, {Title: "Bénéfice net " + currencyTitle(c)},
+			{Title: "Occupation (%)"}, {Title: "Efficacité"},
+		}
+		for _, m := range managers {
+			rows = append(rows, []interface{}{
+				m.ManagerName, m.ApartmentCount, m.RevenueUSD, m.RevenueCDF, m.TotalRevenue, m.NetProfit, m.OccupancyRate, m.Efficiency,
+			})
+		}
+
+	default:
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unknown report '" + report + "'",
+			"data":    nil,
+		})
+	}
+
+	// Les rapports sont agrégés en SQL, une ligne par appartement, gestionnaire ou mois :
+	// ils sont envoyés par le même chemin que le livre de caisse
+	if err := utils.CheckExportSize(format, int64(len(rows))); err != nil {
+		return dashboardError(c, err)
+	}
+	err := utils.StreamTable(c, format, report, title, exportSubtitle(c), columns, func(table utils.TableWriter) error {
+		for _, row := range rows {
+			if err := table.WriteRow(row); err != nil {
+				return err
+			}
+		}
+		for _, footer := range footers {
+			if err := table.WriteFooter(footer); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return dashboardError(c, err)
+	}
+	return nil
+}
+
+// currencyTitle retourne la devise de consolidation pour les titres de colonnes
+func currencyTitle(c *fiber.Ctx) string {
+	return strings.ToUpper(c.Query("currency", utils.CurrencyUSD))
+}
+
+// exportSubtitle décrit les filtres appliqués, affiché sous le titre du PDF
+func exportSubtitle(c *fiber.Ctx) string {
+	var parts []string
+	if v := c.Query("start_date"); v != "" {
+		parts = append(parts, "Du "+v)
+	}
+	if v := c.Query("end_date"); v != "" {
+		parts = append(parts, "au "+v)
+	}
+	if v := c.Query("year"); v != "" {
+		parts = append(parts, "Année "+v)
+	}
+	return strings.Join(parts, " ")
+}
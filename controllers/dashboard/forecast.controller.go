@@ -0,0 +1,442 @@
+package dashboard
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kgermando/appartment-app-api/database"
+	"github.com/kgermando/appartment-app-api/models"
+	"github.com/kgermando/appartment-app-api/utils"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// Cash-flow forecast for the next months, per month and currency.
+// Income: the rent due on active leases (or, for an occupied apartment without a lease,
+// its monthly rent on its echeance day), reduced by the tenant's historical collection rate
+// and shifted by the tenant's average delay. Expenses: the occurrences of the active recurring
+// expenses, plus the monthly average of each category over the past months, entries created
+// from a recurring expense excepted.
+// Filters: months (6 by default, up to 24), history_months (6 by default, up to 24), user_uuid, tz.
+func GetCashFlowForecast(c *fiber.Ctx) error {
+	data, err := cashFlowForecast(c)
+	if err != nil {
+		return dashboardError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Cash-flow forecast retrieved successfully",
+		"data":    data,
+	})
+}
+
+// Sources des sorties projetées
+const (
+	forecastFromHistory   = "history"
+	forecastFromRecurring = "recurring"
+)
+
+// tenantBehaviour résume les paiements passés d'un locataire
+type tenantBehaviour struct {
+	expected decimal.Decimal // Échéances passées
+	paid     decimal.Decimal // Loyers encaissés pendant ses baux
+	delays   int64           // Somme des jours de retard des échéances couvertes
+	covered  int64           // Nombre d'échéances couvertes
+}
+
+// rate retourne la part des loyers dus effectivement encaissée, entre 0 et 1, 1 sans historique
+func (b *tenantBehaviour) rate() decimal.Decimal {
+	if b == nil || !b.expected.IsPositive() {
+		return decimal.NewFromInt(1)
+	}
+	rate := b.paid.Div(b.expected)
+	if rate.GreaterThan(decimal.NewFromInt(1)) {
+		return decimal.NewFromInt(1)
+	}
+	if rate.IsNegative() {
+		return decimal.Zero
+	}
+	return rate
+}
+
+// delay retourne le retard moyen de paiement en jours, 0 sans historique
+func (b *tenantBehaviour) delay() int {
+	if b == nil || b.covered == 0 {
+		return 0
+	}
+	return int(math.Round(float64(b.delays) / float64(b.covered)))
+}
+
+// rentPayment est un loyer encaissé, consolidé en USD
+type rentPayment struct {
+	date   time.Time
+	amount decimal.Decimal
+}
+
+// forecastMonths lit un nombre de mois entre 1 et 24, def par défaut
+func forecastMonths(c *fiber.Ctx, key string, def int) (int, error) {
+	value := c.Query(key)
+	if value == "" {
+		return def, nil
+	}
+	months, err := strconv.Atoi(value)
+	if err != nil || months < 1 || months > 24 {
+		return 0, fiber.NewError(400, key+" must be a number of months between 1 and 24")
+	}
+	return months, nil
+}
+
+// cashFlowForecast projette les entrées et les sorties des prochains mois
+func cashFlowForecast(c *fiber.Ctx) (map[string]interface{}, error) {
+	db := database.DB
+
+	filter, err := utils.ParseQueryFilter(c, 0)
+	if err != nil {
+		return nil, err
+	}
+	months, err := forecastMonths(c, "months", 6)
+	if err != nil {
+		return nil, err
+	}
+	historyMonths, err := forecastMonths(c, "history_months", 6)
+	if err != nil {
+		return nil, err
+	}
+
+	// Les mois complets : l'historique finit au début du mois en cours, la projection commence le mois suivant
+	today := utils.StartOfDay(time.Now(), filter.Location)
+	monthStart := today.AddDate(0, 0, 1-today.Day())
+	historyStart := monthStart.AddDate(0, -historyMonths, 0)
+	start := monthStart.AddDate(0, 1, 0)
+	end := start.AddDate(0, months, 0)
+
+	forecast := make([]models.CashFlowForecast, months)
+	for i := range forecast {
+		monthStart := start.AddDate(0, i, 0)
+		forecast[i] = models.CashFlowForecast{
+			Month:             monthStart.Format("2006-01"),
+			Start:             monthStart,
+			End:               monthStart.AddDate(0, 1, 0),
+			IncomeByCurrency:  make(map[string]utils.Amount),
+			ExpenseByCurrency: make(map[string]utils.Amount),
+			Expenses:          []models.ForecastExpense{},
+		}
+		for _, currency := range utils.EnabledCurrencies() {
+			forecast[i].IncomeByCurrency[currency] = utils.Zero
+			forecast[i].ExpenseByCurrency[currency] = utils.Zero
+		}
+	}
+	// monthOf retourne le mois projeté d'une date, -1 hors de la projection
+	monthOf := func(date time.Time) int {
+		if date.Before(start) || !date.Before(end) {
+			return -1
+		}
+		date = date.In(filter.Location)
+		return (date.Year()-start.Year())*12 + int(date.Month()-start.Month())
+	}
+
+	if err := forecastIncome(db, filter, historyStart, today, start, end, func(date time.Time, amount decimal.Decimal) {
+		if month := monthOf(date); month >= 0 {
+			income := forecast[month].IncomeByCurrency
+			income[utils.CurrencyUSD] = income[utils.CurrencyUSD].Add(utils.AmountFromDecimal(amount))
+		}
+	}); err != nil {
+		return nil, err
+	}
+
+	expenses, err := historicalExpenses(db, filter.ManagerUUID, historyStart, monthStart, historyMonths)
+	if err != nil {
+		return nil, err
+	}
+	for i := range forecast {
+		forecast[i].Expenses = append(forecast[i].Expenses, expenses...)
+	}
+	if err := recurringExpenses(db, filter.ManagerUUID, start, end, func(date time.Time, expense models.ForecastExpense) {
+		if month := monthOf(date); month >= 0 {
+			forecast[month].Expenses = append(forecast[month].Expenses, expense)
+		}
+	}); err != nil {
+		return nil, err
+	}
+	for i := range forecast {
+		month := &forecast[i]
+		for _, expense := range month.Expenses {
+			month.ExpenseByCurrency[expense.Currency] = month.ExpenseByCurrency[expense.Currency].Add(expense.Amount)
+		}
+	}
+
+	// Solde de chaque mois et de la projection
+	totalIncome := make(map[string]utils.Amount)
+	totalExpense := make(map[string]utils.Amount)
+	for i := range forecast {
+		month := &forecast[i]
+		for currency, income := range month.IncomeByCurrency {
+			totalIncome[currency] = totalIncome[currency].Add(income)
+		}
+		for currency, expense := range month.ExpenseByCurrency {
+			totalExpense[currency] = totalExpense[currency].Add(expense)
+		}
+		month.NetByCurrency = netByCurrency(month.IncomeByCurrency, month.ExpenseByCurrency)
+	}
+
+	return map[string]interface{}{
+		"start":          start,
+		"end":            end,
+		"months":         months,
+		"history_months": historyMonths,
+		"history_start":  historyStart,
+		"forecast":       forecast,
+		"total": map[string]interface{}{
+			"income_by_currency":  totalIncome,
+			"expense_by_currency": totalExpense,
+			"net_by_currency":     netByCurrency(totalIncome, totalExpense),
+		},
+	}, nil
+}
+
+// netByCurrency retourne les entrées moins les sorties de chaque devise
+func netByCurrency(income, expense map[string]utils.Amount) map[string]utils.Amount {
+	net := make(map[string]utils.Amount, len(income))
+	for currency, amount := range income {
+		net[currency] = amount.Sub(expense[currency])
+	}
+	for currency, amount := range expense {
+		if _, ok := income[currency]; !ok {
+			net[currency] = amount.Neg()
+		}
+	}
+	return net
+}
+
+// forecastIncome projette les loyers des appartements du filtre sur [start, end[ : chaque échéance
+// d'un bail actif, réduite du taux d'encaissement du locataire sur [historyStart, today[ et décalée
+// de son retard moyen, ou chaque mois le loyer d'un appartement occupé sans bail, au jour de son échéance
+func forecastIncome(db *gorm.DB, filter *utils.QueryFilter, historyStart, today, start, end time.Time, add func(time.Time, decimal.Decimal)) error {
+	var apartments []models.Appartment
+	if err := filter.ApplyManager(db.Model(&models.Appartment{}), "manager_uuid").
+		Find(&apartments).Error; err != nil {
+		return fiber.NewError(500, "Failed to fetch apartments: "+err.Error())
+	}
+
+	behaviours, err := tenantBehaviours(db, historyStart, today, filter.Location)
+	if err != nil {
+		return err
+	}
+
+	// Baux actifs pendant la projection, un décalage pouvant reporter une échéance antérieure
+	var maxDelay int
+	for _, behaviour := range behaviours {
+		maxDelay = max(maxDelay, behaviour.delay())
+	}
+	leases, err := periodLeases(db, start.AddDate(0, 0, -maxDelay), end)
+	if err != nil {
+		return err
+	}
+
+	for _, apt := range apartments {
+		active := false
+		for _, lease := range leases[apt.UUID] {
+			if lease.Status != models.LeaseActive {
+				continue
+			}
+			active = true
+			behaviour := behaviours[lease.TenantUUID]
+			delay := behaviour.delay()
+			rent := lease.MonthlyRent.Decimal().Mul(behaviour.rate())
+			for _, due := range rentDueDates(lease, start.AddDate(0, 0, -delay), end.AddDate(0, 0, -delay), filter.Location) {
+				add(due.AddDate(0, 0, delay), rent)
+			}
+		}
+
+		// Appartement occupé sans bail enregistré : le loyer de l'appartement, chaque mois au jour de l'échéance
+		if !active && apt.Status == "occupied" {
+			first := apt.Echeance
+			if first.IsZero() {
+				first = start
+			}
+			legacy := models.Lease{StartDate: first, MonthlyRent: apt.MonthlyRent}
+			for _, due := range rentDueDates(legacy, start, end, filter.Location) {
+				add(due, apt.MonthlyRent.Decimal())
+			}
+		}
+	}
+	return nil
+}
+
+// tenantBehaviours mesure pour chaque locataire, quel que soit le gestionnaire de l'appartement, sur les échéances de ses baux comprises dans
+// [start, end[, la part des loyers encaissée et le retard moyen : les encaissements de
+// l'appartement pendant le bail couvrent les échéances dans l'ordre, une échéance couverte
+// l'étant à la date de l'encaissement qui la solde
+func tenantBehaviours(db *gorm.DB, start, end time.Time, loc *time.Location) (map[string]*tenantBehaviour, error) {
+	leases, err := periodLeases(db, start, end)
+	if err != nil {
+		return nil, err
+	}
+	rentCategories, err := rentCategoryUUIDs(db)
+	if err != nil {
+		return nil, err
+	}
+
+	// Loyers encaissés de la période, par appartement et par date
+	rows, err := rentPayments(postedCaisses(db, "", &start, &end), rentCategories).
+		Joins("JOIN caisse_lines ON caisse_lines.caisse_uuid = caisses.uuid").
+		Select("caisses.appartment_uuid, caisses.transaction_date, " + consolidatedSQL(utils.CurrencyUSD)).
+		Group("caisses.uuid, caisses.appartment_uuid, caisses.transaction_date").
+		Order("caisses.transaction_date").
+		Rows()
+	if err != nil {
+		return nil, fiber.NewError(500, "Failed to fetch rent payments: "+err.Error())
+	}
+	defer rows.Close()
+
+	payments := make(map[string][]rentPayment)
+	var missing int64
+	for rows.Next() {
+		var appartmentUUID string
+		var payment rentPayment
+		var amount utils.Amount
+		var rowMissing int64
+		if err := rows.Scan(&appartmentUUID, &payment.date, &amount, &rowMissing); err != nil {
+			return nil, fiber.NewError(500, "Failed to fetch rent payments: "+err.Error())
+		}
+		missing += rowMissing
+		payment.amount = amount.Decimal()
+		payments[appartmentUUID] = append(payments[appartmentUUID], payment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fiber.NewError(500, "Failed to fetch rent payments: "+err.Error())
+	}
+	if missing > 0 {
+		return nil, missingRateError(missing, utils.CurrencyUSD)
+	}
+
+	behaviours := make(map[string]*tenantBehaviour)
+	for appartmentUUID, apartmentLeases := range leases {
+		for _, lease := range apartmentLeases {
+			dues := rentDueDates(lease, start, end, loc)
+			if len(dues) == 0 {
+				continue
+			}
+			behaviour, ok := behaviours[lease.TenantUUID]
+			if !ok {
+				behaviour = &tenantBehaviour{}
+				behaviours[lease.TenantUUID] = behaviour
+			}
+
+			// Encaissements de l'appartement pendant le bail, dans l'ordre des dates
+			var leasePayments []rentPayment
+			leaseEnd := leaseEnd(lease)
+			for _, payment := range payments[appartmentUUID] {
+				if payment.date.Before(lease.StartDate) || (leaseEnd != nil && !payment.date.Before(*leaseEnd)) {
+					continue
+				}
+				leasePayments = append(leasePayments, payment)
+			}
+
+			rent := lease.MonthlyRent.Decimal()
+			paid := decimal.Zero
+			next := 0
+			for i, due := range dues {
+				owed := rent.Mul(decimal.NewFromInt(int64(i + 1)))
+				var settled time.Time
+				for next < len(leasePayments) && paid.LessThan(owed) {
+					paid = paid.Add(leasePayments[next].amount)
+					settled = leasePayments[next].date
+					next++
+				}
+				if paid.LessThan(owed) {
+					break
+				}
+				behaviour.covered++
+				if !settled.IsZero() && settled.After(due) {
+					behaviour.delays += periodDays(due, utils.StartOfDay(settled, loc))
+				}
+			}
+			for ; next < len(leasePayments); next++ {
+				paid = paid.Add(leasePayments[next].amount)
+			}
+
+			behaviour.expected = behaviour.expected.Add(rent.Mul(decimal.NewFromInt(int64(len(dues)))))
+			behaviour.paid = behaviour.paid.Add(paid)
+		}
+	}
+	return behaviours, nil
+}
+
+// historicalExpenses retourne la moyenne mensuelle des sorties comptabilisées de [start, end[
+// par catégorie et par devise, sur le nombre de mois donné. Les entrées créées depuis une
+// sortie récurrente sont exclues : ses échéances sont projetées par recurringExpenses.
+func historicalExpenses(db *gorm.DB, userUUID string, start, end time.Time, months int) ([]models.ForecastExpense, error) {
+	var rows []struct {
+		CategoryUUID string
+		CategoryName string
+		Currency     string
+		Amount       utils.Amount
+	}
+	if err := postedCaisses(db, userUUID, &start, &end).
+		Joins("JOIN caisse_lines ON caisse_lines.caisse_uuid = caisses.uuid").
+		Joins("LEFT JOIN categories ON categories.uuid = caisses.category_uuid").
+		Where("caisses.type = ?", "Expense").
+		Where("COALESCE(caisses.recurring_expense_uuid, '') = ''").
+		Select("COALESCE(caisses.category_uuid, '') AS category_uuid, COALESCE(categories.name, '') AS category_name, " +
+			"caisse_lines.currency, COALESCE(SUM(caisse_lines.amount), 0) AS amount").
+		Group("caisses.category_uuid, categories.name, caisse_lines.currency").
+		Order("category_name, caisse_lines.currency").
+		Scan(&rows).Error; err != nil {
+		return nil, fiber.NewError(500, "Failed to compute expense averages: "+err.Error())
+	}
+
+	expenses := make([]models.ForecastExpense, 0, len(rows))
+	for _, row := range rows {
+		if row.Amount.IsZero() {
+			continue
+		}
+		expenses = append(expenses, models.ForecastExpense{
+			CategoryUUID: row.CategoryUUID,
+			CategoryName: row.CategoryName,
+			Currency:     row.Currency,
+			Amount:       row.Amount.Div(decimal.NewFromInt(int64(months))),
+			Source:       forecastFromHistory,
+		})
+	}
+	return expenses, nil
+}
+
+// recurringExpenses projette chaque échéance de [start, end[ des sorties récurrentes actives
+// des appartements du gestionnaire si userUUID est renseigné, une sortie par devise
+func recurringExpenses(db *gorm.DB, userUUID string, start, end time.Time, add func(time.Time, models.ForecastExpense)) error {
+	query := db.Joins("JOIN appartments ON appartments.uuid = recurring_expenses.appartment_uuid").
+		Where("recurring_expenses.active = ?", true)
+	if userUUID != "" {
+		query = query.Where("appartments.manager_uuid = ?", userUUID)
+	}
+	var templates []models.RecurringExpense
+	if err := query.Preload("Category").Preload("Lines").Find(&templates).Error; err != nil {
+		return fiber.NewError(500, "Failed to fetch recurring expenses: "+err.Error())
+	}
+
+	for _, template := range templates {
+		for n := template.Occurrences; ; n++ {
+			date := template.Occurrence(n)
+			if !date.Before(end) || template.Ended(date) {
+				break
+			}
+			if date.Before(start) {
+				continue
+			}
+			for _, line := range template.Lines {
+				add(date, models.ForecastExpense{
+					CategoryUUID: template.CategoryUUID,
+					CategoryName: template.Category.Name,
+					Currency:     line.Currency,
+					Amount:       line.Amount,
+					Source:       forecastFromRecurring,
+				})
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package dashboard
+
+import (
+	"math"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kgermando/appartment-app-api/models"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// Les loyers des baux sont exprimés en USD.
+
+// rentCategoryUUIDs retourne la catégorie Loyer et ses sous-catégories
+func rentCategoryUUIDs(db *gorm.DB) ([]string, error) {
+	var rent models.Category
+	if err := db.Where("code = ?", models.CategoryRent).Limit(1).Find(&rent).Error; err != nil {
+		return nil, fiber.NewError(500, "Failed to fetch the rent category: "+err.Error())
+	}
+	if rent.UUID == "" {
+		return nil, nil
+	}
+	uuids, err := models.CategoryDescendants(db, rent.UUID)
+	if err != nil {
+		return nil, fiber.NewError(500, "Failed to fetch the rent category: "+err.Error())
+	}
+	return uuids, nil
+}
+
+// rentPayments limite les entrées aux loyers encaissés : entrées de la catégorie Loyer,
+// toutes les entrées si la catégorie n'existe pas
+func rentPayments(query *gorm.DB, rentCategories []string) *gorm.DB {
+	query = query.Where("caisses.type = ?", "Income")
+	if len(rentCategories) > 0 {
+		query = query.Where("caisses.category_uuid IN ?", rentCategories)
+	}
+	return query
+}
+
+// periodLeases retourne par appartement les baux signés en cours pendant la période [start, end[,
+// la date de fin d'un bail étant son dernier jour
+func periodLeases(db *gorm.DB, start, end time.Time) (map[string][]models.Lease, error) {
+	var leases []models.Lease
+	err := db.Preload("Tenant").
+		Where("status IN ?", []string{models.LeaseActive, models.LeaseTerminated}).
+		Where("start_date < ? AND (end_date IS NULL OR end_date >= ?)", end, start).
+		Order("start_date").
+		Find(&leases).Error
+	if err != nil {
+		return nil, fiber.NewError(500, "Failed to fetch leases: "+err.Error())
+	}
+
+	byApartment := make(map[string][]models.Lease)
+	for _, lease := range leases {
+		byApartment[lease.AppartmentUUID] = append(byApartment[lease.AppartmentUUID], lease)
+	}
+	return byApartment, nil
+}
+
+// periodDays retourne le nombre de jours de [start, end[, un jour de changement d'heure comptant pour un
+func periodDays(start, end time.Time) int64 {
+	return int64(math.Round(end.Sub(start).Hours() / 24))
+}
+
+// leaseEnd retourne la fin exclue du bail, le lendemain de son dernier jour, nil s'il est sans fin
+func leaseEnd(lease models.Lease) *time.Time {
+	if lease.EndDate == nil {
+		return nil
+	}
+	end := lease.EndDate.AddDate(0, 0, 1)
+	return &end
+}
+
+// leaseDays retourne le nombre de jours de la période [start, end[ couverts par le bail
+func leaseDays(lease models.Lease, start, end time.Time) int64 {
+	from, to := start, end
+	if lease.StartDate.After(from) {
+		from = lease.StartDate
+	}
+	if leaseEnd := leaseEnd(lease); leaseEnd != nil && leaseEnd.Before(to) {
+		to = *leaseEnd
+	}
+	if days := periodDays(from, to); days > 0 {
+		return days
+	}
+	return 0
+}
+
+// proratedRent retourne le loyer du bail pour un nombre de jours, au prorata du loyer annuel
+func proratedRent(lease models.Lease, days int64) decimal.Decimal {
+	return lease.MonthlyRent.Decimal().Mul(decimal.NewFromInt(days * 12)).Div(decimal.NewFromInt(365))
+}
+
+// rentDueDates retourne les échéances du bail comprises dans la période [start, end[ :
+// chaque mois au jour anniversaire de son début, le dernier jour du mois pour les mois plus courts
+func rentDueDates(lease models.Lease, start, end time.Time, loc *time.Location) []time.Time {
+	first := lease.StartDate.In(loc)
+	limit := end
+	if leaseEnd := leaseEnd(lease); leaseEnd != nil && leaseEnd.Before(limit) {
+		limit = *leaseEnd
+	}
+
+	var dates []time.Time
+	for month := 0; ; month++ {
+		monthStart := time.Date(first.Year(), first.Month()+time.Month(month), 1, 0, 0, 0, 0, loc)
+		day := min(first.Day(), monthStart.AddDate(0, 1, -1).Day())
+		due := monthStart.AddDate(0, 0, day-1)
+		if !due.Before(limit) {
+			return dates
+		}
+		if !due.Before(start) {
+			dates = append(dates, due)
+		}
+	}
+}
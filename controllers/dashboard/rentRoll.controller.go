@@ -0,0 +1,190 @@
+package dashboard
+
+import (
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kgermando/appartment-app-api/database"
+	"github.com/kgermando/appartment-app-api/models"
+	"github.com/kgermando/appartment-app-api/utils"
+	"github.com/shopspring/decimal"
+)
+
+// Rent roll: for each apartment the rent expected over the period, the rent collected,
+// the balance, the last payment and the days late, with the collection rate per manager,
+// per building and in total. Amounts are in USD.
+// Filters: start_date, end_date (the current month by default), user_uuid, tz.
+func GetRentRoll(c *fiber.Ctx) error {
+	data, err := rentRoll(c)
+	if err != nil {
+		return dashboardError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Rent roll retrieved successfully",
+		"data":    data,
+	})
+}
+
+// rentRoll calcule le rôle des loyers de la période
+func rentRoll(c *fiber.Ctx) (map[string]interface{}, error) {
+	db := database.DB
+
+	filter, err := utils.ParseQueryFilter(c, 0)
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := currentPeriod(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var apartments []models.Appartment
+	if err := filter.ApplyManager(db.Preload("Manager"), "manager_uuid").
+		Order("name, number").
+		Find(&apartments).Error; err != nil {
+		return nil, fiber.NewError(500, "Failed to fetch apartments: "+err.Error())
+	}
+
+	leases, err := periodLeases(db, start, end)
+	if err != nil {
+		return nil, err
+	}
+	rentCategories, err := rentCategoryUUIDs(db)
+	if err != nil {
+		return nil, err
+	}
+
+	// Loyers encaissés pendant la période, par appartement
+	collected, err := groupedCaisseTotals(rentPayments(postedCaisses(db, filter.ManagerUUID, &start, &end), rentCategories),
+		caisseGroups[byAppartment], utils.CurrencyUSD)
+	if err != nil {
+		return nil, err
+	}
+
+	// Dernier paiement de chaque appartement jusqu'à la fin de la période, hors contre-passations
+	var payments []struct {
+		AppartmentUUID string
+		LastPayment    time.Time
+	}
+	if err := rentPayments(postedCaisses(db, filter.ManagerUUID, nil, &end), rentCategories).
+		Where("COALESCE(caisses.reversal_of_uuid, '') = ''").
+		Select("caisses.appartment_uuid, MAX(caisses.transaction_date) AS last_payment").
+		Group("caisses.appartment_uuid").
+		Scan(&payments).Error; err != nil {
+		return nil, fiber.NewError(500, "Failed to fetch payments: "+err.Error())
+	}
+	lastPayments := make(map[string]time.Time, len(payments))
+	for _, payment := range payments {
+		lastPayments[payment.AppartmentUUID] = payment.LastPayment
+	}
+
+	// Retards mesurés aujourd'hui, ou à la fin d'une période passée
+	asOf := time.Now()
+	if end.Before(asOf) {
+		asOf = end
+	}
+
+	lines := make([]models.RentRollLine, 0, len(apartments))
+	for _, apt := range apartments {
+		line := models.RentRollLine{
+			AppartmentUUID: apt.UUID,
+			Building:       apt.Name,
+			Number:         apt.Number,
+			ManagerUUID:    apt.ManagerUUID,
+			ManagerName:    apt.Manager.Fullname,
+		}
+		if totals, ok := collected[apt.UUID]; ok {
+			line.Collected = totals.TotalIncome
+		}
+
+		// Échéances de tous les baux de la période, dans l'ordre
+		type dueRent struct {
+			date   time.Time
+			amount decimal.Decimal
+		}
+		var dues []dueRent
+		for _, lease := range leases[apt.UUID] {
+			line.TenantName = lease.Tenant.Fullname
+			line.MonthlyRent = lease.MonthlyRent
+			for _, date := range rentDueDates(lease, start, end, filter.Location) {
+				dues = append(dues, dueRent{date: date, amount: lease.MonthlyRent.Decimal()})
+			}
+		}
+		sort.Slice(dues, func(i, j int) bool { return dues[i].date.Before(dues[j].date) })
+
+		// Les encaissements couvrent les échéances dans l'ordre : la première non couverte date le retard
+		expected := decimal.Zero
+		for _, due := range dues {
+			expected = expected.Add(due.amount)
+			if line.DaysLate == 0 && expected.GreaterThan(line.Collected.Decimal()) && due.date.Before(asOf) {
+				line.DaysLate = periodDays(utils.StartOfDay(due.date, filter.Location), utils.StartOfDay(asOf, filter.Location))
+			}
+		}
+		line.ExpectedRent = utils.AmountFromDecimal(expected)
+		line.Balance = line.ExpectedRent.Sub(line.Collected)
+		line.CollectionRate = line.Collected.Percent(line.ExpectedRent)
+		if lastPayment, ok := lastPayments[apt.UUID]; ok {
+			lastPayment = lastPayment.In(filter.Location)
+			line.LastPaymentDate = &lastPayment
+		}
+		lines = append(lines, line)
+	}
+
+	byManager := rentRollTotals(lines, func(line models.RentRollLine) (string, string) {
+		return line.ManagerUUID, line.ManagerName
+	})
+	byBuilding := rentRollTotals(lines, func(line models.RentRollLine) (string, string) {
+		return line.Building, line.Building
+	})
+	total := rentRollTotals(lines, func(models.RentRollLine) (string, string) { return "", "" })
+
+	response := map[string]interface{}{
+		"currency":    utils.CurrencyUSD,
+		"start":       start,
+		"end":         end,
+		"lines":       lines,
+		"by_manager":  byManager,
+		"by_building": byBuilding,
+		"total":       models.RentRollTotal{},
+	}
+	if len(total) > 0 {
+		response["total"] = total[0]
+	}
+	return response, nil
+}
+
+// rentRollTotals totalise les lignes par groupe, triés par nom
+func rentRollTotals(lines []models.RentRollLine, group func(models.RentRollLine) (string, string)) []models.RentRollTotal {
+	totals := make(map[string]*models.RentRollTotal)
+	for _, line := range lines {
+		key, name := group(line)
+		total, ok := totals[key]
+		if !ok {
+			total = &models.RentRollTotal{Key: key, Name: name}
+			totals[key] = total
+		}
+		total.Apartments++
+		if line.DaysLate > 0 {
+			total.LateApartments++
+		}
+		total.ExpectedRent = total.ExpectedRent.Add(line.ExpectedRent)
+		total.Collected = total.Collected.Add(line.Collected)
+	}
+
+	result := make([]models.RentRollTotal, 0, len(totals))
+	for _, total := range totals {
+		total.Balance = total.ExpectedRent.Sub(total.Collected)
+		total.CollectionRate = total.Collected.Percent(total.ExpectedRent)
+		result = append(result, *total)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Name != result[j].Name {
+			return result[i].Name < result[j].Name
+		}
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
@@ -0,0 +1,50 @@
+package dashboard
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/kgermando/appartment-app-api/database"
+	"github.com/kgermando/appartment-app-api/models"
+)
+
+// Compare the daily summaries read by the dashboards with the cash entries
+func CheckDailySummaries(c *fiber.Ctx) error {
+	mismatches, err := models.CheckDailySummaries(database.DB)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to check daily summaries",
+			"error":   err.Error(),
+		})
+	}
+
+	message := "Daily summaries match the cash entries"
+	if len(mismatches) > 0 {
+		message = "Daily summaries differ from the cash entries, rebuild them"
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": message,
+		"data": fiber.Map{
+			"consistent": len(mismatches) == 0,
+			"mismatches": mismatches,
+		},
+	})
+}
+
+// Rebuild all daily summaries from the cash entries
+func RebuildDailySummaries(c *fiber.Ctx) error {
+	if err := models.RebuildDailySummaries(database.DB); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to rebuild daily summaries",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Daily summaries rebuilt success",
+		"data":    nil,
+	})
+}
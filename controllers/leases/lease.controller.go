@@ -0,0 +1,249 @@
+package leases
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kgermando/appartment-app-api/database"
+	"github.com/kgermando/appartment-app-api/models"
+	"github.com/kgermando/appartment-app-api/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func GetAllLeasesByAppartmentUUID(c *fiber.Ctx) error {
+	db := database.DB
+	appartmentUUID := c.Params("appartment_uuid")
+
+	var leases []models.Lease
+	db.Where("appartment_uuid = ?", appartmentUUID).
+		Order("start_date DESC").
+		Preload("Tenant").
+		Find(&leases)
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "All leases",
+		"data":    leases,
+	})
+}
+
+// Get one data
+func GetLease(c *fiber.Ctx) error {
+	uuid := c.Params("uuid")
+	db := database.DB
+	var lease models.Lease
+	db.Where("uuid = ?", uuid).Preload("Appartment").Preload("Tenant").First(&lease)
+	if lease.UUID == "" {
+		return c.Status(404).JSON(
+			fiber.Map{
+				"status":  "error",
+				"message": "No Lease found",
+				"data":    nil,
+			},
+		)
+	}
+	return c.JSON(
+		fiber.Map{
+			"status":  "success",
+			"message": "Lease found",
+			"data":    lease,
+		},
+	)
+}
+
+// Update the terms of a draft lease
+func UpdateLease(c *fiber.Ctx) error {
+	uuid := c.Params("uuid")
+	db := database.DB
+
+	type UpdateDataInput struct {
+		StartDate     time.Time    `json:"start_date"`
+		EndDate       *time.Time   `json:"end_date"`
+		MonthlyRent   utils.Amount `json:"monthly_rent"`
+		GarantieMonth float64      `json:"garantie_month"`
+		Garantie      utils.Amount `json:"garantie_montant"`
+	}
+
+	var updateData UpdateDataInput
+
+	if err := c.BodyParser(&updateData); err != nil {
+		return c.Status(400).JSON(
+			fiber.Map{
+				"status":  "error",
+				"message": "Review your input",
+				"data":    nil,
+			},
+		)
+	}
+
+	lease := new(models.Lease)
+	if err := db.Where("uuid = ?", uuid).First(&lease).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No Lease found",
+			"data":    nil,
+		})
+	}
+
+	if lease.Status != models.LeaseDraft {
+		return c.Status(409).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Only draft leases can be modified",
+			"data":    nil,
+		})
+	}
+
+	if !updateData.StartDate.IsZero() {
+		lease.StartDate = updateData.StartDate
+	}
+	lease.EndDate = updateData.EndDate
+	lease.MonthlyRent = updateData.MonthlyRent
+	lease.GarantieMonth = updateData.GarantieMonth
+	lease.Garantie = updateData.Garantie
+
+	db.Save(&lease)
+
+	return c.JSON(
+		fiber.Map{
+			"status":  "success",
+			"message": "Lease updated success",
+			"data":    lease,
+		},
+	)
+}
+
+// Activate a draft lease, the appartment becomes occupied
+func ActivateLease(c *fiber.Ctx) error {
+	uuid := c.Params("uuid")
+	db := database.DB
+
+	var lease models.Lease
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("uuid = ?", uuid).First(&lease).Error; err != nil {
+			return fiber.NewError(404, "No Lease found")
+		}
+
+		// L'appartement est verrouillé : deux activations simultanées de baux du même
+		// appartement attendent l'une l'autre, la seconde voit alors le bail actif
+		var appartment models.Appartment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("uuid").Where("uuid = ?", lease.AppartmentUUID).First(&appartment).Error; err != nil {
+			return err
+		}
+		// Relu sous le verrou : le bail a pu être activé entre-temps
+		if err := tx.Where("uuid = ?", uuid).First(&lease).Error; err != nil {
+			return err
+		}
+		if lease.Status != models.LeaseDraft {
+			return fiber.NewError(409, "Only draft leases can be activated")
+		}
+
+		var activeCount int64
+		if err := tx.Model(&models.Lease{}).
+			Where("appartment_uuid = ? AND status = ?", lease.AppartmentUUID, models.LeaseActive).
+			Count(&activeCount).Error; err != nil {
+			return err
+		}
+		if activeCount > 0 {
+			return fiber.NewError(409, "The appartment already has an active lease")
+		}
+
+		if err := tx.Model(&lease).Update("status", models.LeaseActive).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Appartment{}).
+			Where("uuid = ?", lease.AppartmentUUID).
+			Updates(map[string]interface{}{
+				"status":       "occupied",
+				"monthly_rent": lease.MonthlyRent,
+			}).Error
+	})
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		return c.Status(fe.Code).JSON(fiber.Map{
+			"status":  "error",
+			"message": fe.Message,
+			"data":    nil,
+		})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to activate Lease",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Lease activated success",
+		"data":    lease,
+	})
+}
+
+// Terminate an active lease, the appartment becomes available again
+func TerminateLease(c *fiber.Ctx) error {
+	uuid := c.Params("uuid")
+	db := database.DB
+
+	type TerminateInput struct {
+		EndDate *time.Time `json:"end_date"`
+	}
+
+	var input TerminateInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Review your input",
+			"data":    nil,
+		})
+	}
+
+	var lease models.Lease
+	if err := db.Where("uuid = ?", uuid).First(&lease).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No Lease found",
+			"data":    nil,
+		})
+	}
+
+	if lease.Status != models.LeaseActive {
+		return c.Status(409).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Only active leases can be terminated",
+			"data":    nil,
+		})
+	}
+
+	endDate := time.Now()
+	if input.EndDate != nil {
+		endDate = *input.EndDate
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&lease).Updates(map[string]interface{}{
+			"status":   models.LeaseTerminated,
+			"end_date": endDate,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Appartment{}).
+			Where("uuid = ?", lease.AppartmentUUID).
+			Update("status", "available").Error
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to terminate Lease",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Lease terminated success",
+		"data":    lease,
+	})
+}
@@ -0,0 +1,265 @@
+package periods
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kgermando/appartment-app-api/database"
+	"github.com/kgermando/appartment-app-api/models"
+	"github.com/kgermando/appartment-app-api/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// reconciliationLine compare le solde attendu d'une caisse au comptage physique
+type reconciliationLine struct {
+	RegisterUUID string        `json:"register_uuid"`
+	RegisterName string        `json:"register_name"`
+	Currency     string        `json:"currency"`
+	Expected     utils.Amount  `json:"expected"`
+	Counted      *utils.Amount `json:"counted"`
+	Variance     *utils.Amount `json:"variance"`
+}
+
+// Get all accounting periods, most recent first
+func GetPeriods(c *fiber.Ctx) error {
+	db := database.DB
+
+	var periods []models.AccountingPeriod
+	if err := db.Preload("ClosedBy").Preload("Counts").Order("year DESC, month DESC").Find(&periods).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch periods",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "All periods",
+		"data":    periods,
+	})
+}
+
+// Get the reconciliation of a month: expected balance of each register per currency
+// at the end of the month, with the physical counts and variances once closed
+func GetPeriodReconciliation(c *fiber.Ctx) error {
+	db := database.DB
+
+	year, month, err := periodParams(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+			"data":    nil,
+		})
+	}
+
+	period := models.AccountingPeriod{Year: year, Month: month, Status: models.PeriodOpen}
+	db.Preload("ClosedBy").Preload("Counts").Where("year = ? AND month = ?", year, month).First(&period)
+
+	lines, err := reconciliation(db, period)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to compute expected balances",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Period reconciliation",
+		"data": fiber.Map{
+			"period": period,
+			"lines":  lines,
+		},
+	})
+}
+
+// Close a month (Supervisor). The body gives the physical count of every
+// register in each currency: {"counts": [{"register_uuid", "currency", "counted"}], "notes"}
+func ClosePeriod(c *fiber.Ctx) error {
+	db := database.DB
+
+	year, month, err := periodParams(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+			"data":    nil,
+		})
+	}
+
+	_, end := models.PeriodBounds(year, month)
+	if end.After(time.Now()) {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "A period can only be closed once it is over",
+			"data":    nil,
+		})
+	}
+
+	var input struct {
+		Notes  string `json:"notes"`
+		Counts []struct {
+			RegisterUUID string       `json:"register_uuid"`
+			Currency     string       `json:"currency"`
+			Counted      utils.Amount `json:"counted"`
+		} `json:"counts"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid input",
+			"data":    nil,
+		})
+	}
+
+	counted := make(map[string]utils.Amount, len(input.Counts))
+	for _, count := range input.Counts {
+		counted[count.RegisterUUID+"|"+strings.ToUpper(count.Currency)] = count.Counted
+	}
+
+	user, _ := c.Locals("user").(models.User)
+
+	period := models.AccountingPeriod{Year: year, Month: month, Status: models.PeriodOpen}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Deux clôtures simultanées du mois attendent l'une l'autre, même quand la période
+		// n'existe pas encore : la seconde la voit alors clôturée
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", fmt.Sprintf("accounting_period:%d-%02d", year, month)).Error; err != nil {
+			return err
+		}
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("year = ? AND month = ?", year, month).First(&period).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if period.Status == models.PeriodClosed {
+			return fiber.NewError(409, "This period is already closed")
+		}
+
+		lines, err := reconciliation(tx, period)
+		if err != nil {
+			return err
+		}
+
+		// Chaque caisse doit être comptée dans chaque devise
+		var missing []string
+		var counts []models.CashCount
+		for _, line := range lines {
+			value, ok := counted[line.RegisterUUID+"|"+line.Currency]
+			if !ok {
+				missing = append(missing, line.RegisterName+" ("+line.Currency+")")
+				continue
+			}
+			counts = append(counts, models.CashCount{
+				UUID:         utils.GenerateUUID(),
+				RegisterUUID: line.RegisterUUID,
+				Currency:     line.Currency,
+				Expected:     line.Expected,
+				Counted:      value,
+				Variance:     value.Sub(line.Expected),
+			})
+		}
+		if len(missing) > 0 {
+			return fiber.NewError(400, "Missing cash count for: "+strings.Join(missing, ", "))
+		}
+
+		now := time.Now()
+		if period.UUID == "" {
+			period.UUID = utils.GenerateUUID()
+		}
+		period.Status = models.PeriodClosed
+		period.Notes = input.Notes
+		period.ClosedAt = &now
+		period.ClosedByUUID = user.UUID
+		if err := tx.Omit("ClosedBy", "Counts").Save(&period).Error; err != nil {
+			return err
+		}
+
+		for i := range counts {
+			counts[i].PeriodUUID = period.UUID
+		}
+		if len(counts) > 0 {
+			if err := tx.Create(&counts).Error; err != nil {
+				return err
+			}
+		}
+		period.Counts = counts
+		return nil
+	})
+
+	if err != nil {
+		var fe *fiber.Error
+		if errors.As(err, &fe) {
+			return c.Status(fe.Code).JSON(fiber.Map{
+				"status":  "error",
+				"message": fe.Message,
+				"data":    nil,
+			})
+		}
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to close period",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Period closed success",
+		"data":    period,
+	})
+}
+
+// periodParams lit et valide :year et :month
+func periodParams(c *fiber.Ctx) (int, int, error) {
+	year, err := strconv.Atoi(c.Params("year"))
+	if err != nil || year < 2000 || year > 2100 {
+		return 0, 0, errors.New("Invalid year")
+	}
+	month, err := strconv.Atoi(c.Params("month"))
+	if err != nil || month < 1 || month > 12 {
+		return 0, 0, errors.New("Invalid month, expected 1 to 12")
+	}
+	return year, month, nil
+}
+
+// reconciliation calcule les lignes attendues de la période et y reporte les comptages enregistrés
+func reconciliation(db *gorm.DB, period models.AccountingPeriod) ([]reconciliationLine, error) {
+	_, end := models.PeriodBounds(period.Year, period.Month)
+	balances, err := models.ExpectedBalances(db, end)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]models.CashCount, len(period.Counts))
+	for _, count := range period.Counts {
+		counts[count.RegisterUUID+"|"+count.Currency] = count
+	}
+
+	lines := []reconciliationLine{}
+	for _, b := range balances {
+		for _, currency := range models.CurrenciesOf(b.Balances) {
+			line := reconciliationLine{
+				RegisterUUID: b.RegisterUUID,
+				RegisterName: b.RegisterName,
+				Currency:     currency,
+				Expected:     b.Balances[currency],
+			}
+			// Une fois clôturée, la période garde les montants attendus du jour de la clôture
+			if count, ok := counts[b.RegisterUUID+"|"+currency]; ok {
+				line.Expected = count.Expected
+				line.Counted = &count.Counted
+				line.Variance = &count.Variance
+			}
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
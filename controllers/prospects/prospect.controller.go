@@ -0,0 +1,364 @@
+package prospects
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kgermando/appartment-app-api/database"
+	"github.com/kgermando/appartment-app-api/models"
+	"github.com/kgermando/appartment-app-api/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Paginate prospects, optionally scoped to a manager and filtered by status
+func GetPaginatedProspects(c *fiber.Ctx) error {
+	db := database.DB
+
+	managerUUID := c.Params("manager_uuid")
+
+	// Parse pagination and search filters
+	filter, err := utils.ParseQueryFilter(c, 15)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+			"data":    nil,
+		})
+	}
+	status := c.Query("status", "")
+
+	query := filter.ApplySearch(db.Model(&models.Prospect{}), "fullname", "telephone", "email")
+	if managerUUID != "" {
+		query = query.Where("manager_uuid = ?", managerUUID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var prospects []models.Prospect
+	var totalRecords int64
+
+	query.Session(&gorm.Session{}).Count(&totalRecords)
+
+	err = filter.Paginate(query).
+		Order("prospects.updated_at DESC").
+		Preload("Appartment").
+		Preload("Manager").
+		Find(&prospects).Error
+
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch Prospects",
+			"error":   err.Error(),
+		})
+	}
+
+	// Prepare pagination metadata
+	pagination := filter.Pagination(totalRecords)
+
+	return c.JSON(fiber.Map{
+		"status":     "success",
+		"message":    "Prospects retrieved successfully",
+		"data":       prospects,
+		"pagination": pagination,
+	})
+}
+
+// Get one data
+func GetProspect(c *fiber.Ctx) error {
+	uuid := c.Params("uuid")
+	db := database.DB
+	var prospect models.Prospect
+	db.Where("uuid = ?", uuid).
+		Preload("Appartment").
+		Preload("Manager").
+		Preload("Visits", func(db *gorm.DB) *gorm.DB {
+			return db.Order("starts_at ASC")
+		}).
+		First(&prospect)
+	if prospect.UUID == "" {
+		return c.Status(404).JSON(
+			fiber.Map{
+				"status":  "error",
+				"message": "No Prospect found",
+				"data":    nil,
+			},
+		)
+	}
+	return c.JSON(
+		fiber.Map{
+			"status":  "success",
+			"message": "Prospect found",
+			"data":    prospect,
+		},
+	)
+}
+
+// Create data (walk-in, phone...) from the back-office
+func CreateProspect(c *fiber.Ctx) error {
+	db := database.DB
+
+	type CreateProspectInput struct {
+		Fullname       string     `json:"fullname" validate:"required"`
+		Email          string     `json:"email" validate:"omitempty,email"`
+		Telephone      string     `json:"telephone" validate:"required"`
+		Message        string     `json:"message"`
+		PreferredDate  *time.Time `json:"preferred_date"`
+		Source         string     `json:"source"`
+		Notes          string     `json:"notes"`
+		AppartmentUUID string     `json:"appartment_uuid" validate:"required"`
+	}
+
+	var input CreateProspectInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to parse request body",
+			"error":   err.Error(),
+		})
+	}
+
+	if errs := utils.ValidateStruct(input); errs != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Form not complete - fullname, telephone and appartment_uuid are required",
+			"errors":  errs,
+		})
+	}
+
+	var appartment models.Appartment
+	if err := db.Where("uuid = ?", input.AppartmentUUID).First(&appartment).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Appartment not found",
+			"data":    nil,
+		})
+	}
+
+	source := input.Source
+	if source == "" {
+		source = "walk-in"
+	}
+
+	prospect := &models.Prospect{
+		UUID:           utils.GenerateUUID(),
+		Fullname:       strings.TrimSpace(input.Fullname),
+		Email:          strings.TrimSpace(input.Email),
+		Telephone:      strings.TrimSpace(input.Telephone),
+		Message:        input.Message,
+		PreferredDate:  input.PreferredDate,
+		Source:         source,
+		Status:         models.ProspectNew,
+		Notes:          input.Notes,
+		AppartmentUUID: appartment.UUID,
+		ManagerUUID:    appartment.ManagerUUID,
+	}
+
+	if err := db.Create(prospect).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to create Prospect",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(
+		fiber.Map{
+			"status":  "success",
+			"message": "Prospect Created success",
+			"data":    prospect,
+		},
+	)
+}
+
+// Move a prospect along the pipeline
+func UpdateProspectStatus(c *fiber.Ctx) error {
+	uuid := c.Params("uuid")
+	db := database.DB
+
+	type UpdateStatusInput struct {
+		Status string `json:"status"`
+		Notes  string `json:"notes"`
+	}
+
+	var input UpdateStatusInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Review your input",
+			"data":    nil,
+		})
+	}
+
+	if !models.IsValidProspectStatus(input.Status) {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Status must be one of 'new', 'visited', 'application', 'accepted' or 'rejected'",
+			"data":    nil,
+		})
+	}
+
+	var prospect models.Prospect
+	if err := db.Where("uuid = ?", uuid).First(&prospect).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No Prospect found",
+			"data":    nil,
+		})
+	}
+
+	if !prospect.CanTransitionTo(input.Status) {
+		return c.Status(409).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Cannot move prospect from '" + prospect.Status + "' to '" + input.Status + "'",
+			"data":    nil,
+		})
+	}
+
+	prospect.Status = input.Status
+	if input.Notes != "" {
+		prospect.Notes = input.Notes
+	}
+
+	db.Save(&prospect)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Prospect status updated success",
+		"data":    prospect,
+	})
+}
+
+// Convert an accepted prospect into a tenant with a draft lease
+func ConvertProspect(c *fiber.Ctx) error {
+	uuid := c.Params("uuid")
+	db := database.DB
+
+	type ConvertInput struct {
+		StartDate time.Time  `json:"start_date"`
+		EndDate   *time.Time `json:"end_date"`
+	}
+
+	var input ConvertInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Review your input",
+			"data":    nil,
+		})
+	}
+
+	startDate := input.StartDate
+	if startDate.IsZero() {
+		startDate = time.Now()
+	}
+
+	var tenant *models.Tenant
+	var lease *models.Lease
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Le prospect est verrouillé : deux conversions simultanées ne créent pas deux locataires
+		var prospect models.Prospect
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("uuid = ?", uuid).First(&prospect).Error; err != nil {
+			return fiber.NewError(404, "No Prospect found")
+		}
+		if prospect.Status != models.ProspectAccepted {
+			return fiber.NewError(409, "Only accepted prospects can be converted")
+		}
+		if prospect.TenantUUID != "" {
+			return fiber.NewError(409, "Prospect already converted")
+		}
+		var appartment models.Appartment
+		if err := tx.Where("uuid = ?", prospect.AppartmentUUID).First(&appartment).Error; err != nil {
+			return err
+		}
+
+		tenant = &models.Tenant{
+			UUID:         utils.GenerateUUID(),
+			Fullname:     prospect.Fullname,
+			Email:        prospect.Email,
+			Telephone:    prospect.Telephone,
+			ProspectUUID: prospect.UUID,
+		}
+
+		lease = &models.Lease{
+			UUID:           utils.GenerateUUID(),
+			AppartmentUUID: prospect.AppartmentUUID,
+			TenantUUID:     tenant.UUID,
+			StartDate:      startDate,
+			EndDate:        input.EndDate,
+			MonthlyRent:    appartment.MonthlyRent,
+			GarantieMonth:  appartment.GarantieMonth,
+			Garantie:       appartment.Garantie,
+			Status:         models.LeaseDraft,
+		}
+
+		if err := tx.Create(tenant).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(lease).Error; err != nil {
+			return err
+		}
+		return tx.Model(&prospect).Updates(map[string]interface{}{
+			"tenant_uuid": tenant.UUID,
+			"lease_uuid":  lease.UUID,
+		}).Error
+	})
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		return c.Status(fe.Code).JSON(fiber.Map{
+			"status":  "error",
+			"message": fe.Message,
+			"data":    nil,
+		})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to convert Prospect",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Prospect converted success",
+		"data": fiber.Map{
+			"tenant": tenant,
+			"lease":  lease,
+		},
+	})
+}
+
+// Delete data
+func DeleteProspect(c *fiber.Ctx) error {
+	uuid := c.Params("uuid")
+
+	db := database.DB
+
+	var prospect models.Prospect
+	db.Where("uuid = ?", uuid).First(&prospect)
+	if prospect.UUID == "" {
+		return c.Status(404).JSON(
+			fiber.Map{
+				"status":  "error",
+				"message": "No Prospect found",
+				"data":    nil,
+			},
+		)
+	}
+
+	db.Delete(&prospect)
+
+	return c.JSON(
+		fiber.Map{
+			"status":  "success",
+			"message": "Prospect deleted success",
+			"data":    nil,
+		},
+	)
+}
@@ -0,0 +1,287 @@
+package prospects
+
+import (
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kgermando/appartment-app-api/database"
+	"github.com/kgermando/appartment-app-api/models"
+	"github.com/kgermando/appartment-app-api/utils"
+	"gorm.io/gorm"
+)
+
+// Durée par défaut d'une visite lorsque ends_at n'est pas fourni
+const defaultVisitDuration = 30 * time.Minute
+
+// findVisitConflicts retourne les visites planifiées qui chevauchent le créneau
+// pour le même appartement ou le même gestionnaire
+func findVisitConflicts(db *gorm.DB, appartmentUUID, managerUUID string, startsAt, endsAt time.Time, excludeUUID string) ([]models.Visit, error) {
+	var conflicts []models.Visit
+
+	query := db.Where("status = ?", models.VisitScheduled).
+		Where("starts_at < ? AND ends_at > ?", endsAt, startsAt)
+	if managerUUID != "" {
+		query = query.Where("appartment_uuid = ? OR manager_uuid = ?", appartmentUUID, managerUUID)
+	} else {
+		query = query.Where("appartment_uuid = ?", appartmentUUID)
+	}
+	if excludeUUID != "" {
+		query = query.Where("uuid <> ?", excludeUUID)
+	}
+
+	err := query.Order("starts_at ASC").Find(&conflicts).Error
+	return conflicts, err
+}
+
+// lockVisitSlots verrouille jusqu'à la fin de la transaction les agendas de l'appartement
+// et du gestionnaire : deux réservations concurrentes du même créneau sont vérifiées
+// l'une après l'autre. Les verrous sont pris dans le même ordre pour éviter les interblocages.
+func lockVisitSlots(tx *gorm.DB, appartmentUUID, managerUUID string) error {
+	keys := []string{"visit:appartment:" + appartmentUUID}
+	if managerUUID != "" {
+		keys = append(keys, "visit:manager:"+managerUUID)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", key).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Visits of a manager, optionally between from and to (YYYY-MM-DD)
+func GetVisitsByManagerUUID(c *fiber.Ctx) error {
+	db := database.DB
+	managerUUID := c.Params("manager_uuid")
+
+	query := db.Where("manager_uuid = ?", managerUUID)
+	if from := c.Query("from"); from != "" {
+		if parsedFrom, err := time.Parse("2006-01-02", from); err == nil {
+			query = query.Where("starts_at >= ?", parsedFrom)
+		}
+	}
+	if to := c.Query("to"); to != "" {
+		if parsedTo, err := time.Parse("2006-01-02", to); err == nil {
+			query = query.Where("starts_at < ?", parsedTo.Add(24*time.Hour))
+		}
+	}
+
+	var visits []models.Visit
+	query.Order("starts_at ASC").Preload("Prospect").Preload("Appartment").Find(&visits)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "All visits",
+		"data":    visits,
+	})
+}
+
+// Schedule a visit for a prospect
+func CreateVisit(c *fiber.Ctx) error {
+	db := database.DB
+
+	type CreateVisitInput struct {
+		ProspectUUID string    `json:"prospect_uuid"`
+		StartsAt     time.Time `json:"starts_at"`
+		EndsAt       time.Time `json:"ends_at"`
+		Notes        string    `json:"notes"`
+	}
+
+	var input CreateVisitInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to parse request body",
+			"error":   err.Error(),
+		})
+	}
+
+	if input.ProspectUUID == "" || input.StartsAt.IsZero() {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Form not complete - prospect_uuid and starts_at are required",
+			"data":    nil,
+		})
+	}
+
+	if input.EndsAt.IsZero() {
+		input.EndsAt = input.StartsAt.Add(defaultVisitDuration)
+	}
+	if !input.EndsAt.After(input.StartsAt) {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "ends_at must be after starts_at",
+			"data":    nil,
+		})
+	}
+
+	var prospect models.Prospect
+	if err := db.Where("uuid = ?", input.ProspectUUID).First(&prospect).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No Prospect found",
+			"data":    nil,
+		})
+	}
+
+	if prospect.Status == models.ProspectRejected || prospect.Status == models.ProspectAccepted {
+		return c.Status(409).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Cannot schedule a visit for a " + prospect.Status + " prospect",
+			"data":    nil,
+		})
+	}
+
+	visit := &models.Visit{
+		UUID:           utils.GenerateUUID(),
+		ProspectUUID:   prospect.UUID,
+		AppartmentUUID: prospect.AppartmentUUID,
+		ManagerUUID:    prospect.ManagerUUID,
+		StartsAt:       input.StartsAt,
+		EndsAt:         input.EndsAt,
+		Status:         models.VisitScheduled,
+		Notes:          input.Notes,
+	}
+
+	var conflicts []models.Visit
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := lockVisitSlots(tx, visit.AppartmentUUID, visit.ManagerUUID); err != nil {
+			return err
+		}
+		var err error
+		conflicts, err = findVisitConflicts(tx, visit.AppartmentUUID, visit.ManagerUUID, visit.StartsAt, visit.EndsAt, "")
+		if err != nil || len(conflicts) > 0 {
+			return err
+		}
+		return tx.Create(visit).Error
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to schedule visit",
+			"error":   err.Error(),
+		})
+	}
+	if len(conflicts) > 0 {
+		return c.Status(409).JSON(fiber.Map{
+			"status":  "error",
+			"message": "This slot overlaps another visit for the appartment or the manager",
+			"data":    conflicts,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Visit scheduled success",
+		"data":    visit,
+	})
+}
+
+// Reschedule a visit or change its status
+func UpdateVisit(c *fiber.Ctx) error {
+	uuid := c.Params("uuid")
+	db := database.DB
+
+	type UpdateVisitInput struct {
+		StartsAt time.Time `json:"starts_at"`
+		EndsAt   time.Time `json:"ends_at"`
+		Status   string    `json:"status"`
+		Notes    string    `json:"notes"`
+	}
+
+	var input UpdateVisitInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Review your input",
+			"data":    nil,
+		})
+	}
+
+	var visit models.Visit
+	if err := db.Where("uuid = ?", uuid).First(&visit).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No Visit found",
+			"data":    nil,
+		})
+	}
+
+	if !input.StartsAt.IsZero() {
+		visit.StartsAt = input.StartsAt
+		if input.EndsAt.IsZero() {
+			input.EndsAt = input.StartsAt.Add(defaultVisitDuration)
+		}
+	}
+	if !input.EndsAt.IsZero() {
+		visit.EndsAt = input.EndsAt
+	}
+	if !visit.EndsAt.After(visit.StartsAt) {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "ends_at must be after starts_at",
+			"data":    nil,
+		})
+	}
+	if input.Status != "" {
+		visit.Status = input.Status
+	}
+	if !visit.ValidateStatus() {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Status must be one of 'scheduled', 'done', 'cancelled' or 'no_show'",
+			"data":    nil,
+		})
+	}
+	if input.Notes != "" {
+		visit.Notes = input.Notes
+	}
+
+	var conflicts []models.Visit
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if visit.Status == models.VisitScheduled {
+			if err := lockVisitSlots(tx, visit.AppartmentUUID, visit.ManagerUUID); err != nil {
+				return err
+			}
+			var err error
+			conflicts, err = findVisitConflicts(tx, visit.AppartmentUUID, visit.ManagerUUID, visit.StartsAt, visit.EndsAt, visit.UUID)
+			if err != nil || len(conflicts) > 0 {
+				return err
+			}
+		}
+
+		if err := tx.Save(&visit).Error; err != nil {
+			return err
+		}
+
+		// Une visite effectuée fait avancer un nouveau prospect à l'étape "visited"
+		if visit.Status == models.VisitDone {
+			return tx.Model(&models.Prospect{}).
+				Where("uuid = ? AND status = ?", visit.ProspectUUID, models.ProspectNew).
+				Update("status", models.ProspectVisited).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to update visit",
+			"error":   err.Error(),
+		})
+	}
+	if len(conflicts) > 0 {
+		return c.Status(409).JSON(fiber.Map{
+			"status":  "error",
+			"message": "This slot overlaps another visit for the appartment or the manager",
+			"data":    conflicts,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Visit updated success",
+		"data":    visit,
+	})
+}
@@ -0,0 +1,202 @@
+package public
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kgermando/appartment-app-api/database"
+	"github.com/kgermando/appartment-app-api/models"
+	"github.com/kgermando/appartment-app-api/utils"
+	"gorm.io/gorm"
+)
+
+// Paginate available appartments for the public website
+func GetPublicListings(c *fiber.Ctx) error {
+	db := database.DB
+
+	// Parse query parameters for pagination
+	filter, err := utils.ParseQueryFilter(c, 12)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+			"data":    nil,
+		})
+	}
+	if filter.Limit > 50 {
+		filter.Limit = 12
+	}
+
+	query := db.Model(&models.Appartment{}).Where("status = ?", "available")
+
+	// Filtres optionnels
+	if v := c.Query("min_rent"); v != "" {
+		minRent, err := utils.ParseMoney(v)
+		if err != nil {
+			return invalidFilter(c, "min_rent")
+		}
+		query = query.Where("monthly_rent >= ?", minRent)
+	}
+	if v := c.Query("max_rent"); v != "" {
+		maxRent, err := utils.ParseMoney(v)
+		if err != nil {
+			return invalidFilter(c, "max_rent")
+		}
+		query = query.Where("monthly_rent <= ?", maxRent)
+	}
+	if v := c.Query("rooms"); v != "" {
+		rooms, err := strconv.Atoi(v)
+		if err != nil {
+			return invalidFilter(c, "rooms")
+		}
+		query = query.Where("rooms = ?", rooms)
+	}
+	if v := c.Query("furnished"); v != "" {
+		furnished, err := strconv.ParseBool(v)
+		if err != nil {
+			return invalidFilter(c, "furnished")
+		}
+		query = query.Where("furnished = ?", furnished)
+	}
+	if v := strings.TrimSpace(c.Query("area")); v != "" {
+		query = query.Where("area ILIKE ? OR name ILIKE ?", "%"+v+"%", "%"+v+"%")
+	}
+
+	var totalRecords int64
+	query.Session(&gorm.Session{}).Count(&totalRecords)
+
+	var appartments []models.Appartment
+	err = filter.Paginate(query).
+		Order("appartments.updated_at DESC").
+		Preload("Photos", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position ASC")
+		}).
+		Find(&appartments).Error
+
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch listings",
+			"error":   err.Error(),
+		})
+	}
+
+	listings := make([]models.PublicListing, 0, len(appartments))
+	for _, apt := range appartments {
+		listings = append(listings, models.NewPublicListing(apt))
+	}
+
+	// Prepare pagination metadata
+	pagination := filter.Pagination(totalRecords)
+
+	return c.JSON(fiber.Map{
+		"status":     "success",
+		"message":    "Listings retrieved successfully",
+		"data":       listings,
+		"pagination": pagination,
+	})
+}
+
+// Get one available appartment
+func GetPublicListing(c *fiber.Ctx) error {
+	uuid := c.Params("uuid")
+	db := database.DB
+
+	var appartment models.Appartment
+	err := db.Where("uuid = ? AND status = ?", uuid, "available").
+		Preload("Photos", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position ASC")
+		}).
+		First(&appartment).Error
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Listing not found",
+			"data":    nil,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Listing found",
+		"data":    models.NewPublicListing(appartment),
+	})
+}
+
+// Contact / visit request form, creates a prospect assigned to the appartment's manager
+func CreateVisitRequest(c *fiber.Ctx) error {
+	uuid := c.Params("uuid")
+	db := database.DB
+
+	type VisitRequestInput struct {
+		Fullname      string     `json:"fullname" validate:"required,max=255"`
+		Email         string     `json:"email" validate:"omitempty,email"`
+		Telephone     string     `json:"telephone" validate:"required,max=50"`
+		Message       string     `json:"message" validate:"max=2000"`
+		PreferredDate *time.Time `json:"preferred_date"`
+	}
+
+	var input VisitRequestInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to parse request body",
+			"error":   err.Error(),
+		})
+	}
+
+	if errs := utils.ValidateStruct(input); errs != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Form not complete - fullname and telephone are required",
+			"errors":  errs,
+		})
+	}
+
+	var appartment models.Appartment
+	if err := db.Where("uuid = ? AND status = ?", uuid, "available").First(&appartment).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Listing not found",
+			"data":    nil,
+		})
+	}
+
+	prospect := &models.Prospect{
+		UUID:           utils.GenerateUUID(),
+		Fullname:       strings.TrimSpace(input.Fullname),
+		Email:          strings.TrimSpace(input.Email),
+		Telephone:      strings.TrimSpace(input.Telephone),
+		Message:        input.Message,
+		PreferredDate:  input.PreferredDate,
+		Source:         "public",
+		Status:         models.ProspectNew,
+		AppartmentUUID: appartment.UUID,
+		ManagerUUID:    appartment.ManagerUUID,
+	}
+
+	if err := db.Create(prospect).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to send your request",
+			"error":   err.Error(),
+		})
+	}
+
+	// Ne renvoyer aucune information interne au visiteur
+	return c.Status(201).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Your request has been sent, the manager will contact you",
+		"data":    nil,
+	})
+}
+
+func invalidFilter(c *fiber.Ctx, name string) error {
+	return c.Status(400).JSON(fiber.Map{
+		"status":  "error",
+		"message": "Invalid value for " + name,
+		"data":    nil,
+	})
+}
@@ -0,0 +1,270 @@
+package rates
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kgermando/appartment-app-api/database"
+	"github.com/kgermando/appartment-app-api/models"
+	"github.com/kgermando/appartment-app-api/utils"
+	"github.com/shopspring/decimal"
+)
+
+// rateInput est le corps des requêtes de création et de modification d'un taux
+type rateInput struct {
+	Currency      string          `json:"currency"`       // Devise cotée, CDF par défaut
+	EffectiveDate string          `json:"effective_date"` // YYYY-MM-DD ou DD/MM/YYYY
+	Rate          decimal.Decimal `json:"rate"`           // Unités de la devise pour 1 USD
+	Source        string          `json:"source"`
+	Notes         string          `json:"notes"`
+}
+
+// Get all exchange rates, most recent first, filter: currency
+func GetExchangeRates(c *fiber.Ctx) error {
+	db := database.DB
+
+	query := db.Model(&models.ExchangeRate{})
+	if currency := c.Query("currency"); currency != "" {
+		query = query.Where("currency = ?", strings.ToUpper(currency))
+	}
+
+	var rates []models.ExchangeRate
+	if err := query.Order("effective_date DESC, currency").Find(&rates).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch exchange rates",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "All exchange rates",
+		"data":    rates,
+	})
+}
+
+// Get the exchange rate of a currency in force at a date (?currency=, CDF by default, ?date=, today by default)
+func GetExchangeRateAt(c *fiber.Ctx) error {
+	date := time.Now()
+	if v := c.Query("date"); v != "" {
+		var err error
+		if date, err = utils.ParseDate(v); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+	}
+
+	rate, err := models.RateAt(database.DB, strings.ToUpper(c.Query("currency", utils.CurrencyCDF)), date)
+	if errors.Is(err, models.ErrNoExchangeRate) {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+			"data":    nil,
+		})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch exchange rate",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Exchange rate in force",
+		"data":    rate,
+	})
+}
+
+// Create the exchange rate in force from a date. Draft entries without a rate
+// receive it if their transaction date falls in its period.
+func CreateExchangeRate(c *fiber.Ctx) error {
+	db := database.DB
+
+	var input rateInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid input",
+			"data":    nil,
+		})
+	}
+
+	effectiveDate, err := utils.ParseDate(input.EffectiveDate)
+	if err != nil || !input.Rate.IsPositive() {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "A valid effective_date and a rate greater than 0 are required",
+			"data":    nil,
+		})
+	}
+
+	currency := strings.ToUpper(input.Currency)
+	if currency == "" {
+		currency = utils.CurrencyCDF
+	}
+	if currency == utils.CurrencyUSD || !utils.IsCurrency(currency) {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "currency must be an enabled currency other than USD",
+			"data":    nil,
+		})
+	}
+
+	var count int64
+	db.Model(&models.ExchangeRate{}).
+		Where("currency = ? AND effective_date = ?", currency, effectiveDate.Format("2006-01-02")).
+		Count(&count)
+	if count > 0 {
+		return c.Status(409).JSON(fiber.Map{
+			"status":  "error",
+			"message": "An exchange rate already exists for " + currency + " on " + effectiveDate.Format("02/01/2006"),
+			"data":    nil,
+		})
+	}
+
+	rate := &models.ExchangeRate{
+		UUID:          utils.GenerateUUID(),
+		Currency:      currency,
+		EffectiveDate: effectiveDate,
+		Rate:          input.Rate,
+		Source:        input.Source,
+		Notes:         input.Notes,
+	}
+	if user, ok := c.Locals("user").(models.User); ok {
+		rate.CreatedByUUID = user.UUID
+	}
+
+	if err := db.Create(rate).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to create exchange rate",
+			"error":   err.Error(),
+		})
+	}
+
+	// Les brouillons sans taux prennent ce taux. Les entrées comptabilisées gardent le leur
+	// (ou restent sans conversion) et ne sont pas dans les résumés à reconstruire.
+	if _, err := models.BackfillCaisseRates(db); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Exchange rate created but draft entries without rate were not updated",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Exchange rate created success",
+		"data":    rate,
+	})
+}
+
+// Update an exchange rate. Entries already posted keep the rate they were posted with.
+func UpdateExchangeRate(c *fiber.Ctx) error {
+	db := database.DB
+
+	var input rateInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Review your input",
+			"data":    nil,
+		})
+	}
+
+	var rate models.ExchangeRate
+	db.Where("uuid = ?", c.Params("uuid")).First(&rate)
+	if rate.UUID == "" {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No exchange rate found",
+			"data":    nil,
+		})
+	}
+
+	if input.EffectiveDate != "" {
+		effectiveDate, err := utils.ParseDate(input.EffectiveDate)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+				"data":    nil,
+			})
+		}
+		var count int64
+		db.Model(&models.ExchangeRate{}).
+			Where("currency = ? AND effective_date = ? AND uuid <> ?", rate.Currency, effectiveDate.Format("2006-01-02"), rate.UUID).
+			Count(&count)
+		if count > 0 {
+			return c.Status(409).JSON(fiber.Map{
+				"status":  "error",
+				"message": "An exchange rate already exists for " + rate.Currency + " on " + effectiveDate.Format("02/01/2006"),
+				"data":    nil,
+			})
+		}
+		rate.EffectiveDate = effectiveDate
+	}
+	if input.Rate.IsNegative() {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "The rate must be greater than 0",
+			"data":    nil,
+		})
+	}
+	if input.Rate.IsPositive() {
+		rate.Rate = input.Rate
+	}
+	rate.Source = input.Source
+	rate.Notes = input.Notes
+
+	if err := db.Save(&rate).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to update exchange rate",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Exchange rate updated success",
+		"data":    rate,
+	})
+}
+
+// Delete an exchange rate. Entries already posted keep the rate they were posted with.
+func DeleteExchangeRate(c *fiber.Ctx) error {
+	db := database.DB
+
+	var rate models.ExchangeRate
+	db.Where("uuid = ?", c.Params("uuid")).First(&rate)
+	if rate.UUID == "" {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No exchange rate found",
+			"data":    nil,
+		})
+	}
+
+	if err := db.Delete(&rate).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to delete exchange rate",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Exchange rate deleted success",
+		"data":    nil,
+	})
+}
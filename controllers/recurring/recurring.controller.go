@@ -0,0 +1,439 @@
+package recurring
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kgermando/appartment-app-api/database"
+	"github.com/kgermando/appartment-app-api/models"
+	"github.com/kgermando/appartment-app-api/utils"
+	"gorm.io/gorm"
+)
+
+// recurringInput est la saisie d'une sortie récurrente, les dates étant lues en texte
+type recurringInput struct {
+	Name           string                        `json:"name"`
+	Building       string                        `json:"building"`
+	AppartmentUUID string                        `json:"appartment_uuid"`
+	CategoryUUID   string                        `json:"category_uuid"`
+	AccountUUID    string                        `json:"account_uuid"`
+	Lines          []models.RecurringExpenseLine `json:"lines"`
+	Frequency      string                        `json:"frequency"`
+	StartDate      string                        `json:"start_date"`
+	EndDate        string                        `json:"end_date"`
+	Signature      string                        `json:"signature"`
+	Active         *bool                         `json:"active"`
+}
+
+// pendingManager compte les brouillons à valider d'un gestionnaire
+type pendingManager struct {
+	ManagerUUID string                  `json:"manager_uuid"`
+	ManagerName string                  `json:"manager_name"`
+	Drafts      int64                   `json:"drafts"`
+	Totals      map[string]utils.Amount `json:"totals"` // Montants par devise
+}
+
+// Paginate, filters: search (name, building), user_uuid (manager of the apartment), active
+func GetPaginatedRecurringExpenses(c *fiber.Ctx) error {
+	db := database.DB
+
+	filter, err := utils.ParseQueryFilter(c, 15)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+			"data":    nil,
+		})
+	}
+
+	// Une requête neuve pour le comptage et pour la page
+	filtered := func() *gorm.DB {
+		query := db.Model(&models.RecurringExpense{}).
+			Joins("JOIN appartments ON appartments.uuid = recurring_expenses.appartment_uuid")
+		query = filter.ApplyManager(query, "appartments.manager_uuid")
+		query = filter.ApplySearch(query, "recurring_expenses.name", "recurring_expenses.building")
+		if v := c.Query("active"); v != "" {
+			query = query.Where("recurring_expenses.active = ?", v == "true")
+		}
+		return query
+	}
+
+	var totalRecords int64
+	filtered().Count(&totalRecords)
+
+	var templates []models.RecurringExpense
+	err = filter.Paginate(filtered()).
+		Preload("Appartment").
+		Preload("Category").
+		Preload("Lines").
+		Order("recurring_expenses.next_run_date, recurring_expenses.name").
+		Find(&templates).Error
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch recurring expenses",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":     "success",
+		"message":    "Recurring expenses retrieved successfully",
+		"data":       templates,
+		"pagination": filter.Pagination(totalRecords),
+	})
+}
+
+// Get one data
+func GetRecurringExpense(c *fiber.Ctx) error {
+	db := database.DB
+
+	var template models.RecurringExpense
+	db.Where("uuid = ?", c.Params("uuid")).
+		Preload("Appartment").
+		Preload("Category").
+		Preload("Lines").
+		First(&template)
+	if template.UUID == "" {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No recurring expense found",
+			"data":    nil,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Recurring expense found",
+		"data":    template,
+	})
+}
+
+// Create a recurring expense: its drafts are created by the scheduler from start_date
+func CreateRecurringExpense(c *fiber.Ctx) error {
+	db := database.DB
+
+	p := &recurringInput{}
+	if err := c.BodyParser(&p); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid input",
+			"data":    nil,
+		})
+	}
+
+	if strings.TrimSpace(p.Name) == "" || p.CategoryUUID == "" || p.StartDate == "" ||
+		(p.AppartmentUUID == "" && p.Building == "") {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Form not complete - name, appartment_uuid or building, category_uuid and start_date are required",
+			"data":    nil,
+		})
+	}
+
+	template := &models.RecurringExpense{
+		UUID:        utils.GenerateUUID(),
+		Name:        strings.TrimSpace(p.Name),
+		AccountUUID: p.AccountUUID,
+		Signature:   p.Signature,
+		Active:      true,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := applyInput(tx, template, p); err != nil {
+			return err
+		}
+		if err := tx.Omit("Appartment", "Category", "Lines").Create(template).Error; err != nil {
+			return err
+		}
+		return models.SaveRecurringExpenseLines(tx, template)
+	})
+	if err != nil {
+		return recurringError(c, err, "Failed to create recurring expense")
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Recurring expense created success",
+		"data":    template,
+	})
+}
+
+// Update a recurring expense. A new frequency or start_date restarts its schedule.
+func UpdateRecurringExpense(c *fiber.Ctx) error {
+	db := database.DB
+
+	p := &recurringInput{}
+	if err := c.BodyParser(&p); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid input",
+			"data":    nil,
+		})
+	}
+
+	var template models.RecurringExpense
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("uuid = ?", c.Params("uuid")).Preload("Lines").First(&template).Error; err != nil {
+			return err
+		}
+		if name := strings.TrimSpace(p.Name); name != "" {
+			template.Name = name
+		}
+		if p.AccountUUID != "" {
+			template.AccountUUID = p.AccountUUID
+		}
+		if p.Signature != "" {
+			template.Signature = p.Signature
+		}
+		if p.Active != nil {
+			template.Active = *p.Active
+		}
+		if p.CategoryUUID == "" {
+			p.CategoryUUID = template.CategoryUUID
+		}
+		if p.AppartmentUUID == "" && p.Building == "" {
+			p.Building = template.Building
+			if p.Building == "" {
+				p.AppartmentUUID = template.AppartmentUUID
+			}
+		}
+		if len(p.Lines) == 0 {
+			p.Lines = template.Lines
+		}
+		if p.Frequency == "" {
+			p.Frequency = template.Frequency
+		}
+
+		if err := applyInput(tx, &template, p); err != nil {
+			return err
+		}
+		if err := tx.Omit("Appartment", "Category", "Lines").Save(&template).Error; err != nil {
+			return err
+		}
+		return models.SaveRecurringExpenseLines(tx, &template)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No recurring expense found",
+			"data":    nil,
+		})
+	}
+	if err != nil {
+		return recurringError(c, err, "Failed to update recurring expense")
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Recurring expense updated success",
+		"data":    template,
+	})
+}
+
+// Delete a recurring expense: no more drafts are created, the existing entries are kept
+func DeleteRecurringExpense(c *fiber.Ctx) error {
+	db := database.DB
+
+	var template models.RecurringExpense
+	db.Where("uuid = ?", c.Params("uuid")).First(&template)
+	if template.UUID == "" {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No recurring expense found",
+			"data":    nil,
+		})
+	}
+
+	db.Delete(&template)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Recurring expense deleted success",
+		"data":    nil,
+	})
+}
+
+// Create now the drafts of the recurring expenses that are due, without waiting for the scheduler.
+// A failing template does not stop the others: the errors are returned with the number of drafts created.
+func RunRecurringExpenses(c *fiber.Ctx) error {
+	created, err := models.GenerateRecurringExpenses(database.DB, time.Now())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to create recurring expense drafts",
+			"error":   err.Error(),
+			"data":    fiber.Map{"created": created}, // Drafts of the templates without errors
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Recurring expense drafts created",
+		"data":    fiber.Map{"created": created},
+	})
+}
+
+// Draft entries created from recurring expenses and waiting to be posted
+// (PUT /api/caisses/:uuid/post), with their count per manager. Filters: user_uuid.
+func GetPendingRecurringDrafts(c *fiber.Ctx) error {
+	db := database.DB
+
+	filter, err := utils.ParseQueryFilter(c, 0)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+			"data":    nil,
+		})
+	}
+
+	query := db.Joins("JOIN appartments ON appartments.uuid = caisses.appartment_uuid").
+		Where("caisses.status = ? AND COALESCE(caisses.recurring_expense_uuid, '') <> ''", models.CaisseDraft)
+	query = filter.ApplyManager(query, "appartments.manager_uuid")
+
+	var drafts []models.Caisse
+	err = query.Preload("Appartment.Manager").
+		Preload("Category").
+		Preload("Lines").
+		Order("caisses.transaction_date, caisses.created_at").
+		Find(&drafts).Error
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch pending drafts",
+			"error":   err.Error(),
+		})
+	}
+
+	managers := make(map[string]*pendingManager)
+	for _, draft := range drafts {
+		manager, ok := managers[draft.Appartment.ManagerUUID]
+		if !ok {
+			manager = &pendingManager{
+				ManagerUUID: draft.Appartment.ManagerUUID,
+				ManagerName: draft.Appartment.Manager.Fullname,
+				Totals:      make(map[string]utils.Amount),
+			}
+			managers[draft.Appartment.ManagerUUID] = manager
+		}
+		manager.Drafts++
+		for currency, amount := range draft.TotalsByCurrency() {
+			manager.Totals[currency] = manager.Totals[currency].Add(amount)
+		}
+	}
+	byManager := make([]*pendingManager, 0, len(managers))
+	for _, manager := range managers {
+		byManager = append(byManager, manager)
+	}
+	sort.Slice(byManager, func(i, j int) bool { return byManager[i].ManagerName < byManager[j].ManagerName })
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Pending recurring drafts retrieved successfully",
+		"data": fiber.Map{
+			"drafts":     drafts,
+			"by_manager": byManager,
+		},
+	})
+}
+
+// applyInput contrôle la saisie et l'applique au modèle : appartement ou immeuble, catégorie
+// de sortie active, montants, fréquence et dates. Une nouvelle fréquence ou date de début
+// repart de la première échéance.
+func applyInput(tx *gorm.DB, template *models.RecurringExpense, p *recurringInput) error {
+	// Une sortie d'immeuble est passée sur son premier appartement
+	var apt models.Appartment
+	if p.Building != "" {
+		tx.Where("name = ?", p.Building).Order("number").Limit(1).Find(&apt)
+		if apt.UUID == "" {
+			return fiber.NewError(400, "No apartment found in building "+p.Building)
+		}
+	} else {
+		tx.Where("uuid = ?", p.AppartmentUUID).Limit(1).Find(&apt)
+		if apt.UUID == "" {
+			return fiber.NewError(400, "Appartment not found")
+		}
+	}
+	template.Building = p.Building
+	template.AppartmentUUID = apt.UUID
+
+	var category models.Category
+	tx.Where("uuid = ?", p.CategoryUUID).Limit(1).Find(&category)
+	if category.UUID == "" {
+		return fiber.NewError(400, "Category not found")
+	}
+	if !category.Active || category.Type != "Expense" {
+		return fiber.NewError(400, "Category "+category.Name+" is not an active expense category")
+	}
+	template.CategoryUUID = category.UUID
+
+	if err := template.SetLines(p.Lines); err != nil {
+		return err
+	}
+
+	frequency := strings.ToLower(p.Frequency)
+	if frequency == "" {
+		frequency = models.FrequencyMonthly
+	}
+	if !models.IsFrequency(frequency) {
+		return fiber.NewError(400, "frequency must be one of weekly, monthly, quarterly or yearly")
+	}
+
+	restart := template.NextRunDate.IsZero() || frequency != template.Frequency
+	template.Frequency = frequency
+	if p.StartDate != "" {
+		start, err := utils.ParseDateIn(p.StartDate, utils.OrgLocation())
+		if err != nil {
+			return fiber.NewError(400, "start_date: "+err.Error())
+		}
+		restart = restart || !start.Equal(template.StartDate)
+		template.StartDate = start
+	}
+	if restart {
+		// Sans nouvelle date de début, le calendrier repart de la prochaine échéance
+		if p.StartDate == "" {
+			template.StartDate = template.NextRunDate
+		}
+		template.Occurrences = 0
+		template.Schedule()
+	}
+
+	if p.EndDate != "" {
+		end, err := utils.ParseDateIn(p.EndDate, utils.OrgLocation())
+		if err != nil {
+			return fiber.NewError(400, "end_date: "+err.Error())
+		}
+		if end.Before(template.StartDate) {
+			return fiber.NewError(400, "end_date cannot be before start_date")
+		}
+		template.EndDate = &end
+	}
+	return nil
+}
+
+// recurringError répond avec le code d'une *fiber.Error, 400 pour des montants invalides, 500 sinon
+func recurringError(c *fiber.Ctx, err error, message string) error {
+	var fe *fiber.Error
+	switch {
+	case errors.As(err, &fe):
+		return c.Status(fe.Code).JSON(fiber.Map{
+			"status":  "error",
+			"message": fe.Message,
+			"data":    nil,
+		})
+	case errors.Is(err, models.ErrInvalidCaisseLines):
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+			"data":    nil,
+		})
+	}
+	return c.Status(500).JSON(fiber.Map{
+		"status":  "error",
+		"message": message,
+		"error":   err.Error(),
+	})
+}
@@ -0,0 +1,126 @@
+package tenants
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/kgermando/appartment-app-api/database"
+	"github.com/kgermando/appartment-app-api/models"
+	"github.com/kgermando/appartment-app-api/utils"
+)
+
+// Paginate
+func GetPaginatedTenants(c *fiber.Ctx) error {
+	db := database.DB
+
+	// Parse pagination and search filters
+	filter, err := utils.ParseQueryFilter(c, 15)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+			"data":    nil,
+		})
+	}
+
+	var tenants []models.Tenant
+	var totalRecords int64
+
+	// Count total records matching the search query
+	filter.ApplySearch(db.Model(&models.Tenant{}), "fullname", "telephone", "email").
+		Count(&totalRecords)
+
+	err = filter.Paginate(filter.ApplySearch(db, "fullname", "telephone", "email")).
+		Order("tenants.updated_at DESC").
+		Preload("Leases").
+		Find(&tenants).Error
+
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch Tenants",
+			"error":   err.Error(),
+		})
+	}
+
+	// Prepare pagination metadata
+	pagination := filter.Pagination(totalRecords)
+
+	// Return response
+	return c.JSON(fiber.Map{
+		"status":     "success",
+		"message":    "Tenants retrieved successfully",
+		"data":       tenants,
+		"pagination": pagination,
+	})
+}
+
+// Get one data
+func GetTenant(c *fiber.Ctx) error {
+	uuid := c.Params("uuid")
+	db := database.DB
+	var tenant models.Tenant
+	db.Where("uuid = ?", uuid).Preload("Leases").First(&tenant)
+	if tenant.UUID == "" {
+		return c.Status(404).JSON(
+			fiber.Map{
+				"status":  "error",
+				"message": "No Tenant found",
+				"data":    nil,
+			},
+		)
+	}
+	return c.JSON(
+		fiber.Map{
+			"status":  "success",
+			"message": "Tenant found",
+			"data":    tenant,
+		},
+	)
+}
+
+// Update data
+func UpdateTenant(c *fiber.Ctx) error {
+	uuid := c.Params("uuid")
+	db := database.DB
+
+	type UpdateDataInput struct {
+		Fullname  string `json:"fullname"`
+		Email     string `json:"email"`
+		Telephone string `json:"telephone"`
+	}
+
+	var updateData UpdateDataInput
+
+	if err := c.BodyParser(&updateData); err != nil {
+		return c.Status(400).JSON(
+			fiber.Map{
+				"status":  "error",
+				"message": "Review your input",
+				"data":    nil,
+			},
+		)
+	}
+
+	tenant := new(models.Tenant)
+
+	db.Where("uuid = ?", uuid).First(&tenant)
+	if tenant.UUID == "" {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "No Tenant found",
+			"data":    nil,
+		})
+	}
+	tenant.Fullname = updateData.Fullname
+	tenant.Email = updateData.Email
+	tenant.Telephone = updateData.Telephone
+
+	db.Save(&tenant)
+
+	return c.JSON(
+		fiber.Map{
+			"status":  "success",
+			"message": "Tenant updated success",
+			"data":    tenant,
+		},
+	)
+}
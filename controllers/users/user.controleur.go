@@ -1,8 +1,6 @@
 package users
 
 import (
-	"strconv"
-
 	"github.com/gofiber/fiber/v2"
 	"github.com/kgermando/appartment-app-api/database"
 	"github.com/kgermando/appartment-app-api/models"
@@ -14,32 +12,24 @@ import (
 func GetPaginatedUsers(c *fiber.Ctx) error {
 	db := database.DB
 
-	// Parse query parameters for pagination
-	page, err := strconv.Atoi(c.Query("page", "1"))
-	if err != nil || page <= 0 {
-		page = 1
-	}
-	limit, err := strconv.Atoi(c.Query("limit", "15"))
-	if err != nil || limit <= 0 {
-		limit = 15
+	// Parse pagination and search filters
+	filter, err := utils.ParseQueryFilter(c, 15)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+			"data":    nil,
+		})
 	}
-	offset := (page - 1) * limit
-
-	// Parse search query
-	search := c.Query("search", "")
 
 	var users []models.User
 	var totalRecords int64
 
 	// Count total records matching the search query
-	db.Model(&models.User{}).
-		Where("fullname ILIKE ? OR role ILIKE ?", "%"+search+"%", "%"+search+"%").
+	filter.ApplySearch(db.Model(&models.User{}), "fullname", "role").
 		Count(&totalRecords)
 
-	err = db.
-		Where("fullname ILIKE ? OR role ILIKE ?", "%"+search+"%", "%"+search+"%").
-		Offset(offset).
-		Limit(limit).
+	err = filter.Paginate(filter.ApplySearch(db, "fullname", "role")).
 		Order("users.updated_at DESC"). 
 		Find(&users).Error
 
@@ -51,16 +41,8 @@ func GetPaginatedUsers(c *fiber.Ctx) error {
 		})
 	}
 
-	// Calculate total pages
-	totalPages := int((totalRecords + int64(limit) - 1) / int64(limit))
-
-	//  Prepare pagination metadata
-	pagination := map[string]interface{}{
-		"total_records": totalRecords,
-		"total_pages":   totalPages,
-		"current_page":  page,
-		"page_size":     limit,
-	}
+	// Prepare pagination metadata
+	pagination := filter.Pagination(totalRecords)
 
 	// Return response
 	return c.JSON(fiber.Map{
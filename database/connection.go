@@ -30,10 +30,80 @@ func Connect() {
 	DB = connection
 	fmt.Println("Database Connected 🎉!")
 
+	// Les montants passent de double precision à des décimaux exacts
+	if err := models.MigrateMoneyColumns(connection); err != nil {
+		fmt.Println("Money columns migration failed:", err)
+	}
+
 	connection.AutoMigrate(
 		&models.User{},
 		&models.Appartment{},
+		&models.AppartmentPhoto{},
 		&models.Caisse{},
 		&models.PasswordReset{},
+		&models.Prospect{},
+		&models.Visit{},
+		&models.Tenant{},
+		&models.Lease{},
+		&models.Receipt{},
+		&models.ReceiptSequence{},
+		&models.CaisseSequence{},
+		&models.AccountingPeriod{},
+		&models.CashCount{},
+		&models.TreasuryAccount{},
+		&models.TreasuryTransfer{},
+		&models.Category{},
+		&models.Budget{},
+		&models.ExchangeRate{},
+		&models.CaisseLine{},
+		&models.DailySummary{},
+		&models.RecurringExpense{},
+		&models.RecurringExpenseLine{},
 	)
+
+	if err := models.MigrateExchangeRateIndex(connection); err != nil {
+		fmt.Println("Exchange rates index migration failed:", err)
+	}
+
+	if err := models.SeedCategories(connection); err != nil {
+		fmt.Println("Categories seed failed:", err)
+	}
+
+	if err := models.BackfillTransactionDates(connection); err != nil {
+		fmt.Println("Caisse transaction dates backfill failed:", err)
+	}
+
+	// Une petite caisse par appartement pour les entrées antérieures aux comptes de trésorerie
+	if err := models.BackfillTreasuryAccounts(connection); err != nil {
+		fmt.Println("Treasury accounts backfill failed:", err)
+	}
+
+	// Les montants USD et CDF des entrées existantes deviennent leurs lignes
+	if err := models.BackfillCaisseLines(connection); err != nil {
+		fmt.Println("Caisse lines backfill failed:", err)
+	}
+	updated, err := models.BackfillCaisseRates(connection)
+	if err != nil {
+		fmt.Println("Caisse exchange rates backfill failed:", err)
+	}
+
+	// Numéroter et chaîner les pièces de caisse existantes, une fois leurs lignes et leurs taux renseignés
+	if err := models.BackfillCaisseVouchers(connection); err != nil {
+		fmt.Println("Caisse vouchers backfill failed:", err)
+	}
+	if err := models.LinkCaissesToAccounts(connection); err != nil {
+		fmt.Println("Caisse accounts backfill failed:", err)
+	}
+
+	// Résumés journaliers des tableaux de bord : construits au premier démarrage,
+	// reconstruits si des taux viennent d'être renseignés
+	if updated > 0 {
+		err = models.RebuildDailySummaries(connection)
+	} else {
+		err = models.EnsureDailySummaries(connection)
+	}
+	if err != nil {
+		fmt.Println("Daily summaries build failed:", err)
+	}
+	connection.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_caisse_voucher ON caisses (register_uuid, voucher_year, voucher_number) WHERE voucher_number > 0")
 }
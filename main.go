@@ -4,12 +4,15 @@ import (
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/kgermando/appartment-app-api/database"
+	"github.com/kgermando/appartment-app-api/models"
 	"github.com/kgermando/appartment-app-api/routes"
+	"github.com/kgermando/appartment-app-api/utils"
 )
 
 func getPort() string {
@@ -23,11 +26,25 @@ func getPort() string {
 	return port
 }
 
+// recurringExpenseInterval lit l'intervalle du planificateur des sorties récurrentes
+// dans RECURRING_EXPENSE_INTERVAL (ex. 15m, 1h), une heure par défaut
+func recurringExpenseInterval() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("RECURRING_EXPENSE_INTERVAL")); err == nil && d > 0 {
+		return d
+	}
+	return time.Hour
+}
+
 func main() {
 
 	database.Connect()
 
-	app := fiber.New()
+	// Brouillons des sorties récurrentes arrivées à échéance
+	go models.ScheduleRecurringExpenses(database.DB, recurringExpenseInterval())
+
+	app := fiber.New(fiber.Config{
+		BodyLimit: 50 * 1024 * 1024, // Upload de photos
+	})
 
 	// Initialize default config
 	app.Use(logger.New())
@@ -47,6 +64,9 @@ func main() {
 		}, ","),
 	}))
 
+	// Photos et miniatures uploadées
+	app.Static("/uploads", utils.UploadDir())
+
 	routes.Setup(app)
 
 	log.Fatal(app.Listen(getPort()))
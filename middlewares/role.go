@@ -0,0 +1,49 @@
+package middlewares
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kgermando/appartment-app-api/database"
+	"github.com/kgermando/appartment-app-api/models"
+	"github.com/kgermando/appartment-app-api/utils"
+)
+
+// HasRole laisse passer les utilisateurs actifs ayant l'un des rôles donnés.
+// Le token est lu dans la query comme pour AuthUser, l'utilisateur est
+// disponible ensuite dans c.Locals("user").
+func HasRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userUUID, err := utils.VerifyJwt(c.Query("token"))
+		if err != nil || userUUID == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"status":  "error",
+				"message": "unauthenticated",
+				"data":    nil,
+			})
+		}
+
+		var user models.User
+		database.DB.Where("uuid = ?", userUUID).First(&user)
+		if user.UUID == "" || !user.Status {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"status":  "error",
+				"message": "unauthenticated",
+				"data":    nil,
+			})
+		}
+
+		for _, role := range roles {
+			if strings.EqualFold(user.Role, role) {
+				c.Locals("user", user)
+				return c.Next()
+			}
+		}
+
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": "This action requires one of the roles: " + strings.Join(roles, ", "),
+			"data":    nil,
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kgermando/appartment-app-api/utils"
+	"gorm.io/gorm"
+)
+
+// Statuts d'une période comptable
+const (
+	PeriodOpen   = "open"
+	PeriodClosed = "closed"
+)
+
+// ErrPeriodClosed est retournée quand une écriture tombe dans une période clôturée
+var ErrPeriodClosed = errors.New("accounting period is closed")
+
+// AccountingPeriod est un mois comptable. Une fois clôturé par un superviseur,
+// plus aucune entrée de caisse datée dans ce mois ne peut être créée ou modifiée.
+type AccountingPeriod struct {
+	UUID      string `gorm:"type:varchar(255);primary_key" json:"uuid"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+
+	Year  int `gorm:"not null;uniqueIndex:idx_period_year_month" json:"year"`
+	Month int `gorm:"not null;uniqueIndex:idx_period_year_month" json:"month"`
+
+	Status string `gorm:"type:varchar(20);default:'open'" json:"status"` // open, closed
+	Notes  string `json:"notes"`
+
+	ClosedAt     *time.Time `json:"closed_at"`
+	ClosedByUUID string     `gorm:"type:varchar(255)" json:"closed_by_uuid"`
+	ClosedBy     User       `gorm:"foreignKey:ClosedByUUID;references:UUID" json:"closed_by"`
+
+	Counts []CashCount `gorm:"foreignKey:PeriodUUID;references:UUID" json:"counts,omitempty"`
+}
+
+// CashCount est le comptage physique d'une caisse dans une devise à la clôture
+type CashCount struct {
+	UUID      string `gorm:"type:varchar(255);primary_key" json:"uuid"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	PeriodUUID   string `gorm:"type:varchar(255);not null;index" json:"period_uuid"`
+	RegisterUUID string `gorm:"type:varchar(255);not null" json:"register_uuid"`
+	Currency     string `gorm:"type:varchar(3);not null" json:"currency"` // Code ISO 4217
+
+	Expected utils.Amount `gorm:"default:0" json:"expected"` // Entrées - sorties cumulées à la fin de la période
+	Counted  utils.Amount `gorm:"default:0" json:"counted"`
+	Variance utils.Amount `gorm:"default:0" json:"variance"` // Counted - Expected
+}
+
+// PeriodBounds retourne le début et la fin (exclue) d'un mois, dans le fuseau de l'organisation
+func PeriodBounds(year, month int) (time.Time, time.Time) {
+	start := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, utils.OrgLocation())
+	return start, start.AddDate(0, 1, 0)
+}
+
+// EnsurePeriodOpen retourne ErrPeriodClosed si la date tombe dans une période clôturée
+func EnsurePeriodOpen(db *gorm.DB, t time.Time) error {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	t = t.In(utils.OrgLocation())
+
+	var count int64
+	err := db.Model(&AccountingPeriod{}).
+		Where("year = ? AND month = ? AND status = ?", t.Year(), int(t.Month()), PeriodClosed).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: %s", ErrPeriodClosed, t.Format("01/2006"))
+	}
+	return nil
+}
+
+// RegisterBalance est le solde attendu d'un compte de trésorerie, par devise
+type RegisterBalance struct {
+	RegisterUUID string                  `json:"register_uuid"`
+	RegisterName string                  `json:"register_name"`
+	Balances     map[string]utils.Amount `json:"balances"`
+}
+
+// ExpectedBalances calcule les soldes de chaque compte de trésorerie ouvert avant la date
+// donnée : soldes d'ouverture, lignes des entrées comptabilisées (Income - Expense) et transferts
+func ExpectedBalances(db *gorm.DB, before time.Time) ([]RegisterBalance, error) {
+	var accounts []TreasuryAccount
+	if err := db.Where("opening_date < ?", before).Order("name").Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+
+	balances := make([]RegisterBalance, 0, len(accounts))
+	for _, account := range accounts {
+		amounts, err := AccountBalance(db, account, before)
+		if err != nil {
+			return nil, err
+		}
+		balances = append(balances, RegisterBalance{
+			RegisterUUID: account.UUID,
+			RegisterName: account.Name,
+			Balances:     amounts,
+		})
+	}
+	return balances, nil
+}
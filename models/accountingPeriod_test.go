@@ -0,0 +1,77 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+// Les bornes des périodes sont les minuits du fuseau de l'organisation : une entrée de 23:30
+// le dernier jour du mois appartient au mois, une entrée de 00:30 le premier jour au suivant
+func TestPeriodBounds(t *testing.T) {
+	tests := []struct {
+		zone        string
+		year, month int
+		start, end  time.Time // Instants UTC attendus
+		inside      []time.Time
+		outside     []time.Time
+	}{
+		{
+			"Africa/Kinshasa", 2025, 1,
+			time.Date(2024, 12, 31, 23, 0, 0, 0, time.UTC), time.Date(2025, 1, 31, 23, 0, 0, 0, time.UTC),
+			[]time.Time{
+				time.Date(2024, 12, 31, 23, 30, 0, 0, time.UTC), // 1er janvier 00:30
+				time.Date(2025, 1, 31, 22, 30, 0, 0, time.UTC),  // 31 janvier 23:30
+			},
+			[]time.Time{
+				time.Date(2024, 12, 31, 22, 30, 0, 0, time.UTC), // 31 décembre 23:30
+				time.Date(2025, 1, 31, 23, 30, 0, 0, time.UTC),  // 1er février 00:30
+			},
+		},
+		{
+			"Africa/Kinshasa", 2024, 12,
+			time.Date(2024, 11, 30, 23, 0, 0, 0, time.UTC), time.Date(2024, 12, 31, 23, 0, 0, 0, time.UTC),
+			[]time.Time{time.Date(2024, 12, 31, 22, 30, 0, 0, time.UTC)},
+			[]time.Time{time.Date(2024, 12, 31, 23, 30, 0, 0, time.UTC)},
+		},
+		{
+			// Passage à l'heure d'été le 9 mars : le mois dure une heure de moins
+			"America/New_York", 2025, 3,
+			time.Date(2025, 3, 1, 5, 0, 0, 0, time.UTC), time.Date(2025, 4, 1, 4, 0, 0, 0, time.UTC),
+			[]time.Time{
+				time.Date(2025, 3, 1, 5, 30, 0, 0, time.UTC), // 1er mars 00:30
+				time.Date(2025, 4, 1, 3, 30, 0, 0, time.UTC), // 31 mars 23:30
+			},
+			[]time.Time{
+				time.Date(2025, 3, 1, 4, 30, 0, 0, time.UTC), // 28 février 23:30
+				time.Date(2025, 4, 1, 4, 30, 0, 0, time.UTC), // 1er avril 00:30
+			},
+		},
+		{
+			"UTC", 2025, 2,
+			time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+			[]time.Time{time.Date(2025, 2, 28, 23, 30, 0, 0, time.UTC)},
+			[]time.Time{time.Date(2025, 1, 31, 23, 30, 0, 0, time.UTC)},
+		},
+	}
+	for _, tt := range tests {
+		t.Setenv("ORG_TIMEZONE", tt.zone)
+		start, end := PeriodBounds(tt.year, tt.month)
+		if !start.Equal(tt.start) || !end.Equal(tt.end) {
+			t.Errorf("%s %d-%02d: PeriodBounds = [%s, %s[, want [%s, %s[",
+				tt.zone, tt.year, tt.month, start.UTC(), end.UTC(), tt.start, tt.end)
+		}
+		if start.Location().String() != tt.zone {
+			t.Errorf("%s %d-%02d: bounds are in %s", tt.zone, tt.year, tt.month, start.Location())
+		}
+		for _, at := range tt.inside {
+			if at.Before(start) || !at.Before(end) {
+				t.Errorf("%s %d-%02d: %s should be inside the period", tt.zone, tt.year, tt.month, at)
+			}
+		}
+		for _, at := range tt.outside {
+			if !at.Before(start) && at.Before(end) {
+				t.Errorf("%s %d-%02d: %s should be outside the period", tt.zone, tt.year, tt.month, at)
+			}
+		}
+	}
+}
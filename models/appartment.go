@@ -3,6 +3,7 @@ package models
 import (
 	"time"
 
+	"github.com/kgermando/appartment-app-api/utils"
 	"gorm.io/gorm"
 )
 
@@ -14,6 +15,7 @@ type Appartment struct {
 
 	Name   string `gorm:"not null" json:"name"`   // Locateur Ex. okapi
 	Number string `gorm:"not null" json:"number"` // Numero appartement Ex. 1201
+	Area   string `json:"area"`                   // Quartier / commune Ex. Gombe
 
 	// Caractéristiques physiques
 	Surface   float64 `gorm:"default:0" json:"surface"`       // Surface en m²
@@ -23,9 +25,9 @@ type Appartment struct {
 	Furnished bool    `gorm:"default:false" json:"furnished"` // Meublé ou non
 
 	// Informations financières
-	MonthlyRent   float64 `gorm:"not null;default:0" json:"monthly_rent" validate:"required,gt=0"`     // Loyer mensuel
-	GarantieMonth float64 `gorm:"not null;default:2" json:"garantie_month" validate:"required,gt=0"`   // Nombre de mois de garantie
-	Garantie      float64 `gorm:"not null;default:0" json:"garantie_montant" validate:"required,gt=0"` // Montant de la garantie
+	MonthlyRent   utils.Amount `gorm:"not null;default:0" json:"monthly_rent" validate:"required,gt=0"`     // Loyer mensuel
+	GarantieMonth float64      `gorm:"not null;default:2" json:"garantie_month" validate:"required,gt=0"`   // Nombre de mois de garantie
+	Garantie      utils.Amount `gorm:"not null;default:0" json:"garantie_montant" validate:"required,gt=0"` // Montant de la garantie
 
 	// Date d'échéance
 	Echeance time.Time `json:"echeance"` // Date de paiement loyer
@@ -38,5 +40,9 @@ type Appartment struct {
 	Manager     User   `gorm:"foreignKey:ManagerUUID;references:UUID" json:"manager"`
 
 	// Relations inverses
-	Caisses []Caisse `gorm:"foreignKey:AppartmentUUID;references:UUID" json:"caisses,omitempty"`
+	Caisses []Caisse          `gorm:"foreignKey:AppartmentUUID;references:UUID" json:"caisses,omitempty"`
+	Photos  []AppartmentPhoto `gorm:"foreignKey:AppartmentUUID;references:UUID" json:"photos,omitempty"`
+
+	// Miniature de la photo de couverture (calculée, non stockée)
+	CoverThumbnailURL string `gorm:"-" json:"cover_thumbnail_url"`
 }
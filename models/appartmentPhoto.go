@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type AppartmentPhoto struct {
+	UUID      string `gorm:"type:varchar(255);primary_key" json:"uuid"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+
+	AppartmentUUID string `gorm:"type:varchar(255);not null;index" json:"appartment_uuid"`
+
+	FileName    string `gorm:"not null" json:"file_name"` // Nom du fichier envoyé
+	ContentType string `json:"content_type"`              // image/jpeg, image/png, image/gif
+	Size        int64  `json:"size"`                      // Taille en octets
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+
+	// Chemins relatifs au dossier d'upload
+	Path          string `gorm:"not null" json:"-"`
+	ThumbnailPath string `gorm:"not null" json:"-"`
+
+	URL          string `gorm:"not null" json:"url"`
+	ThumbnailURL string `gorm:"not null" json:"thumbnail_url"`
+
+	Position int  `gorm:"default:0" json:"position"`     // Ordre d'affichage dans la galerie
+	IsCover  bool `gorm:"default:false" json:"is_cover"` // Photo de couverture
+}
@@ -3,9 +3,18 @@ package models
 import (
 	"time"
 
+	"github.com/kgermando/appartment-app-api/utils"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
+// Statuts d'une entrée de caisse
+const (
+	CaisseDraft    = "draft"    // Brouillon : modifiable et supprimable, non comptabilisé
+	CaissePosted   = "posted"   // Comptabilisé : numéroté et chaîné
+	CaisseReversed = "reversed" // Comptabilisé puis annulé par une contre-passation
+)
+
 type Caisse struct {
 	UUID      string `gorm:"type:varchar(255);primary_key;not null" json:"uuid"`
 	CreatedAt time.Time
@@ -15,13 +24,68 @@ type Caisse struct {
 	AppartmentUUID string     `gorm:"type:varchar(255);not null" json:"appartment_uuid"`
 	Appartment     Appartment `gorm:"foreignKey:AppartmentUUID;references:UUID" json:"appartment"`
 
-	Type      string  `gorm:"type:varchar(20);not null" json:"type"` // Entrees et Sorties (Income/Expense)
-	DeviceCDF float64 `gorm:"default:0" json:"device_cdf"`
-	DeviceUSD float64 `gorm:"default:0" json:"device_usd"`
+	Type string `gorm:"type:varchar(20);not null" json:"type"` // Entrees et Sorties (Income/Expense)
+
+	// Montants de l'entrée, une ligne par montant et par devise
+	Lines []CaisseLine `gorm:"foreignKey:CaisseUUID;references:UUID" json:"lines"`
+
+	// Totaux USD et CDF des lignes (voir SetLines), repris par les comptes de trésorerie et les budgets
+	DeviceCDF utils.Amount `gorm:"default:0" json:"device_cdf"`
+	DeviceUSD utils.Amount `gorm:"default:0" json:"device_usd"`
+
+	// Taux USD/CDF en vigueur à la date d'opération, figé à la comptabilisation
+	ExchangeRate     decimal.Decimal `gorm:"type:numeric(20,6);default:0" json:"exchange_rate"` // CDF pour 1 USD, 0 si aucun taux n'était défini
+	ExchangeRateUUID string          `gorm:"type:varchar(255)" json:"exchange_rate_uuid"`
 
 	Motif string `gorm:"not null" json:"motif"`
 
 	Signature string `gorm:"not null" json:"signature"` // Pour savoir qui q fait des entrees et des sorties
+
+	// Date de l'opération, utilisée par les filtres et les rapports (CreatedAt est la date de saisie)
+	TransactionDate time.Time  `gorm:"index" json:"transaction_date"`
+	ValueDate       *time.Time `json:"value_date"` // Date de valeur en banque ou mobile money, facultative
+
+	// Catégorie (loyer, réparations...) du même type que l'entrée
+	CategoryUUID string   `gorm:"type:varchar(255);index" json:"category_uuid"`
+	Category     Category `gorm:"foreignKey:CategoryUUID;references:UUID" json:"category"`
+
+	// Compte de trésorerie (caisse, banque, mobile money) de l'entrée
+	AccountUUID string `gorm:"type:varchar(255);index" json:"account_uuid"`
+
+	// Numérotation des pièces de caisse, sans trou, par caisse et par année
+	RegisterUUID  string `gorm:"type:varchar(255);index" json:"register_uuid"` // Registre de la pièce, le compte de trésorerie
+	VoucherYear   int    `gorm:"default:0" json:"voucher_year"`
+	VoucherNumber int    `gorm:"default:0" json:"voucher_number"`
+	Voucher       string `gorm:"type:varchar(30)" json:"voucher"` // Ex. 2025-000042
+
+	// Chaînage : chaque pièce contient l'empreinte de la précédente
+	PrevHash string `gorm:"type:varchar(64)" json:"prev_hash"`
+	Hash     string `gorm:"type:varchar(64)" json:"hash"`
+	// Liste de champs de l'empreinte (voir ComputeHash), 1 pour les pièces antérieures aux versions
+	HashVersion int `gorm:"not null;default:1" json:"hash_version"`
+
+	Status   string     `gorm:"type:varchar(20);default:'posted';index" json:"status"` // draft, posted, reversed
+	PostedAt *time.Time `json:"posted_at"`
+
+	// Contre-passation : l'entrée annulée et l'entrée qui l'annule se référencent
+	ReversalOfUUID string `gorm:"type:varchar(255);index" json:"reversal_of_uuid"`
+	ReversedByUUID string `gorm:"type:varchar(255)" json:"reversed_by_uuid"`
+	ReversalReason string `json:"reversal_reason"`
+
+	// Sortie récurrente dont le planificateur a créé l'entrée
+	RecurringExpenseUUID string `gorm:"type:varchar(255);index" json:"recurring_expense_uuid"`
+}
+
+// PostedCaisses limite une requête aux entrées comptabilisées. Les entrées annulées
+// restent comptées : leur contre-passation, de montants opposés, les neutralise.
+func PostedCaisses(db *gorm.DB) *gorm.DB {
+	return db.Where("caisses.status <> ?", CaisseDraft)
+}
+
+// BackfillTransactionDates date les entrées antérieures au champ TransactionDate
+// de leur date de saisie
+func BackfillTransactionDates(db *gorm.DB) error {
+	return db.Exec("UPDATE caisses SET transaction_date = created_at WHERE transaction_date IS NULL OR transaction_date < '1900-01-01'").Error
 }
 
 // ValidateType validates that the Type field contains only allowed values
@@ -0,0 +1,173 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kgermando/appartment-app-api/utils"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidCaisseLines est retournée quand les montants d'une entrée sont invalides
+var ErrInvalidCaisseLines = errors.New("invalid caisse lines")
+
+// CaisseLine est un montant d'une entrée de caisse dans une devise (code ISO 4217).
+// Une entrée peut avoir plusieurs lignes, dans la même devise ou non.
+type CaisseLine struct {
+	UUID      string `gorm:"type:varchar(255);primary_key" json:"uuid"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	CaisseUUID string       `gorm:"type:varchar(255);not null;index" json:"caisse_uuid"`
+	Currency   string       `gorm:"type:varchar(3);not null;index" json:"currency"`
+	Amount     utils.Amount `gorm:"not null;default:0" json:"amount"`
+
+	// Taux de la devise pour 1 USD en vigueur à la date d'opération, figé à la comptabilisation
+	ExchangeRate     decimal.Decimal `gorm:"type:numeric(20,6);default:0" json:"exchange_rate"` // 1 pour USD, 0 si aucun taux n'était défini
+	ExchangeRateUUID string          `gorm:"type:varchar(255)" json:"exchange_rate_uuid"`
+}
+
+// Money retourne le montant de la ligne avec sa devise
+func (l CaisseLine) Money() utils.Money {
+	return utils.NewMoney(l.Amount, l.Currency)
+}
+
+// SetLines contrôle et attache les lignes à l'entrée, puis met à jour ses totaux USD et CDF.
+// Sans ligne, les montants device_usd et device_cdf de la saisie deviennent les lignes.
+// Les taux sont remis à zéro : ils sont figés à la comptabilisation.
+func (c *Caisse) SetLines(lines []CaisseLine) error {
+	if len(lines) == 0 {
+		if !c.DeviceUSD.IsZero() {
+			lines = append(lines, CaisseLine{Currency: utils.CurrencyUSD, Amount: c.DeviceUSD})
+		}
+		if !c.DeviceCDF.IsZero() {
+			lines = append(lines, CaisseLine{Currency: utils.CurrencyCDF, Amount: c.DeviceCDF})
+		}
+	}
+	if len(lines) == 0 {
+		return fmt.Errorf("%w: at least one amount is required", ErrInvalidCaisseLines)
+	}
+
+	for i := range lines {
+		line := &lines[i]
+		line.Currency = strings.ToUpper(strings.TrimSpace(line.Currency))
+		if !utils.IsCurrency(line.Currency) {
+			return fmt.Errorf("%w: currency '%s' is not enabled", ErrInvalidCaisseLines, line.Currency)
+		}
+		if !line.Amount.IsPositive() {
+			return fmt.Errorf("%w: amounts must be greater than 0", ErrInvalidCaisseLines)
+		}
+		if line.UUID == "" {
+			line.UUID = utils.GenerateUUID()
+		}
+		line.CaisseUUID = c.UUID
+		line.ExchangeRate = decimal.Zero
+		line.ExchangeRateUUID = ""
+	}
+
+	c.Lines = lines
+	c.SyncLineTotals()
+	return nil
+}
+
+// SyncLineTotals recalcule les totaux USD et CDF de l'entrée à partir de ses lignes
+func (c *Caisse) SyncLineTotals() {
+	totals := c.TotalsByCurrency()
+	c.DeviceUSD = totals[utils.CurrencyUSD]
+	c.DeviceCDF = totals[utils.CurrencyCDF]
+}
+
+// TotalsByCurrency additionne les lignes de l'entrée par devise
+func (c *Caisse) TotalsByCurrency() map[string]utils.Amount {
+	totals := make(map[string]utils.Amount, len(c.Lines))
+	for _, line := range c.Lines {
+		totals[line.Currency] = totals[line.Currency].Add(line.Amount)
+	}
+	return totals
+}
+
+// Currencies retourne les devises des lignes : USD et CDF en premier, puis les autres triées
+func (c *Caisse) Currencies() []string {
+	return CurrenciesOf(c.TotalsByCurrency())
+}
+
+// otherCurrencies retourne, triées, les devises des lignes autres que USD et CDF
+func (c *Caisse) otherCurrencies() []string {
+	return otherCurrencies(c.TotalsByCurrency())
+}
+
+// CurrenciesOf retourne les devises des montants : USD et CDF en premier, puis les autres triées
+func CurrenciesOf(amounts map[string]utils.Amount) []string {
+	var currencies []string
+	for _, currency := range []string{utils.CurrencyUSD, utils.CurrencyCDF} {
+		if _, ok := amounts[currency]; ok {
+			currencies = append(currencies, currency)
+		}
+	}
+	return append(currencies, otherCurrencies(amounts)...)
+}
+
+func otherCurrencies(amounts map[string]utils.Amount) []string {
+	var currencies []string
+	for currency := range amounts {
+		if currency != utils.CurrencyUSD && currency != utils.CurrencyCDF {
+			currencies = append(currencies, currency)
+		}
+	}
+	sort.Strings(currencies)
+	return currencies
+}
+
+// SaveCaisseLines remplace les lignes enregistrées de l'entrée par ses lignes actuelles
+func SaveCaisseLines(tx *gorm.DB, caisse *Caisse) error {
+	if err := tx.Where("caisse_uuid = ?", caisse.UUID).Delete(&CaisseLine{}).Error; err != nil {
+		return err
+	}
+	if len(caisse.Lines) == 0 {
+		return nil
+	}
+	for i := range caisse.Lines {
+		caisse.Lines[i].CaisseUUID = caisse.UUID
+	}
+	return tx.Create(&caisse.Lines).Error
+}
+
+// BackfillCaisseLines crée les lignes USD et CDF des entrées enregistrées avant les lignes,
+// avec les taux qu'elles portent
+func BackfillCaisseLines(db *gorm.DB) error {
+	var pending []Caisse
+	return db.Unscoped().
+		Select("uuid", "created_at", "device_usd", "device_cdf", "exchange_rate", "exchange_rate_uuid").
+		Where("NOT EXISTS (SELECT 1 FROM caisse_lines WHERE caisse_lines.caisse_uuid = caisses.uuid)").
+		FindInBatches(&pending, 500, func(tx *gorm.DB, batch int) error {
+			var lines []CaisseLine
+			for _, caisse := range pending {
+				line := CaisseLine{CreatedAt: caisse.CreatedAt, UpdatedAt: caisse.CreatedAt, CaisseUUID: caisse.UUID}
+				if !caisse.DeviceUSD.IsZero() {
+					usd := line
+					usd.UUID = utils.GenerateUUID()
+					usd.Currency = utils.CurrencyUSD
+					usd.Amount = caisse.DeviceUSD
+					usd.ExchangeRate = decimal.NewFromInt(1)
+					lines = append(lines, usd)
+				}
+				if !caisse.DeviceCDF.IsZero() {
+					cdf := line
+					cdf.UUID = utils.GenerateUUID()
+					cdf.Currency = utils.CurrencyCDF
+					cdf.Amount = caisse.DeviceCDF
+					cdf.ExchangeRate = caisse.ExchangeRate
+					cdf.ExchangeRateUUID = caisse.ExchangeRateUUID
+					lines = append(lines, cdf)
+				}
+			}
+			if len(lines) == 0 {
+				return nil
+			}
+			return db.Create(&lines).Error
+		}).Error
+}
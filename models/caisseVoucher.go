@@ -0,0 +1,357 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kgermando/appartment-app-api/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CaisseSequence garde le dernier numéro de pièce attribué par caisse et par année
+type CaisseSequence struct {
+	RegisterUUID string `gorm:"type:varchar(255);primary_key" json:"register_uuid"`
+	Year         int    `gorm:"primary_key;autoIncrement:false" json:"year"`
+	LastNumber   int    `gorm:"not null;default:0" json:"last_number"`
+}
+
+// CaisseChainIssue décrit une anomalie trouvée dans la chaîne d'une caisse
+type CaisseChainIssue struct {
+	CaisseUUID    string `json:"caisse_uuid"`
+	VoucherNumber int    `json:"voucher_number"`
+	Problem       string `json:"problem"` // altered, broken_link, gap, deleted
+	Message       string `json:"message"`
+}
+
+// CaisseChainReport est le résultat de la vérification d'une caisse pour une année
+type CaisseChainReport struct {
+	RegisterUUID string             `json:"register_uuid"`
+	Year         int                `json:"year"`
+	Entries      int                `json:"entries"`
+	LastNumber   int                `json:"last_number"`
+	Valid        bool               `json:"valid"`
+	Issues       []CaisseChainIssue `json:"issues"`
+}
+
+// Versions de l'empreinte des pièces. Une pièce garde la version avec laquelle elle a été
+// chaînée, la vérification recalcule son empreinte avec la même liste de champs.
+const (
+	CaisseHashV1 = 1 // Totaux USD et CDF et montants des autres devises
+	CaisseHashV2 = 2 // V1, la date d'opération, le taux de l'entrée et chaque ligne avec son taux
+
+	// CaisseHashVersion est la version des pièces chaînées aujourd'hui
+	CaisseHashVersion = CaisseHashV2
+)
+
+// ComputeHash calcule l'empreinte SHA-256 de la pièce à partir de ses données
+// et de l'empreinte de la pièce précédente, selon sa version HashVersion
+func (c *Caisse) ComputeHash() string {
+	fields := []string{
+		c.PrevHash,
+		c.UUID,
+		c.RegisterUUID,
+		strconv.Itoa(c.VoucherYear),
+		strconv.Itoa(c.VoucherNumber),
+		c.AppartmentUUID,
+		c.Type,
+		strconv.FormatFloat(c.DeviceUSD.Float64(), 'f', -1, 64), // Même format qu'avant le passage en décimal
+		strconv.FormatFloat(c.DeviceCDF.Float64(), 'f', -1, 64),
+		c.Motif,
+		c.Signature,
+		c.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+	// Les montants dans d'autres devises que USD et CDF suivent, pour que les pièces
+	// antérieures aux lignes gardent leur empreinte
+	totals := c.TotalsByCurrency()
+	for _, currency := range c.otherCurrencies() {
+		fields = append(fields, currency+" "+totals[currency].String())
+	}
+	if c.HashVersion >= CaisseHashV2 {
+		// Postgres conserve les microsecondes et six décimales des taux
+		fields = append(fields,
+			c.TransactionDate.Truncate(time.Microsecond).UTC().Format(time.RFC3339Nano),
+			c.ExchangeRate.StringFixed(6),
+			c.ExchangeRateUUID,
+		)
+		lines := make([]string, len(c.Lines))
+		for i, line := range c.Lines {
+			lines[i] = strings.Join([]string{
+				line.Currency, line.Amount.String(), line.ExchangeRate.StringFixed(6), line.ExchangeRateUUID,
+			}, " ")
+		}
+		// L'ordre des lignes en base n'est pas garanti
+		sort.Strings(lines)
+		fields = append(fields, lines...)
+	}
+	sum := sha256.Sum256([]byte(strings.Join(fields, "|")))
+	return hex.EncodeToString(sum[:])
+}
+
+// lockCaisseSequence verrouille le compteur de la caisse pour l'année jusqu'à la fin de la transaction
+func lockCaisseSequence(tx *gorm.DB, registerUUID string, year int) (*CaisseSequence, error) {
+	err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&CaisseSequence{RegisterUUID: registerUUID, Year: year}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var seq CaisseSequence
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("register_uuid = ? AND year = ?", registerUUID, year).
+		First(&seq).Error
+	return &seq, err
+}
+
+// AssignVoucher attribue le prochain numéro de pièce, chaîne l'entrée à la précédente
+// et la marque comptabilisée. Doit être appelé dans la transaction qui crée (ou
+// comptabilise) l'entrée, juste avant l'écriture.
+func AssignVoucher(tx *gorm.DB, caisse *Caisse) error {
+	if caisse.RegisterUUID == "" {
+		caisse.RegisterUUID = caisse.AccountUUID
+	}
+	if caisse.RegisterUUID == "" {
+		caisse.RegisterUUID = caisse.AppartmentUUID
+	}
+	if caisse.CreatedAt.IsZero() {
+		caisse.CreatedAt = time.Now()
+	}
+	// Postgres conserve les microsecondes : arrondir avant de calculer l'empreinte
+	caisse.CreatedAt = caisse.CreatedAt.Truncate(time.Microsecond)
+	// L'année de la pièce est celle de l'organisation, pas celle du fuseau du serveur
+	caisse.VoucherYear = caisse.CreatedAt.In(utils.OrgLocation()).Year()
+	if caisse.TransactionDate.IsZero() {
+		caisse.TransactionDate = caisse.CreatedAt
+	}
+	caisse.TransactionDate = caisse.TransactionDate.Truncate(time.Microsecond)
+	if err := ApplyExchangeRate(tx, caisse); err != nil {
+		return err
+	}
+
+	seq, err := lockCaisseSequence(tx, caisse.RegisterUUID, caisse.VoucherYear)
+	if err != nil {
+		return err
+	}
+
+	caisse.PrevHash = ""
+	if seq.LastNumber > 0 {
+		var prev Caisse
+		err := tx.Unscoped().Select("hash").
+			Where("register_uuid = ? AND voucher_year = ? AND voucher_number = ?", seq.RegisterUUID, seq.Year, seq.LastNumber).
+			First(&prev).Error
+		if err != nil {
+			return err
+		}
+		caisse.PrevHash = prev.Hash
+	}
+
+	seq.LastNumber++
+	err = tx.Model(&CaisseSequence{}).
+		Where("register_uuid = ? AND year = ?", seq.RegisterUUID, seq.Year).
+		Update("last_number", seq.LastNumber).Error
+	if err != nil {
+		return err
+	}
+
+	caisse.VoucherNumber = seq.LastNumber
+	caisse.Voucher = fmt.Sprintf("%d-%06d", caisse.VoucherYear, caisse.VoucherNumber)
+	caisse.HashVersion = CaisseHashVersion
+	caisse.Hash = caisse.ComputeHash()
+
+	caisse.Status = CaissePosted
+	if caisse.PostedAt == nil {
+		now := time.Now()
+		caisse.PostedAt = &now
+	}
+	return nil
+}
+
+// IsLastVoucher indique si la pièce est la dernière de sa chaîne. Le compteur est
+// verrouillé, aucune pièce ne peut donc être ajoutée avant la fin de la transaction.
+func IsLastVoucher(tx *gorm.DB, caisse *Caisse) (bool, error) {
+	if caisse.VoucherNumber == 0 {
+		return true, nil
+	}
+	seq, err := lockCaisseSequence(tx, caisse.RegisterUUID, caisse.VoucherYear)
+	if err != nil {
+		return false, err
+	}
+	return seq.LastNumber == caisse.VoucherNumber, nil
+}
+
+// BackfillCaisseVouchers numérote et chaîne les entrées enregistrées avant la numérotation,
+// dans l'ordre de création. Les lignes et les taux des entrées doivent déjà être renseignés
+// (BackfillCaisseLines, BackfillCaisseRates) : ils entrent dans l'empreinte.
+func BackfillCaisseVouchers(db *gorm.DB) error {
+	var pending []Caisse
+	if err := db.Unscoped().Preload("Lines").Where("(voucher_number = 0 OR voucher_number IS NULL) AND status <> ?", CaisseDraft).Order("created_at ASC").Find(&pending).Error; err != nil {
+		return err
+	}
+
+	for i := range pending {
+		caisse := &pending[i]
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := AssignVoucher(tx, caisse); err != nil {
+				return err
+			}
+			// Enregistrer les taux appliqués par AssignVoucher avec l'empreinte qui les couvre
+			for _, line := range caisse.Lines {
+				err := tx.Model(&CaisseLine{}).Where("uuid = ?", line.UUID).Updates(map[string]interface{}{
+					"exchange_rate":      line.ExchangeRate,
+					"exchange_rate_uuid": line.ExchangeRateUUID,
+				}).Error
+				if err != nil {
+					return err
+				}
+			}
+			return tx.Unscoped().Model(&Caisse{}).Where("uuid = ?", caisse.UUID).Updates(map[string]interface{}{
+				"register_uuid":      caisse.RegisterUUID,
+				"created_at":         caisse.CreatedAt,
+				"transaction_date":   caisse.TransactionDate,
+				"exchange_rate":      caisse.ExchangeRate,
+				"exchange_rate_uuid": caisse.ExchangeRateUUID,
+				"voucher_year":       caisse.VoucherYear,
+				"voucher_number":     caisse.VoucherNumber,
+				"voucher":            caisse.Voucher,
+				"prev_hash":          caisse.PrevHash,
+				"hash":               caisse.Hash,
+				"hash_version":       caisse.HashVersion,
+			}).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyCaisseChains recalcule les empreintes de toutes les pièces (supprimées comprises)
+// et signale les pièces modifiées, les liens rompus et les numéros manquants.
+// registerUUID et year sont optionnels (vide / 0 pour tout vérifier).
+func VerifyCaisseChains(db *gorm.DB, registerUUID string, year int) ([]CaisseChainReport, error) {
+	query := db.Unscoped().Model(&Caisse{}).Where("voucher_number > 0")
+	if registerUUID != "" {
+		query = query.Where("register_uuid = ?", registerUUID)
+	}
+	if year > 0 {
+		query = query.Where("voucher_year = ?", year)
+	}
+
+	// Lignes des pièces vérifiées : toutes les lignes entrent dans l'empreinte V2
+	var lines []CaisseLine
+	err := db.Where("caisse_uuid IN (?)", query.Session(&gorm.Session{}).Select("uuid")).
+		Order("caisse_uuid, created_at").
+		Find(&lines).Error
+	if err != nil {
+		return nil, err
+	}
+	linesByCaisse := make(map[string][]CaisseLine)
+	for _, line := range lines {
+		linesByCaisse[line.CaisseUUID] = append(linesByCaisse[line.CaisseUUID], line)
+	}
+
+	rows, err := query.Order("register_uuid, voucher_year, voucher_number").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []CaisseChainReport{}
+	var report *CaisseChainReport
+	var prevHash string
+	var prevNumber int
+
+	for rows.Next() {
+		var entry Caisse
+		if err := db.ScanRows(rows, &entry); err != nil {
+			return nil, err
+		}
+		entry.Lines = linesByCaisse[entry.UUID]
+
+		if report == nil || report.RegisterUUID != entry.RegisterUUID || report.Year != entry.VoucherYear {
+			reports = append(reports, CaisseChainReport{
+				RegisterUUID: entry.RegisterUUID,
+				Year:         entry.VoucherYear,
+				Valid:        true,
+				Issues:       []CaisseChainIssue{},
+			})
+			report = &reports[len(reports)-1]
+			prevHash, prevNumber = "", 0
+		}
+
+		issue := func(problem, message string) {
+			report.Issues = append(report.Issues, CaisseChainIssue{
+				CaisseUUID:    entry.UUID,
+				VoucherNumber: entry.VoucherNumber,
+				Problem:       problem,
+				Message:       message,
+			})
+			if problem != "deleted" {
+				report.Valid = false
+			}
+		}
+
+		if entry.VoucherNumber != prevNumber+1 {
+			issue("gap", fmt.Sprintf("vouchers %d to %d are missing", prevNumber+1, entry.VoucherNumber-1))
+		}
+		if entry.PrevHash != prevHash {
+			issue("broken_link", "prev_hash does not match the hash of the previous voucher")
+		}
+		if entry.ComputeHash() != entry.Hash {
+			issue("altered", "the content of the voucher does not match its hash")
+		}
+		if entry.DeletedAt.Valid {
+			issue("deleted", "the voucher was deleted on "+entry.DeletedAt.Time.Format("2006-01-02 15:04"))
+		}
+
+		report.Entries++
+		report.LastNumber = entry.VoucherNumber
+		prevHash, prevNumber = entry.Hash, entry.VoucherNumber
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Comparer avec les compteurs : des pièces en fin de chaîne ont pu disparaître
+	seqQuery := db.Model(&CaisseSequence{}).Where("last_number > 0")
+	if registerUUID != "" {
+		seqQuery = seqQuery.Where("register_uuid = ?", registerUUID)
+	}
+	if year > 0 {
+		seqQuery = seqQuery.Where("year = ?", year)
+	}
+	var sequences []CaisseSequence
+	if err := seqQuery.Find(&sequences).Error; err != nil {
+		return nil, err
+	}
+
+	for _, seq := range sequences {
+		var found *CaisseChainReport
+		for i := range reports {
+			if reports[i].RegisterUUID == seq.RegisterUUID && reports[i].Year == seq.Year {
+				found = &reports[i]
+				break
+			}
+		}
+		if found == nil {
+			reports = append(reports, CaisseChainReport{RegisterUUID: seq.RegisterUUID, Year: seq.Year, Issues: []CaisseChainIssue{}})
+			found = &reports[len(reports)-1]
+		}
+		if seq.LastNumber > found.LastNumber {
+			found.Valid = false
+			found.Issues = append(found.Issues, CaisseChainIssue{
+				VoucherNumber: found.LastNumber + 1,
+				Problem:       "gap",
+				Message:       fmt.Sprintf("vouchers %d to %d are missing", found.LastNumber+1, seq.LastNumber),
+			})
+		}
+	}
+
+	return reports, nil
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/kgermando/appartment-app-api/utils"
+	"github.com/shopspring/decimal"
+)
+
+func hashTestCaisse(version int) *Caisse {
+	return &Caisse{
+		UUID:             "c1",
+		PrevHash:         "prev",
+		RegisterUUID:     "r1",
+		VoucherYear:      2025,
+		VoucherNumber:    42,
+		AppartmentUUID:   "a1",
+		Type:             "Income",
+		DeviceUSD:        utils.NewAmount(100),
+		DeviceCDF:        utils.NewAmount(28000),
+		ExchangeRate:     decimal.NewFromInt(2800),
+		ExchangeRateUUID: "rate-cdf",
+		Motif:            "Loyer",
+		Signature:        "Agent",
+		CreatedAt:        time.Date(2025, 3, 1, 8, 30, 0, 0, time.UTC),
+		TransactionDate:  time.Date(2025, 2, 28, 22, 30, 0, 0, time.UTC),
+		HashVersion:      version,
+		Lines: []CaisseLine{
+			{Currency: utils.CurrencyUSD, Amount: utils.NewAmount(100), ExchangeRate: decimal.NewFromInt(1)},
+			{Currency: utils.CurrencyCDF, Amount: utils.NewAmount(28000), ExchangeRate: decimal.NewFromInt(2800), ExchangeRateUUID: "rate-cdf"},
+			{Currency: "EUR", Amount: utils.NewAmount(50), ExchangeRate: decimal.RequireFromString("0.92"), ExchangeRateUUID: "rate-eur"},
+		},
+	}
+}
+
+// Les pièces chaînées avant les versions doivent garder leur empreinte
+func TestComputeHashV1KeepsLegacyFields(t *testing.T) {
+	legacy := "prev|c1|r1|2025|42|a1|Income|100|28000|Loyer|Agent|2025-03-01T08:30:00Z|EUR 50.00"
+	sum := sha256.Sum256([]byte(legacy))
+	want := hex.EncodeToString(sum[:])
+
+	c := hashTestCaisse(CaisseHashV1)
+	if got := c.ComputeHash(); got != want {
+		t.Fatalf("v1 hash = %s, want %s", got, want)
+	}
+	// Les champs ajoutés en V2 ne changent pas une empreinte V1
+	c.TransactionDate = c.TransactionDate.AddDate(0, 0, 1)
+	c.ExchangeRate = decimal.NewFromInt(2900)
+	c.Lines[1].ExchangeRate = decimal.NewFromInt(2900)
+	if got := c.ComputeHash(); got != want {
+		t.Errorf("v1 hash changed with v2 fields: %s, want %s", got, want)
+	}
+}
+
+func TestComputeHashV2CoversLinesRatesAndDate(t *testing.T) {
+	base := hashTestCaisse(CaisseHashV2).ComputeHash()
+	if base == hashTestCaisse(CaisseHashV1).ComputeHash() {
+		t.Fatal("v2 hash equals the v1 hash")
+	}
+
+	tests := []struct {
+		name   string
+		change func(c *Caisse)
+	}{
+		{"transaction date", func(c *Caisse) { c.TransactionDate = c.TransactionDate.Add(time.Hour) }},
+		{"entry rate", func(c *Caisse) { c.ExchangeRate = decimal.NewFromInt(2850) }},
+		{"entry rate uuid", func(c *Caisse) { c.ExchangeRateUUID = "rate-other" }},
+		{"line rate", func(c *Caisse) { c.Lines[2].ExchangeRate = decimal.RequireFromString("0.93") }},
+		{"line rate uuid", func(c *Caisse) { c.Lines[2].ExchangeRateUUID = "rate-other" }},
+		{"line currency", func(c *Caisse) { c.Lines[2].Currency = "ZAR" }},
+		{"USD line amount", func(c *Caisse) { c.Lines[0].Amount = utils.NewAmount(90) }},
+		{"CDF line amount", func(c *Caisse) { c.Lines[1].Amount = utils.NewAmount(27000) }},
+		{"extra line", func(c *Caisse) {
+			c.Lines = append(c.Lines, CaisseLine{Currency: utils.CurrencyUSD, Amount: utils.NewAmount(0)})
+		}},
+	}
+	for _, tt := range tests {
+		c := hashTestCaisse(CaisseHashV2)
+		tt.change(c)
+		if c.ComputeHash() == base {
+			t.Errorf("v2 hash does not cover the %s", tt.name)
+		}
+	}
+}
+
+func TestComputeHashV2IgnoresLineOrderAndStoragePrecision(t *testing.T) {
+	want := hashTestCaisse(CaisseHashV2).ComputeHash()
+
+	c := hashTestCaisse(CaisseHashV2)
+	c.Lines[0], c.Lines[2] = c.Lines[2], c.Lines[0]
+	if got := c.ComputeHash(); got != want {
+		t.Errorf("v2 hash depends on the line order")
+	}
+
+	// Relu depuis Postgres : microsecondes, numeric(20,6), autre fuseau
+	c = hashTestCaisse(CaisseHashV2)
+	c.TransactionDate = c.TransactionDate.Add(400 * time.Nanosecond).In(time.FixedZone("WAT", 3600))
+	c.ExchangeRate = decimal.RequireFromString("2800.000000")
+	if got := c.ComputeHash(); got != want {
+		t.Errorf("v2 hash changes once stored: %s, want %s", got, want)
+	}
+}
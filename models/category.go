@@ -0,0 +1,134 @@
+package models
+
+import (
+	"time"
+
+	"github.com/kgermando/appartment-app-api/utils"
+	"gorm.io/gorm"
+)
+
+// Catégories par défaut des entrées sans catégorie, avant l'arrivée des catégories
+const (
+	CategoryOtherIncome  = "other_income"
+	CategoryOtherExpense = "other_expense"
+)
+
+// CategoryRent est la catégorie des loyers encaissés
+const CategoryRent = "rent"
+
+// Category classe les entrées de caisse (loyer, garantie, réparations...). Les catégories
+// forment un arbre par type, les totaux d'une catégorie incluent ses sous-catégories.
+type Category struct {
+	UUID      string `gorm:"type:varchar(255);primary_key" json:"uuid"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+
+	Code string `gorm:"type:varchar(50);not null;uniqueIndex" json:"code"` // Ex. repairs_plumbing
+	Name string `gorm:"not null" json:"name"`
+	Type string `gorm:"type:varchar(20);not null" json:"type"` // Income ou Expense
+
+	ParentUUID *string    `gorm:"type:varchar(255);index" json:"parent_uuid"`
+	Children   []Category `gorm:"foreignKey:ParentUUID;references:UUID" json:"children,omitempty"`
+
+	Active bool `gorm:"default:true" json:"active"`
+}
+
+// Budget est le montant prévu pour une catégorie, un immeuble et un mois
+type Budget struct {
+	UUID      string `gorm:"type:varchar(255);primary_key" json:"uuid"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	CategoryUUID string   `gorm:"type:varchar(255);not null;uniqueIndex:idx_budget_key" json:"category_uuid"`
+	Category     Category `gorm:"foreignKey:CategoryUUID;references:UUID" json:"category"`
+	Building     string   `gorm:"not null;uniqueIndex:idx_budget_key" json:"building"` // Nom de l'immeuble (appartments.name)
+	Year         int      `gorm:"not null;uniqueIndex:idx_budget_key" json:"year"`
+	Month        int      `gorm:"not null;uniqueIndex:idx_budget_key" json:"month"`
+
+	AmountUSD utils.Amount `gorm:"default:0" json:"amount_usd"`
+	AmountCDF utils.Amount `gorm:"default:0" json:"amount_cdf"`
+	Notes     string       `json:"notes"`
+}
+
+// defaultCategories est l'arbre créé au premier démarrage : code, nom, type et sous-catégories
+var defaultCategories = []struct {
+	Code, Name, Type string
+	Children         [][2]string
+}{
+	{CategoryRent, "Loyer", "Income", nil},
+	{"deposit", "Garantie", "Income", nil},
+	{"charges_recovery", "Récupération de charges", "Income", nil},
+	{CategoryOtherIncome, "Autres entrées", "Income", nil},
+	{"utilities", "Services publics", "Expense", [][2]string{
+		{"utilities_water", "Eau"},
+		{"utilities_electricity", "Électricité"},
+		{"utilities_internet", "Internet"},
+	}},
+	{"repairs", "Réparations et entretien", "Expense", [][2]string{
+		{"repairs_plumbing", "Plomberie"},
+		{"repairs_electricity", "Électricité"},
+		{"repairs_painting", "Peinture"},
+		{"repairs_cleaning", "Nettoyage"},
+	}},
+	{"salaries", "Salaires", "Expense", [][2]string{
+		{"salaries_guards", "Gardiennage"},
+		{"salaries_staff", "Personnel"},
+	}},
+	{"taxes", "Impôts et taxes", "Expense", nil},
+	{"commissions", "Commissions", "Expense", nil},
+	{"deposit_refund", "Remboursement de garantie", "Expense", nil},
+	{CategoryOtherExpense, "Autres sorties", "Expense", nil},
+}
+
+// SeedCategories crée l'arbre des catégories par défaut s'il n'existe pas encore
+// et classe les entrées antérieures dans "Autres entrées" / "Autres sorties"
+func SeedCategories(db *gorm.DB) error {
+	var count int64
+	if err := db.Unscoped().Model(&Category{}).Count(&count).Error; err != nil {
+		return err
+	}
+
+	if count == 0 {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for _, def := range defaultCategories {
+				parent := Category{UUID: utils.GenerateUUID(), Code: def.Code, Name: def.Name, Type: def.Type, Active: true}
+				if err := tx.Create(&parent).Error; err != nil {
+					return err
+				}
+				for _, child := range def.Children {
+					sub := Category{UUID: utils.GenerateUUID(), Code: child[0], Name: child[1], Type: def.Type, ParentUUID: &parent.UUID, Active: true}
+					if err := tx.Create(&sub).Error; err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for code, caisseType := range map[string]string{CategoryOtherIncome: "Income", CategoryOtherExpense: "Expense"} {
+		err := db.Exec("UPDATE caisses SET category_uuid = (SELECT uuid FROM categories WHERE code = ?) WHERE (category_uuid IS NULL OR category_uuid = '') AND type = ?",
+			code, caisseType).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CategoryDescendants retourne l'UUID de la catégorie et de toutes ses sous-catégories
+func CategoryDescendants(db *gorm.DB, categoryUUID string) ([]string, error) {
+	var uuids []string
+	err := db.Raw(`
+WITH RECURSIVE tree AS (
+	SELECT uuid FROM categories WHERE uuid = ?
+	UNION ALL
+	SELECT c.uuid FROM categories c JOIN tree t ON c.parent_uuid = t.uuid
+)
+SELECT uuid FROM tree`, categoryUUID).Scan(&uuids).Error
+	return uuids, err
+}
@@ -0,0 +1,220 @@
+package models
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kgermando/appartment-app-api/utils"
+	"gorm.io/gorm"
+)
+
+// DailySummary totalise les lignes des entrées comptabilisées d'un appartement pour un jour
+// et une devise. Les résumés sont mis à jour à chaque écriture dans la caisse et lus par
+// les tableaux de bord à la place des entrées. Les jours sont ceux du fuseau de l'organisation
+// (ORG_TIMEZONE), enregistré avec chaque résumé.
+type DailySummary struct {
+	Date           time.Time `gorm:"type:date;primaryKey" json:"date"`
+	AppartmentUUID string    `gorm:"type:varchar(255);primaryKey" json:"appartment_uuid"`
+	Currency       string    `gorm:"type:varchar(3);primaryKey" json:"currency"`
+
+	Income       utils.Amount `gorm:"not null;default:0" json:"income"`
+	Expense      utils.Amount `gorm:"not null;default:0" json:"expense"`
+	IncomeCount  int64        `gorm:"not null;default:0" json:"income_count"` // Entrées ayant une ligne dans la devise
+	ExpenseCount int64        `gorm:"not null;default:0" json:"expense_count"`
+
+	// Montants consolidés au taux de chaque ligne, et lignes qui ne peuvent pas l'être faute de taux
+	IncomeInUSD  utils.Amount `gorm:"not null;default:0" json:"income_in_usd"`
+	ExpenseInUSD utils.Amount `gorm:"not null;default:0" json:"expense_in_usd"`
+	IncomeInCDF  utils.Amount `gorm:"not null;default:0" json:"income_in_cdf"`
+	ExpenseInCDF utils.Amount `gorm:"not null;default:0" json:"expense_in_cdf"`
+	MissingUSD   int64        `gorm:"not null;default:0" json:"missing_usd"`
+	MissingCDF   int64        `gorm:"not null;default:0" json:"missing_cdf"`
+
+	Timezone  string    `gorm:"type:varchar(64);not null;default:'UTC'" json:"timezone"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LocalDateSQL retourne le jour de la date d'opération d'une entrée dans le fuseau donné
+func LocalDateSQL(loc *time.Location) string {
+	return "(caisses.transaction_date AT TIME ZONE '" + strings.ReplaceAll(loc.String(), "'", "''") + "')::date"
+}
+
+// ConvertedLineSQL retourne le montant d'une ligne converti dans la devise de consolidation
+// (USD ou CDF) au taux de la ligne, arrondi au centime. Vers le CDF, le montant passe par
+// le dollar puis par le taux CDF de l'entrée. NULL si un taux manque.
+func ConvertedLineSQL(currency string) string {
+	if currency == utils.CurrencyCDF {
+		return "CASE WHEN caisse_lines.currency = 'CDF' THEN caisse_lines.amount " +
+			"ELSE round(caisse_lines.amount / NULLIF(caisse_lines.exchange_rate, 0) * NULLIF(caisses.exchange_rate, 0), 2) END"
+	}
+	return "CASE WHEN caisse_lines.currency = 'USD' THEN caisse_lines.amount " +
+		"ELSE round(caisse_lines.amount / NULLIF(caisse_lines.exchange_rate, 0), 2) END"
+}
+
+// MissingRateSQL retourne la condition des lignes qui ne peuvent pas être converties
+// dans la devise de consolidation
+func MissingRateSQL(currency string) string {
+	if currency == utils.CurrencyCDF {
+		return "caisse_lines.currency <> 'CDF' AND " +
+			"(COALESCE(caisse_lines.exchange_rate, 0) = 0 OR COALESCE(caisses.exchange_rate, 0) = 0)"
+	}
+	return "caisse_lines.currency <> 'USD' AND COALESCE(caisse_lines.exchange_rate, 0) = 0"
+}
+
+// dailySummarySQL agrège les lignes des entrées comptabilisées par jour du fuseau donné,
+// appartement et devise, avec les colonnes de daily_summaries. where restreint les entrées agrégées.
+func dailySummarySQL(loc *time.Location, where string) string {
+	return fmt.Sprintf(`
+SELECT %[1]s AS date, caisses.appartment_uuid, caisse_lines.currency,
+	COALESCE(SUM(caisse_lines.amount) FILTER (WHERE caisses.type = 'Income'), 0) AS income,
+	COALESCE(SUM(caisse_lines.amount) FILTER (WHERE caisses.type = 'Expense'), 0) AS expense,
+	COUNT(DISTINCT caisses.uuid) FILTER (WHERE caisses.type = 'Income') AS income_count,
+	COUNT(DISTINCT caisses.uuid) FILTER (WHERE caisses.type = 'Expense') AS expense_count,
+	COALESCE(SUM(%[2]s) FILTER (WHERE caisses.type = 'Income'), 0) AS income_in_usd,
+	COALESCE(SUM(%[2]s) FILTER (WHERE caisses.type = 'Expense'), 0) AS expense_in_usd,
+	COALESCE(SUM(%[3]s) FILTER (WHERE caisses.type = 'Income'), 0) AS income_in_cdf,
+	COALESCE(SUM(%[3]s) FILTER (WHERE caisses.type = 'Expense'), 0) AS expense_in_cdf,
+	COUNT(*) FILTER (WHERE %[4]s) AS missing_usd,
+	COUNT(*) FILTER (WHERE %[5]s) AS missing_cdf
+FROM caisses
+JOIN caisse_lines ON caisse_lines.caisse_uuid = caisses.uuid
+WHERE caisses.status <> 'draft' AND caisses.deleted_at IS NULL %[6]s
+GROUP BY 1, 2, 3`,
+		LocalDateSQL(loc),
+		ConvertedLineSQL(utils.CurrencyUSD), ConvertedLineSQL(utils.CurrencyCDF),
+		MissingRateSQL(utils.CurrencyUSD), MissingRateSQL(utils.CurrencyCDF),
+		where)
+}
+
+const dailySummaryColumns = "date, appartment_uuid, currency, income, expense, income_count, expense_count, " +
+	"income_in_usd, expense_in_usd, income_in_cdf, expense_in_cdf, missing_usd, missing_cdf"
+
+// dailySummaryUpsert met à jour les résumés existants du même jour, appartement et devise
+const dailySummaryUpsert = "ON CONFLICT (date, appartment_uuid, currency) DO UPDATE SET " +
+	"income = EXCLUDED.income, expense = EXCLUDED.expense, " +
+	"income_count = EXCLUDED.income_count, expense_count = EXCLUDED.expense_count, " +
+	"income_in_usd = EXCLUDED.income_in_usd, expense_in_usd = EXCLUDED.expense_in_usd, " +
+	"income_in_cdf = EXCLUDED.income_in_cdf, expense_in_cdf = EXCLUDED.expense_in_cdf, " +
+	"missing_usd = EXCLUDED.missing_usd, missing_cdf = EXCLUDED.missing_cdf, " +
+	"timezone = EXCLUDED.timezone, updated_at = EXCLUDED.updated_at"
+
+// RefreshDailySummaries recalcule les résumés d'un appartement pour les jours donnés,
+// dans la transaction qui modifie ses entrées. Chaque jour est verrouillé jusqu'à la fin de
+// la transaction : deux écritures simultanées du même appartement le même jour sont
+// agrégées l'une après l'autre, la seconde voyant les entrées de la première.
+func RefreshDailySummaries(tx *gorm.DB, appartmentUUID string, days ...time.Time) error {
+	loc := utils.OrgLocation()
+	var dates []string
+	for _, day := range days {
+		if day.IsZero() {
+			continue
+		}
+		date := day.In(loc).Format("2006-01-02")
+		if !slices.Contains(dates, date) {
+			dates = append(dates, date)
+		}
+	}
+	// Toujours verrouiller dans le même ordre, pour ne pas s'interbloquer
+	sort.Strings(dates)
+
+	for _, date := range dates {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "daily_summary:"+appartmentUUID+":"+date).Error; err != nil {
+			return err
+		}
+
+		err := tx.Exec("INSERT INTO daily_summaries ("+dailySummaryColumns+", timezone, updated_at) SELECT r.*, ?, now() FROM ("+
+			dailySummarySQL(loc, "AND caisses.appartment_uuid = ? AND "+LocalDateSQL(loc)+" = ?")+") r "+dailySummaryUpsert,
+			loc.String(), appartmentUUID, date).Error
+		if err != nil {
+			return err
+		}
+
+		// Les devises qui n'ont plus de ligne ce jour-là
+		err = tx.Exec(`DELETE FROM daily_summaries WHERE appartment_uuid = ? AND date = ? AND NOT EXISTS (
+	SELECT 1 FROM caisses JOIN caisse_lines ON caisse_lines.caisse_uuid = caisses.uuid
+	WHERE caisses.status <> 'draft' AND caisses.deleted_at IS NULL
+		AND caisses.appartment_uuid = daily_summaries.appartment_uuid AND `+LocalDateSQL(loc)+` = daily_summaries.date
+		AND caisse_lines.currency = daily_summaries.currency)`,
+			appartmentUUID, date).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RebuildDailySummaries recalcule tous les résumés à partir des entrées,
+// dans le fuseau de l'organisation
+func RebuildDailySummaries(db *gorm.DB) error {
+	loc := utils.OrgLocation()
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM daily_summaries").Error; err != nil {
+			return err
+		}
+		return tx.Exec("INSERT INTO daily_summaries ("+dailySummaryColumns+", timezone, updated_at) SELECT r.*, ?, now() FROM ("+
+			dailySummarySQL(loc, "")+") r", loc.String()).Error
+	})
+}
+
+// EnsureDailySummaries construit les résumés au premier démarrage, quand la table est vide,
+// et les reconstruit quand ORG_TIMEZONE a changé depuis leur calcul
+func EnsureDailySummaries(db *gorm.DB) error {
+	var count int64
+	if err := db.Model(&DailySummary{}).Limit(1).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		var stale int64
+		if err := db.Model(&DailySummary{}).Where("timezone <> ?", utils.OrgLocation().String()).Limit(1).Count(&stale).Error; err != nil {
+			return err
+		}
+		if stale == 0 {
+			return nil
+		}
+		log.Println("Daily summaries: time zone changed to", utils.OrgLocation(), "- rebuilding")
+	}
+	return RebuildDailySummaries(db)
+}
+
+// DailySummaryMismatch est un résumé qui ne correspond pas aux entrées, ou qui manque
+type DailySummaryMismatch struct {
+	Date           time.Time    `json:"date"`
+	AppartmentUUID string       `json:"appartment_uuid"`
+	Currency       string       `json:"currency"`
+	Income         utils.Amount `json:"income"` // Recalculé à partir des entrées
+	SummaryIncome  utils.Amount `json:"summary_income"`
+	Expense        utils.Amount `json:"expense"`
+	SummaryExpense utils.Amount `json:"summary_expense"`
+	Entries        int64        `json:"entries"`
+	SummaryEntries int64        `json:"summary_entries"`
+}
+
+// CheckDailySummaries compare les résumés aux entrées et retourne ceux qui diffèrent,
+// manquent ou n'ont plus d'entrées
+func CheckDailySummaries(db *gorm.DB) ([]DailySummaryMismatch, error) {
+	mismatches := []DailySummaryMismatch{}
+	err := db.Raw(`
+WITH raw AS (` + dailySummarySQL(utils.OrgLocation(), "") + `)
+SELECT COALESCE(r.date, s.date) AS date,
+	COALESCE(r.appartment_uuid, s.appartment_uuid) AS appartment_uuid,
+	COALESCE(r.currency, s.currency) AS currency,
+	COALESCE(r.income, 0) AS income, COALESCE(s.income, 0) AS summary_income,
+	COALESCE(r.expense, 0) AS expense, COALESCE(s.expense, 0) AS summary_expense,
+	COALESCE(r.income_count + r.expense_count, 0) AS entries,
+	COALESCE(s.income_count + s.expense_count, 0) AS summary_entries
+FROM raw r
+FULL OUTER JOIN daily_summaries s
+	ON s.date = r.date AND s.appartment_uuid = r.appartment_uuid AND s.currency = r.currency
+WHERE r.date IS NULL OR s.date IS NULL
+	OR r.income <> s.income OR r.expense <> s.expense
+	OR r.income_count <> s.income_count OR r.expense_count <> s.expense_count
+	OR r.income_in_usd <> s.income_in_usd OR r.expense_in_usd <> s.expense_in_usd
+	OR r.income_in_cdf <> s.income_in_cdf OR r.expense_in_cdf <> s.expense_in_cdf
+	OR r.missing_usd <> s.missing_usd OR r.missing_cdf <> s.missing_cdf
+ORDER BY 1, 2, 3`).Scan(&mismatches).Error
+	return mismatches, err
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/kgermando/appartment-app-api/utils"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB ouvre la base Postgres de TEST_DATABASE_URL, les tests qui en ont besoin
+// sont ignorés sans elle
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// Postgres range les entrées dans le jour du fuseau de l'organisation, comme StartOfDay
+func TestLocalDateSQLBucketing(t *testing.T) {
+	db := openTestDB(t)
+
+	tests := []struct {
+		zone string
+		at   time.Time
+		want string
+	}{
+		{"Africa/Kinshasa", time.Date(2025, 1, 31, 22, 30, 0, 0, time.UTC), "2025-01-31"}, // 23:30 locale
+		{"Africa/Kinshasa", time.Date(2025, 1, 31, 23, 30, 0, 0, time.UTC), "2025-02-01"}, // 00:30 locale
+		{"Africa/Kinshasa", time.Date(2024, 12, 31, 22, 30, 0, 0, time.UTC), "2024-12-31"},
+		{"Africa/Kinshasa", time.Date(2024, 12, 31, 23, 30, 0, 0, time.UTC), "2025-01-01"},
+		{"America/New_York", time.Date(2025, 3, 1, 4, 30, 0, 0, time.UTC), "2025-02-28"}, // 23:30 en hiver
+		{"America/New_York", time.Date(2025, 3, 1, 5, 30, 0, 0, time.UTC), "2025-03-01"}, // 00:30 en hiver
+		{"America/New_York", time.Date(2025, 8, 1, 3, 30, 0, 0, time.UTC), "2025-07-31"}, // 23:30 en été
+		{"America/New_York", time.Date(2025, 8, 1, 4, 30, 0, 0, time.UTC), "2025-08-01"}, // 00:30 en été
+		{"UTC", time.Date(2025, 1, 31, 23, 30, 0, 0, time.UTC), "2025-01-31"},
+	}
+	for _, tt := range tests {
+		t.Setenv("ORG_TIMEZONE", tt.zone)
+		loc := utils.OrgLocation()
+
+		var got string
+		err := db.Raw("SELECT to_char("+LocalDateSQL(loc)+", 'YYYY-MM-DD') FROM (SELECT ?::timestamptz AS transaction_date) AS caisses", tt.at).
+			Scan(&got).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: %s is bucketed on %s, want %s", tt.zone, tt.at, got, tt.want)
+		}
+		if local := utils.StartOfDay(tt.at, loc).Format("2006-01-02"); local != got {
+			t.Errorf("%s: %s is bucketed on %s by Postgres and on %s by StartOfDay", tt.zone, tt.at, got, local)
+		}
+	}
+}
@@ -1,5 +1,11 @@
 package models
 
+import (
+	"time"
+
+	"github.com/kgermando/appartment-app-api/utils"
+)
+
 type DashboardStats struct {
 	// Statistiques générales
 	TotalAppartments      int64 `json:"total_apartments"`
@@ -8,63 +14,210 @@ type DashboardStats struct {
 	MaintenanceApartments int64 `json:"maintenance_apartments"`
 
 	// Statistiques financières
-	TotalIncomeUSD  float64 `json:"total_income_usd"`
-	TotalIncomeCDF  float64 `json:"total_income_cdf"`
-	TotalExpenseUSD float64 `json:"total_expense_usd"`
-	TotalExpenseCDF float64 `json:"total_expense_cdf"`
+	TotalIncomeUSD  utils.Amount `json:"total_income_usd"`
+	TotalIncomeCDF  utils.Amount `json:"total_income_cdf"`
+	TotalExpenseUSD utils.Amount `json:"total_expense_usd"`
+	TotalExpenseCDF utils.Amount `json:"total_expense_cdf"`
+
+	// Totaux par devise, chaque devise activée étant présente
+	IncomeByCurrency  map[string]utils.Amount `json:"income_by_currency"`
+	ExpenseByCurrency map[string]utils.Amount `json:"expense_by_currency"`
+
+	// Totaux consolidés dans la devise de reporting, au taux de chaque entrée
+	Currency     string       `json:"currency"`
+	TotalIncome  utils.Amount `json:"total_income"`
+	TotalExpense utils.Amount `json:"total_expense"`
 }
 
 type ApartmentRevenue struct {
-	UUID            string  `json:"uuid"`
-	Name            string  `json:"name"`
-	Number          string  `json:"number"`
-	MonthlyRent     float64 `json:"monthly_rent"`
-	TotalIncomeUSD  float64 `json:"total_income_usd"`
-	TotalIncomeCDF  float64 `json:"total_income_cdf"`
-	TotalExpenseUSD float64 `json:"total_expense_usd"`
-	TotalExpenseCDF float64 `json:"total_expense_cdf"`
+	UUID            string       `json:"uuid"`
+	Name            string       `json:"name"`
+	Number          string       `json:"number"`
+	MonthlyRent     utils.Amount `json:"monthly_rent"`
+	TotalIncomeUSD  utils.Amount `json:"total_income_usd"`
+	TotalIncomeCDF  utils.Amount `json:"total_income_cdf"`
+	TotalExpenseUSD utils.Amount `json:"total_expense_usd"`
+	TotalExpenseCDF utils.Amount `json:"total_expense_cdf"`
+
+	IncomeByCurrency  map[string]utils.Amount `json:"income_by_currency"`
+	ExpenseByCurrency map[string]utils.Amount `json:"expense_by_currency"`
+
+	Currency     string       `json:"currency"`
+	TotalIncome  utils.Amount `json:"total_income"`
+	TotalExpense utils.Amount `json:"total_expense"`
 
 	Status      string `json:"status"`
 	ManagerName string `json:"manager_name"`
 }
 
 type ManagerStats struct {
-	ManagerUUID         string  `json:"manager_uuid"`
-	ManagerName         string  `json:"manager_name"`
-	TotalApartments     int64   `json:"total_apartments"`
-	AvailableApartments int64   `json:"available_apartments"`
-	OccupiedApartments  int64   `json:"occupied_apartments"`
-	TotalIncomeUSD      float64 `json:"total_income_usd"`
-	TotalIncomeCDF      float64 `json:"total_income_cdf"`
-	TotalExpenseUSD     float64 `json:"total_expense_usd"`
-	TotalExpenseCDF     float64 `json:"total_expense_cdf"`
+	ManagerUUID         string       `json:"manager_uuid"`
+	ManagerName         string       `json:"manager_name"`
+	TotalApartments     int64        `json:"total_apartments"`
+	AvailableApartments int64        `json:"available_apartments"`
+	OccupiedApartments  int64        `json:"occupied_apartments"`
+	TotalIncomeUSD      utils.Amount `json:"total_income_usd"`
+	TotalIncomeCDF      utils.Amount `json:"total_income_cdf"`
+	TotalExpenseUSD     utils.Amount `json:"total_expense_usd"`
+	TotalExpenseCDF     utils.Amount `json:"total_expense_cdf"`
+
+	IncomeByCurrency  map[string]utils.Amount `json:"income_by_currency"`
+	ExpenseByCurrency map[string]utils.Amount `json:"expense_by_currency"`
+
+	Currency     string       `json:"currency"`
+	TotalIncome  utils.Amount `json:"total_income"`
+	TotalExpense utils.Amount `json:"total_expense"`
 }
 
 type MonthlyTrend struct {
-	Month      string  `json:"month"`
-	Year       int     `json:"year"`
-	IncomeUSD  float64 `json:"income_usd"`
-	ExpenseUSD float64 `json:"expense_usd"`
+	Month      string       `json:"month"`
+	Year       int          `json:"year"`
+	IncomeUSD  utils.Amount `json:"income_usd"`
+	IncomeCDF  utils.Amount `json:"income_cdf"`
+	ExpenseUSD utils.Amount `json:"expense_usd"`
+	ExpenseCDF utils.Amount `json:"expense_cdf"`
+
+	IncomeByCurrency  map[string]utils.Amount `json:"income_by_currency"`
+	ExpenseByCurrency map[string]utils.Amount `json:"expense_by_currency"`
+
+	Currency string       `json:"currency"`
+	Income   utils.Amount `json:"income"`
+	Expense  utils.Amount `json:"expense"`
 }
 
 type OccupancyStats struct {
-	TotalApartments       int64   `json:"total_apartments"`
-	OccupiedApartments    int64   `json:"occupied_apartments"`
-	AvailableApartments   int64   `json:"available_apartments"`
-	MaintenanceApartments int64   `json:"maintenance_apartments"`
-	OccupancyRate         float64 `json:"occupancy_rate"`
-	AvailabilityRate      float64 `json:"availability_rate"`
-	AverageRent           float64 `json:"average_rent"`
-	TotalPotentialRevenue float64 `json:"total_potential_revenue"`
-	LostRevenue           float64 `json:"lost_revenue"`
+	TotalApartments       int64        `json:"total_apartments"`
+	OccupiedApartments    int64        `json:"occupied_apartments"`
+	AvailableApartments   int64        `json:"available_apartments"`
+	MaintenanceApartments int64        `json:"maintenance_apartments"`
+	OccupancyRate         float64      `json:"occupancy_rate"`
+	AvailabilityRate      float64      `json:"availability_rate"`
+	AverageRent           utils.Amount `json:"average_rent"`
+	TotalPotentialRevenue utils.Amount `json:"total_potential_revenue"`
+	LostRevenue           utils.Amount `json:"lost_revenue"`
 }
 
 type TopManager struct {
-	ManagerUUID    string  `json:"manager_uuid"`
-	ManagerName    string  `json:"manager_name"`
-	TotalRevenue   float64 `json:"total_revenue"`
-	NetProfit      float64 `json:"net_profit"`
-	ApartmentCount int64   `json:"apartment_count"`
-	OccupancyRate  float64 `json:"occupancy_rate"`
-	Efficiency     float64 `json:"efficiency"` // Net profit / Total apartments
+	ManagerUUID       string                  `json:"manager_uuid"`
+	ManagerName       string                  `json:"manager_name"`
+	Currency          string                  `json:"currency"`      // Devise de TotalRevenue, NetProfit et Efficiency
+	TotalRevenue      utils.Amount            `json:"total_revenue"` // Consolidé au taux de chaque entrée
+	NetProfit         utils.Amount            `json:"net_profit"`
+	RevenueUSD        utils.Amount            `json:"revenue_usd"`
+	RevenueCDF        utils.Amount            `json:"revenue_cdf"`
+	ExpenseUSD        utils.Amount            `json:"expense_usd"`
+	ExpenseCDF        utils.Amount            `json:"expense_cdf"`
+	RevenueByCurrency map[string]utils.Amount `json:"revenue_by_currency"`
+	ExpenseByCurrency map[string]utils.Amount `json:"expense_by_currency"`
+	ApartmentCount    int64                   `json:"apartment_count"`
+	OccupancyRate     float64                 `json:"occupancy_rate"`
+	Efficiency        utils.Amount            `json:"efficiency"` // Net profit / Total apartments
+}
+
+// CategoryTotal est le total d'une catégorie, sous-catégories comprises
+type CategoryTotal struct {
+	CategoryUUID    string                  `json:"category_uuid"`
+	Code            string                  `json:"code"`
+	Name            string                  `json:"name"`
+	Type            string                  `json:"type"`
+	TotalUSD        utils.Amount            `json:"total_usd"`
+	TotalCDF        utils.Amount            `json:"total_cdf"`
+	TotalByCurrency map[string]utils.Amount `json:"total_by_currency"`
+	Total           utils.Amount            `json:"total"` // Consolidé dans la devise de reporting
+	Entries         int64                   `json:"entries"`
+	Children        []*CategoryTotal        `json:"children,omitempty"`
+}
+
+// PeriodMetrics regroupe les indicateurs d'une période [Start, End[
+type PeriodMetrics struct {
+	Start   time.Time    `json:"start"`
+	End     time.Time    `json:"end"`
+	Income  utils.Amount `json:"income"` // Consolidé dans la devise de reporting
+	Expense utils.Amount `json:"expense"`
+	Net     utils.Amount `json:"net"`
+
+	// Jours d'appartement couverts par un bail sur les jours d'appartement de la période
+	OccupancyRate float64 `json:"occupancy_rate"`
+
+	// Loyers encaissés (catégorie Loyer) sur les loyers des baux au prorata des jours, en USD
+	RentDue        utils.Amount `json:"rent_due"`
+	RentCollected  utils.Amount `json:"rent_collected"`
+	CollectionRate float64      `json:"collection_rate"`
+}
+
+// AmountDelta est l'écart d'un montant entre deux périodes
+type AmountDelta struct {
+	Change  utils.Amount `json:"change"`
+	Percent *float64     `json:"percent"` // nil si le montant de comparaison est nul
+}
+
+// RateDelta est l'écart d'un taux entre deux périodes, en points
+type RateDelta struct {
+	Change  float64  `json:"change"`
+	Percent *float64 `json:"percent"`
+}
+
+// PeriodComparison compare les indicateurs d'un groupe (immeuble, appartement, gestionnaire)
+// ou de l'ensemble sur deux périodes
+type PeriodComparison struct {
+	GroupKey  string        `json:"group_key,omitempty"`
+	GroupName string        `json:"group_name,omitempty"`
+	Currency  string        `json:"currency"`
+	Current   PeriodMetrics `json:"current"`
+	Previous  PeriodMetrics `json:"previous"`
+
+	Income         AmountDelta `json:"income"`
+	Expense        AmountDelta `json:"expense"`
+	Net            AmountDelta `json:"net"`
+	OccupancyRate  RateDelta   `json:"occupancy_rate"`
+	CollectionRate RateDelta   `json:"collection_rate"`
+}
+
+// RentRollLine est la situation des loyers d'un appartement sur une période, en USD
+type RentRollLine struct {
+	AppartmentUUID  string       `json:"appartment_uuid"`
+	Building        string       `json:"building"`
+	Number          string       `json:"number"`
+	ManagerUUID     string       `json:"manager_uuid"`
+	ManagerName     string       `json:"manager_name"`
+	TenantName      string       `json:"tenant_name"`   // Locataire du dernier bail de la période
+	MonthlyRent     utils.Amount `json:"monthly_rent"`  // Loyer du dernier bail de la période
+	ExpectedRent    utils.Amount `json:"expected_rent"` // Échéances des baux comprises dans la période
+	Collected       utils.Amount `json:"collected"`     // Loyers encaissés pendant la période
+	Balance         utils.Amount `json:"balance"`       // ExpectedRent - Collected
+	CollectionRate  float64      `json:"collection_rate"`
+	LastPaymentDate *time.Time   `json:"last_payment_date"`
+	DaysLate        int64        `json:"days_late"` // Depuis la plus ancienne échéance non couverte par les encaissements
+}
+
+// RentRollTotal totalise le rôle des loyers d'un gestionnaire, d'un immeuble ou de l'ensemble
+type RentRollTotal struct {
+	Key            string       `json:"key,omitempty"`
+	Name           string       `json:"name,omitempty"`
+	Apartments     int64        `json:"apartments"`
+	LateApartments int64        `json:"late_apartments"`
+	ExpectedRent   utils.Amount `json:"expected_rent"`
+	Collected      utils.Amount `json:"collected"`
+	Balance        utils.Amount `json:"balance"`
+	CollectionRate float64      `json:"collection_rate"`
+}
+
+// ForecastExpense est la sortie projetée d'une catégorie pour un mois, dans une devise
+type ForecastExpense struct {
+	CategoryUUID string       `json:"category_uuid"`
+	CategoryName string       `json:"category_name"`
+	Currency     string       `json:"currency"`
+	Amount       utils.Amount `json:"amount"`
+	Source       string       `json:"source"` // history : moyenne mensuelle des sorties passées, recurring : sortie récurrente
+}
+
+// CashFlowForecast est la trésorerie projetée d'un mois [Start, End[, par devise
+type CashFlowForecast struct {
+	Month             string                  `json:"month"` // YYYY-MM
+	Start             time.Time               `json:"start"`
+	End               time.Time               `json:"end"`
+	IncomeByCurrency  map[string]utils.Amount `json:"income_by_currency"` // Loyers attendus, ajustés du comportement des locataires
+	ExpenseByCurrency map[string]utils.Amount `json:"expense_by_currency"`
+	NetByCurrency     map[string]utils.Amount `json:"net_by_currency"`
+	Expenses          []ForecastExpense       `json:"expenses"`
 }
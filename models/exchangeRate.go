@@ -0,0 +1,138 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kgermando/appartment-app-api/utils"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// ErrNoExchangeRate est retournée quand aucun taux n'est en vigueur à la date demandée
+var ErrNoExchangeRate = errors.New("no exchange rate in force")
+
+// ExchangeRate est le taux d'une devise pour 1 USD en vigueur à partir d'une date,
+// jusqu'au taux suivant de la même devise
+type ExchangeRate struct {
+	UUID      string `gorm:"type:varchar(255);primary_key" json:"uuid"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Currency      string          `gorm:"type:varchar(3);not null;default:'CDF';uniqueIndex:idx_exchange_rate_key" json:"currency"`
+	EffectiveDate time.Time       `gorm:"type:date;not null;uniqueIndex:idx_exchange_rate_key" json:"effective_date"`
+	Rate          decimal.Decimal `gorm:"type:numeric(20,6);not null" json:"rate"` // Unités de la devise pour 1 USD
+	Source        string          `json:"source"`                                  // Ex. BCC, marché
+	Notes         string          `json:"notes"`
+
+	CreatedByUUID string `gorm:"type:varchar(255)" json:"created_by_uuid"`
+}
+
+// MigrateExchangeRateIndex supprime l'ancien index unique sur la seule date d'effet :
+// chaque devise a désormais ses propres dates
+func MigrateExchangeRateIndex(db *gorm.DB) error {
+	if db.Migrator().HasIndex(&ExchangeRate{}, "idx_exchange_rates_effective_date") {
+		return db.Migrator().DropIndex(&ExchangeRate{}, "idx_exchange_rates_effective_date")
+	}
+	return nil
+}
+
+// RateAt retourne le taux de la devise en vigueur à la date donnée : le dernier dont
+// la date d'effet n'est pas postérieure à cette date
+func RateAt(db *gorm.DB, currency string, t time.Time) (*ExchangeRate, error) {
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	var rate ExchangeRate
+	err := db.Where("currency = ? AND effective_date <= ?", currency, t.Format("2006-01-02")).
+		Order("effective_date DESC").
+		First(&rate).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w for %s on %s", ErrNoExchangeRate, currency, t.Format("02/01/2006"))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+// ApplyExchangeRate enregistre sur l'entrée et sur chacune de ses lignes le taux en vigueur
+// à sa date d'opération, sauf s'ils en portent déjà un (une contre-passation reprend ceux
+// de l'entrée d'origine). Sans taux défini, la ligne reste sans taux : elle est tenue
+// dans sa propre devise.
+func ApplyExchangeRate(tx *gorm.DB, caisse *Caisse) error {
+	if !caisse.ExchangeRate.IsPositive() {
+		rate, err := RateAt(tx, utils.CurrencyCDF, caisse.TransactionDate)
+		if err != nil && !errors.Is(err, ErrNoExchangeRate) {
+			return err
+		}
+		if rate != nil {
+			caisse.ExchangeRate = rate.Rate
+			caisse.ExchangeRateUUID = rate.UUID
+		}
+	}
+
+	for i := range caisse.Lines {
+		line := &caisse.Lines[i]
+		if line.ExchangeRate.IsPositive() {
+			continue
+		}
+		switch line.Currency {
+		case utils.CurrencyUSD:
+			line.ExchangeRate = decimal.NewFromInt(1)
+		case utils.CurrencyCDF:
+			line.ExchangeRate = caisse.ExchangeRate
+			line.ExchangeRateUUID = caisse.ExchangeRateUUID
+		default:
+			rate, err := RateAt(tx, line.Currency, caisse.TransactionDate)
+			if errors.Is(err, ErrNoExchangeRate) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			line.ExchangeRate = rate.Rate
+			line.ExchangeRateUUID = rate.UUID
+		}
+	}
+	return nil
+}
+
+// BackfillCaisseRates renseigne le taux des entrées pas encore chaînées (brouillons et
+// entrées antérieures à la numérotation) et de leurs lignes qui n'en ont pas, avec le taux
+// en vigueur à leur date d'opération. Les pièces chaînées gardent leur taux : il entre dans
+// leur empreinte. Retourne le nombre d'entrées et de lignes mises à jour.
+func BackfillCaisseRates(db *gorm.DB) (int64, error) {
+	caisses := db.Exec(`
+UPDATE caisses SET exchange_rate = r.rate, exchange_rate_uuid = r.uuid
+FROM (
+	SELECT c.uuid AS caisse_uuid,
+		(SELECT er.uuid FROM exchange_rates er
+		 WHERE er.currency = 'CDF' AND er.effective_date <= c.transaction_date::date
+		 ORDER BY er.effective_date DESC LIMIT 1) AS rate_uuid
+	FROM caisses c
+	WHERE (c.exchange_rate IS NULL OR c.exchange_rate = 0) AND (c.voucher_number IS NULL OR c.voucher_number = 0)
+) m
+JOIN exchange_rates r ON r.uuid = m.rate_uuid
+WHERE caisses.uuid = m.caisse_uuid`)
+	if caisses.Error != nil {
+		return 0, caisses.Error
+	}
+
+	lines := db.Exec(`
+UPDATE caisse_lines SET exchange_rate = r.rate, exchange_rate_uuid = r.uuid
+FROM (
+	SELECT l.uuid AS line_uuid,
+		(SELECT er.uuid FROM exchange_rates er
+		 WHERE er.currency = l.currency AND er.effective_date <= c.transaction_date::date
+		 ORDER BY er.effective_date DESC LIMIT 1) AS rate_uuid
+	FROM caisse_lines l
+	JOIN caisses c ON c.uuid = l.caisse_uuid
+	WHERE (l.exchange_rate IS NULL OR l.exchange_rate = 0) AND l.currency <> 'USD' AND (c.voucher_number IS NULL OR c.voucher_number = 0)
+) m
+JOIN exchange_rates r ON r.uuid = m.rate_uuid
+WHERE caisse_lines.uuid = m.line_uuid`)
+	return caisses.RowsAffected + lines.RowsAffected, lines.Error
+}
@@ -0,0 +1,18 @@
+package models
+
+// ImportRowError décrit une erreur de validation sur une ligne d'un fichier importé
+type ImportRowError struct {
+	Row     int    `json:"row"`   // Numéro de ligne dans le fichier (l'en-tête est la ligne 1)
+	Field   string `json:"field"` // Colonne concernée, vide si l'erreur porte sur toute la ligne
+	Message string `json:"message"`
+}
+
+// ImportReport résume le résultat d'un import CSV/XLSX
+type ImportReport struct {
+	TotalRows   int              `json:"total_rows"`
+	ValidRows   int              `json:"valid_rows"`
+	InvalidRows int              `json:"invalid_rows"`
+	DryRun      bool             `json:"dry_run"`
+	Imported    int              `json:"imported"`
+	Errors      []ImportRowError `json:"errors"`
+}
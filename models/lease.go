@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/kgermando/appartment-app-api/utils"
+	"gorm.io/gorm"
+)
+
+// Statuts d'un bail
+const (
+	LeaseDraft      = "draft"
+	LeaseActive     = "active"
+	LeaseTerminated = "terminated"
+)
+
+type Lease struct {
+	UUID      string `gorm:"type:varchar(255);primary_key" json:"uuid"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+
+	AppartmentUUID string     `gorm:"type:varchar(255);not null;index" json:"appartment_uuid"`
+	Appartment     Appartment `gorm:"foreignKey:AppartmentUUID;references:UUID" json:"appartment"`
+
+	TenantUUID string `gorm:"type:varchar(255);not null;index" json:"tenant_uuid"`
+	Tenant     Tenant `gorm:"foreignKey:TenantUUID;references:UUID" json:"tenant"`
+
+	StartDate time.Time  `gorm:"not null" json:"start_date"`
+	EndDate   *time.Time `json:"end_date"`
+
+	// Conditions financières reprises de l'appartement au moment de la signature
+	MonthlyRent   utils.Amount `gorm:"not null;default:0" json:"monthly_rent"`
+	GarantieMonth float64      `gorm:"not null;default:0" json:"garantie_month"`
+	Garantie      utils.Amount `gorm:"not null;default:0" json:"garantie_montant"`
+
+	Status string `gorm:"default:'draft'" json:"status"` // draft, active, terminated
+}
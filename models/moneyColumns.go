@@ -0,0 +1,56 @@
+package models
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// moneyColumns liste les colonnes de montants et de taux, avec leur nombre de décimales
+var moneyColumns = []struct {
+	Table, Column string
+	Scale         int
+}{
+	{"appartments", "monthly_rent", 2},
+	{"appartments", "garantie", 2},
+	{"leases", "monthly_rent", 2},
+	{"leases", "garantie", 2},
+	{"caisses", "device_cdf", 2},
+	{"caisses", "device_usd", 2},
+	{"caisses", "exchange_rate", 6},
+	{"cash_counts", "expected", 2},
+	{"cash_counts", "counted", 2},
+	{"cash_counts", "variance", 2},
+	{"treasury_accounts", "opening_balance_usd", 2},
+	{"treasury_accounts", "opening_balance_cdf", 2},
+	{"treasury_transfers", "amount_usd", 2},
+	{"treasury_transfers", "amount_cdf", 2},
+	{"budgets", "amount_usd", 2},
+	{"budgets", "amount_cdf", 2},
+	{"exchange_rates", "rate", 6},
+}
+
+// MigrateMoneyColumns convertit les colonnes de montants encore en double precision
+// vers des décimaux exacts, arrondis au centime (taux : six décimales). À exécuter
+// avant l'AutoMigrate, qui ne sait pas convertir les valeurs existantes.
+func MigrateMoneyColumns(db *gorm.DB) error {
+	for _, col := range moneyColumns {
+		var dataType string
+		err := db.Raw(`SELECT data_type FROM information_schema.columns
+WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?`, col.Table, col.Column).
+			Scan(&dataType).Error
+		if err != nil {
+			return err
+		}
+		if dataType != "double precision" {
+			continue
+		}
+
+		sql := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE numeric(20,%d) USING round(%s::numeric, %d)",
+			col.Table, col.Column, col.Scale, col.Column, col.Scale)
+		if err := db.Exec(sql).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Étapes du pipeline de location d'un prospect
+const (
+	ProspectNew         = "new"
+	ProspectVisited     = "visited"
+	ProspectApplication = "application"
+	ProspectAccepted    = "accepted"
+	ProspectRejected    = "rejected"
+)
+
+// prospectTransitions liste les étapes atteignables depuis chaque étape
+var prospectTransitions = map[string][]string{
+	ProspectNew:         {ProspectVisited, ProspectApplication, ProspectRejected},
+	ProspectVisited:     {ProspectApplication, ProspectRejected},
+	ProspectApplication: {ProspectAccepted, ProspectRejected},
+	ProspectAccepted:    {},
+	ProspectRejected:    {},
+}
+
+// Prospect est un locataire potentiel intéressé par un appartement
+type Prospect struct {
+	UUID      string `gorm:"type:varchar(255);primary_key" json:"uuid"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+
+	Fullname      string     `gorm:"not null" json:"fullname"`
+	Email         string     `json:"email"`
+	Telephone     string     `gorm:"not null" json:"telephone"`
+	Message       string     `json:"message"`
+	PreferredDate *time.Time `json:"preferred_date"` // Date de visite souhaitée
+
+	Source string `gorm:"default:'public'" json:"source"` // public, walk-in, phone, referral
+	Status string `gorm:"default:'new'" json:"status"`    // new, visited, application, accepted, rejected
+	Notes  string `json:"notes"`
+
+	AppartmentUUID string     `gorm:"type:varchar(255);not null;index" json:"appartment_uuid"`
+	Appartment     Appartment `gorm:"foreignKey:AppartmentUUID;references:UUID" json:"appartment"`
+
+	// Gestionnaire de l'appartement à qui le prospect est assigné
+	ManagerUUID string `gorm:"type:varchar(255);index" json:"manager_uuid"`
+	Manager     User   `gorm:"foreignKey:ManagerUUID;references:UUID" json:"manager"`
+
+	// Renseignés lors de la conversion en locataire
+	TenantUUID string `gorm:"type:varchar(255)" json:"tenant_uuid"`
+	LeaseUUID  string `gorm:"type:varchar(255)" json:"lease_uuid"`
+
+	Visits []Visit `gorm:"foreignKey:ProspectUUID;references:UUID" json:"visits,omitempty"`
+}
+
+// IsValidProspectStatus validates that a status is one of the pipeline steps
+func IsValidProspectStatus(status string) bool {
+	_, ok := prospectTransitions[status]
+	return ok
+}
+
+// CanTransitionTo indique si le prospect peut passer à l'étape donnée
+func (p *Prospect) CanTransitionTo(status string) bool {
+	for _, next := range prospectTransitions[p.Status] {
+		if next == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"github.com/kgermando/appartment-app-api/utils"
+)
+
+// PublicListing expose uniquement les informations publiques d'un appartement disponible
+type PublicListing struct {
+	UUID              string        `json:"uuid"`
+	Building          string        `json:"building"`
+	Number            string        `json:"number"`
+	Area              string        `json:"area"`
+	Surface           float64       `json:"surface"`
+	Rooms             int           `json:"rooms"`
+	Bathrooms         int           `json:"bathrooms"`
+	Balcony           bool          `json:"balcony"`
+	Furnished         bool          `json:"furnished"`
+	MonthlyRent       utils.Amount  `json:"monthly_rent"`
+	CoverThumbnailURL string        `json:"cover_thumbnail_url"`
+	Photos            []PublicPhoto `json:"photos"`
+	UpdatedAt         time.Time     `json:"updated_at"`
+}
+
+type PublicPhoto struct {
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	IsCover      bool   `json:"is_cover"`
+}
+
+// NewPublicListing construit la fiche publique d'un appartement (photos préchargées et triées)
+func NewPublicListing(apt Appartment) PublicListing {
+	listing := PublicListing{
+		UUID:        apt.UUID,
+		Building:    apt.Name,
+		Number:      apt.Number,
+		Area:        apt.Area,
+		Surface:     apt.Surface,
+		Rooms:       apt.Rooms,
+		Bathrooms:   apt.Bathrooms,
+		Balcony:     apt.Balcony,
+		Furnished:   apt.Furnished,
+		MonthlyRent: apt.MonthlyRent,
+		Photos:      []PublicPhoto{},
+		UpdatedAt:   apt.UpdatedAt,
+	}
+
+	for _, photo := range apt.Photos {
+		listing.Photos = append(listing.Photos, PublicPhoto{
+			URL:          photo.URL,
+			ThumbnailURL: photo.ThumbnailURL,
+			IsCover:      photo.IsCover,
+		})
+		if photo.IsCover {
+			listing.CoverThumbnailURL = photo.ThumbnailURL
+		}
+	}
+
+	return listing
+}
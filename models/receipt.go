@@ -0,0 +1,61 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Receipt est une quittance de loyer émise pour une entrée de caisse.
+// Une entrée ne reçoit qu'une seule quittance, réimprimée avec le même numéro.
+type Receipt struct {
+	UUID      string `gorm:"type:varchar(255);primary_key" json:"uuid"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+
+	CaisseUUID string `gorm:"type:varchar(255);not null;uniqueIndex" json:"caisse_uuid"`
+	Caisse     Caisse `gorm:"foreignKey:CaisseUUID;references:UUID" json:"caisse"`
+
+	Year     int    `gorm:"not null;uniqueIndex:idx_receipt_year_sequence" json:"year"`
+	Sequence int    `gorm:"not null;uniqueIndex:idx_receipt_year_sequence" json:"sequence"`
+	Number   string `gorm:"type:varchar(30);not null;uniqueIndex" json:"number"` // Ex. Q-2025-000042
+
+	// Période couverte par le paiement
+	PeriodStart time.Time `gorm:"not null" json:"period_start"`
+	PeriodEnd   time.Time `gorm:"not null" json:"period_end"`
+
+	TenantUUID string `gorm:"type:varchar(255)" json:"tenant_uuid"` // Locataire du bail en cours, si connu
+
+	EmailedTo string     `json:"emailed_to"`
+	EmailedAt *time.Time `json:"emailed_at"`
+}
+
+// ReceiptSequence garde le dernier numéro de quittance attribué pour chaque année
+type ReceiptSequence struct {
+	Year       int `gorm:"primary_key;autoIncrement:false" json:"year"`
+	LastNumber int `gorm:"not null;default:0" json:"last_number"`
+}
+
+// NextReceiptNumber réserve le prochain numéro de quittance de l'année.
+// Doit être appelé dans une transaction : la ligne du compteur reste verrouillée
+// jusqu'au commit, deux quittances ne peuvent donc pas recevoir le même numéro.
+func NextReceiptNumber(tx *gorm.DB, year int) (int, string, error) {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&ReceiptSequence{Year: year}).Error; err != nil {
+		return 0, "", err
+	}
+
+	var seq ReceiptSequence
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("year = ?", year).First(&seq).Error; err != nil {
+		return 0, "", err
+	}
+
+	seq.LastNumber++
+	if err := tx.Model(&seq).Update("last_number", seq.LastNumber).Error; err != nil {
+		return 0, "", err
+	}
+
+	return seq.LastNumber, fmt.Sprintf("Q-%d-%06d", year, seq.LastNumber), nil
+}
@@ -0,0 +1,249 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/kgermando/appartment-app-api/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Fréquences d'une sortie récurrente
+const (
+	FrequencyWeekly    = "weekly"
+	FrequencyMonthly   = "monthly"
+	FrequencyQuarterly = "quarterly"
+	FrequencyYearly    = "yearly"
+)
+
+// RecurringExpense est le modèle d'une sortie de caisse qui revient à date fixe : salaire du
+// gardien, sécurité, carburant du groupe électrogène. À chaque échéance, le planificateur
+// crée une entrée brouillon qu'un agent vérifie puis comptabilise.
+type RecurringExpense struct {
+	UUID      string `gorm:"type:varchar(255);primary_key" json:"uuid"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+
+	Name string `gorm:"not null" json:"name"` // Motif des entrées créées
+
+	// Sortie d'un immeuble (appartments.name) ou d'un appartement. Celle d'un immeuble
+	// est passée sur son premier appartement.
+	Building       string     `gorm:"index" json:"building"`
+	AppartmentUUID string     `gorm:"type:varchar(255);not null;index" json:"appartment_uuid"`
+	Appartment     Appartment `gorm:"foreignKey:AppartmentUUID;references:UUID" json:"appartment"`
+
+	CategoryUUID string   `gorm:"type:varchar(255);not null;index" json:"category_uuid"`
+	Category     Category `gorm:"foreignKey:CategoryUUID;references:UUID" json:"category"`
+
+	// Compte de trésorerie des entrées, la petite caisse de l'appartement par défaut
+	AccountUUID string `gorm:"type:varchar(255)" json:"account_uuid"`
+
+	// Montants de chaque entrée, une ligne par devise
+	Lines []RecurringExpenseLine `gorm:"foreignKey:RecurringExpenseUUID;references:UUID" json:"lines"`
+
+	Frequency   string     `gorm:"type:varchar(20);not null" json:"frequency"` // weekly, monthly, quarterly, yearly
+	StartDate   time.Time  `gorm:"not null" json:"start_date"`                 // Première échéance
+	EndDate     *time.Time `json:"end_date"`                                   // Dernière échéance possible, facultative
+	Occurrences int        `gorm:"default:0" json:"occurrences"`               // Entrées déjà créées
+	NextRunDate time.Time  `gorm:"not null;index" json:"next_run_date"`
+
+	Signature string `json:"signature"` // Signature des entrées créées
+	Active    bool   `gorm:"default:true" json:"active"`
+}
+
+// RecurringExpenseLine est le montant d'une sortie récurrente dans une devise
+type RecurringExpenseLine struct {
+	UUID      string `gorm:"type:varchar(255);primary_key" json:"uuid"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	RecurringExpenseUUID string       `gorm:"type:varchar(255);not null;index" json:"recurring_expense_uuid"`
+	Currency             string       `gorm:"type:varchar(3);not null" json:"currency"`
+	Amount               utils.Amount `gorm:"not null;default:0" json:"amount"`
+}
+
+// IsFrequency indique si la fréquence est connue
+func IsFrequency(frequency string) bool {
+	switch frequency {
+	case FrequencyWeekly, FrequencyMonthly, FrequencyQuarterly, FrequencyYearly:
+		return true
+	}
+	return false
+}
+
+// SetLines contrôle et attache les montants au modèle, une ligne par devise
+func (r *RecurringExpense) SetLines(lines []RecurringExpenseLine) error {
+	if len(lines) == 0 {
+		return fmt.Errorf("%w: at least one amount is required", ErrInvalidCaisseLines)
+	}
+	seen := make(map[string]bool, len(lines))
+	for i := range lines {
+		line := &lines[i]
+		line.Currency = strings.ToUpper(strings.TrimSpace(line.Currency))
+		if !utils.IsCurrency(line.Currency) {
+			return fmt.Errorf("%w: currency '%s' is not enabled", ErrInvalidCaisseLines, line.Currency)
+		}
+		if seen[line.Currency] {
+			return fmt.Errorf("%w: one amount per currency", ErrInvalidCaisseLines)
+		}
+		seen[line.Currency] = true
+		if !line.Amount.IsPositive() {
+			return fmt.Errorf("%w: amounts must be greater than 0", ErrInvalidCaisseLines)
+		}
+		line.UUID = utils.GenerateUUID()
+		line.RecurringExpenseUUID = r.UUID
+	}
+	r.Lines = lines
+	return nil
+}
+
+// SaveRecurringExpenseLines remplace les lignes enregistrées du modèle par ses lignes actuelles
+func SaveRecurringExpenseLines(tx *gorm.DB, r *RecurringExpense) error {
+	if err := tx.Where("recurring_expense_uuid = ?", r.UUID).Delete(&RecurringExpenseLine{}).Error; err != nil {
+		return err
+	}
+	if len(r.Lines) == 0 {
+		return nil
+	}
+	return tx.Create(&r.Lines).Error
+}
+
+// Occurrence retourne la n-ième échéance (0 pour la première) dans le fuseau de l'organisation.
+// Les échéances mensuelles gardent le jour de la première, le dernier jour des mois plus courts.
+func (r *RecurringExpense) Occurrence(n int) time.Time {
+	first := utils.StartOfDay(r.StartDate, utils.OrgLocation())
+	months := 0
+	switch r.Frequency {
+	case FrequencyWeekly:
+		return first.AddDate(0, 0, 7*n)
+	case FrequencyQuarterly:
+		months = 3 * n
+	case FrequencyYearly:
+		months = 12 * n
+	default:
+		months = n
+	}
+	monthStart := time.Date(first.Year(), first.Month()+time.Month(months), 1, 0, 0, 0, 0, first.Location())
+	day := min(first.Day(), monthStart.AddDate(0, 1, -1).Day())
+	return monthStart.AddDate(0, 0, day-1)
+}
+
+// Ended indique si l'échéance tombe après la date de fin du modèle
+func (r *RecurringExpense) Ended(date time.Time) bool {
+	return r.EndDate != nil && date.After(utils.StartOfDay(*r.EndDate, utils.OrgLocation()))
+}
+
+// Schedule calcule la prochaine échéance après les entrées déjà créées
+func (r *RecurringExpense) Schedule() {
+	r.NextRunDate = r.Occurrence(r.Occurrences)
+}
+
+// draft retourne l'entrée brouillon d'une échéance. Le compte, la catégorie et la période
+// sont contrôlés à la comptabilisation.
+func (r *RecurringExpense) draft(date time.Time) (*Caisse, error) {
+	signature := r.Signature
+	if signature == "" {
+		signature = r.Name
+	}
+	caisse := &Caisse{
+		UUID:                 utils.GenerateUUID(),
+		AppartmentUUID:       r.AppartmentUUID,
+		AccountUUID:          r.AccountUUID,
+		CategoryUUID:         r.CategoryUUID,
+		Type:                 "Expense",
+		Motif:                r.Name,
+		Signature:            signature,
+		TransactionDate:      date,
+		Status:               CaisseDraft,
+		RecurringExpenseUUID: r.UUID,
+	}
+	lines := make([]CaisseLine, len(r.Lines))
+	for i, line := range r.Lines {
+		lines[i] = CaisseLine{Currency: line.Currency, Amount: line.Amount}
+	}
+	if err := caisse.SetLines(lines); err != nil {
+		return nil, err
+	}
+	return caisse, nil
+}
+
+// GenerateRecurringExpenses crée les brouillons de toutes les échéances passées ou du jour
+// des modèles actifs et retourne leur nombre. Chaque modèle est verrouillé pendant la
+// création : plusieurs instances de l'API peuvent tourner sans créer deux fois la même entrée.
+// Les erreurs sont journalisées : un modèle en erreur ne bloque pas les suivants, les erreurs
+// sont retournées ensemble.
+func GenerateRecurringExpenses(db *gorm.DB, now time.Time) (int, error) {
+	tomorrow := utils.StartOfDay(now, utils.OrgLocation()).AddDate(0, 0, 1)
+
+	var due []string
+	if err := db.Model(&RecurringExpense{}).
+		Where("active = ? AND next_run_date < ?", true, tomorrow).
+		Pluck("uuid", &due).Error; err != nil {
+		log.Println("Recurring expenses:", err)
+		return 0, err
+	}
+
+	created := 0
+	var errs []error
+	for _, templateUUID := range due {
+		drafts := 0
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var r RecurringExpense
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+				Where("uuid = ? AND active = ? AND next_run_date < ?", templateUUID, true, tomorrow).
+				Limit(1).Find(&r).Error
+			if err != nil || r.UUID == "" {
+				return err
+			}
+			if err := tx.Where("recurring_expense_uuid = ?", r.UUID).Find(&r.Lines).Error; err != nil {
+				return err
+			}
+
+			for r.NextRunDate.Before(tomorrow) && !r.Ended(r.NextRunDate) {
+				caisse, err := r.draft(r.NextRunDate)
+				if err != nil {
+					return err
+				}
+				if err := tx.Omit("Appartment", "Category", "Lines").Create(caisse).Error; err != nil {
+					return err
+				}
+				if err := SaveCaisseLines(tx, caisse); err != nil {
+					return err
+				}
+				drafts++
+				r.Occurrences++
+				r.Schedule()
+			}
+			if r.Ended(r.NextRunDate) {
+				r.Active = false
+			}
+			return tx.Model(&r).Select("occurrences", "next_run_date", "active").Updates(&r).Error
+		})
+		if err != nil {
+			err = fmt.Errorf("recurring expense %s: %w", templateUUID, err)
+			log.Println("Recurring expenses:", err)
+			errs = append(errs, err)
+			continue
+		}
+		created += drafts
+	}
+	return created, errors.Join(errs...)
+}
+
+// ScheduleRecurringExpenses crée les brouillons des échéances au démarrage puis à chaque intervalle.
+// Bloquant : à lancer dans une goroutine.
+func ScheduleRecurringExpenses(db *gorm.DB, every time.Duration) {
+	for {
+		// Les erreurs sont déjà journalisées, modèle par modèle
+		created, _ := GenerateRecurringExpenses(db, time.Now())
+		if created > 0 {
+			log.Printf("Recurring expenses: %d draft entries created", created)
+		}
+		time.Sleep(every)
+	}
+}
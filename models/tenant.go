@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Tenant struct {
+	UUID      string `gorm:"type:varchar(255);primary_key" json:"uuid"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+
+	Fullname  string `gorm:"not null" json:"fullname"`
+	Email     string `json:"email"`
+	Telephone string `gorm:"not null" json:"telephone"`
+
+	ProspectUUID string `gorm:"type:varchar(255)" json:"prospect_uuid"` // Prospect d'origine
+
+	Leases []Lease `gorm:"foreignKey:TenantUUID;references:UUID" json:"leases,omitempty"`
+}
@@ -0,0 +1,239 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/kgermando/appartment-app-api/utils"
+	"gorm.io/gorm"
+)
+
+// Types de comptes de trésorerie
+const (
+	AccountPettyCash   = "petty_cash"   // Caisse (espèces) d'un immeuble
+	AccountBank        = "bank"         // Compte bancaire
+	AccountMobileMoney = "mobile_money" // M-Pesa, Airtel Money, Orange Money...
+)
+
+// IsValidAccountKind indique si le type de compte est connu
+func IsValidAccountKind(kind string) bool {
+	return kind == AccountPettyCash || kind == AccountBank || kind == AccountMobileMoney
+}
+
+// TreasuryAccount est un compte de trésorerie sur lequel sont enregistrées les entrées de caisse
+type TreasuryAccount struct {
+	UUID      string `gorm:"type:varchar(255);primary_key" json:"uuid"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+
+	Name          string `gorm:"not null" json:"name"`
+	Kind          string `gorm:"type:varchar(20);not null" json:"kind"` // petty_cash, bank, mobile_money
+	Provider      string `json:"provider"`                              // Banque ou opérateur Ex. Rawbank, M-Pesa
+	AccountNumber string `json:"account_number"`                        // Numéro de compte ou de téléphone
+	Building      string `json:"building"`                              // Immeuble de la petite caisse Ex. okapi
+
+	// Petite caisse créée pour un appartement (voir EnsureAppartmentAccount)
+	AppartmentUUID string `gorm:"type:varchar(255);index" json:"appartment_uuid"`
+
+	OpeningBalanceUSD utils.Amount `gorm:"default:0" json:"opening_balance_usd"`
+	OpeningBalanceCDF utils.Amount `gorm:"default:0" json:"opening_balance_cdf"`
+	OpeningDate       time.Time    `json:"opening_date"`
+
+	Active bool `gorm:"default:true" json:"active"`
+}
+
+// TreasuryTransfer est un mouvement de fonds entre deux comptes. Ce n'est ni une
+// entrée ni une sortie : il ne modifie que les soldes des deux comptes.
+type TreasuryTransfer struct {
+	UUID      string `gorm:"type:varchar(255);primary_key" json:"uuid"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+
+	FromAccountUUID string          `gorm:"type:varchar(255);not null;index" json:"from_account_uuid"`
+	FromAccount     TreasuryAccount `gorm:"foreignKey:FromAccountUUID;references:UUID" json:"from_account"`
+	ToAccountUUID   string          `gorm:"type:varchar(255);not null;index" json:"to_account_uuid"`
+	ToAccount       TreasuryAccount `gorm:"foreignKey:ToAccountUUID;references:UUID" json:"to_account"`
+
+	AmountUSD utils.Amount `gorm:"default:0" json:"amount_usd"`
+	AmountCDF utils.Amount `gorm:"default:0" json:"amount_cdf"`
+
+	Motif     string `gorm:"not null" json:"motif"`
+	Signature string `json:"signature"`
+}
+
+// OpeningBalances retourne les soldes d'ouverture du compte par devise
+func (a TreasuryAccount) OpeningBalances() map[string]utils.Amount {
+	return map[string]utils.Amount{
+		utils.CurrencyUSD: a.OpeningBalanceUSD,
+		utils.CurrencyCDF: a.OpeningBalanceCDF,
+	}
+}
+
+// AccountMovement est une ligne du relevé d'un compte : entrée de caisse ou transfert
+type AccountMovement struct {
+	Date      time.Time `json:"date"`
+	Kind      string    `json:"kind"` // caisse, transfer_in, transfer_out
+	UUID      string    `json:"uuid"`
+	Reference string    `json:"reference"`
+	Type      string    `json:"type"`
+	Motif     string    `json:"motif"`
+
+	Amounts  map[string]utils.Amount `gorm:"-" json:"amounts"`  // Par devise, signés : négatifs pour une sortie
+	Balances map[string]utils.Amount `gorm:"-" json:"balances"` // Soldes par devise après le mouvement
+}
+
+// accountMovementsSQL réunit les lignes des entrées comptabilisées et les transferts d'un
+// compte, une ligne par mouvement et par devise
+const accountMovementsSQL = `
+SELECT caisses.transaction_date AS date, 'caisse' AS kind, caisses.uuid, caisses.voucher AS reference,
+	caisses.type, caisses.motif, caisse_lines.currency,
+	CASE WHEN caisses.type = 'Income' THEN caisse_lines.amount ELSE -caisse_lines.amount END AS amount
+FROM caisses
+JOIN caisse_lines ON caisse_lines.caisse_uuid = caisses.uuid
+WHERE caisses.account_uuid = @account AND caisses.status <> 'draft' AND caisses.deleted_at IS NULL
+UNION ALL
+SELECT t.created_at, 'transfer_out', t.uuid, '', 'Transfer', t.motif, a.currency, -a.amount
+FROM treasury_transfers t
+CROSS JOIN LATERAL (VALUES ('USD', t.amount_usd), ('CDF', t.amount_cdf)) AS a(currency, amount)
+WHERE t.from_account_uuid = @account AND t.deleted_at IS NULL AND a.amount <> 0
+UNION ALL
+SELECT t.created_at, 'transfer_in', t.uuid, '', 'Transfer', t.motif, a.currency, a.amount
+FROM treasury_transfers t
+CROSS JOIN LATERAL (VALUES ('USD', t.amount_usd), ('CDF', t.amount_cdf)) AS a(currency, amount)
+WHERE t.to_account_uuid = @account AND t.deleted_at IS NULL AND a.amount <> 0`
+
+// AccountLedger retourne les soldes du compte au début de la période puis chaque
+// mouvement de la période avec les soldes après le mouvement. from et to sont optionnels.
+func AccountLedger(db *gorm.DB, account TreasuryAccount, from, to *time.Time) (map[string]utils.Amount, []AccountMovement, error) {
+	opening := account.OpeningBalances()
+	if from != nil {
+		var err error
+		if opening, err = AccountBalance(db, account, *from); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	sql := "SELECT * FROM (" + accountMovementsSQL + ") m WHERE 1 = 1"
+	params := map[string]interface{}{"account": account.UUID}
+	if from != nil {
+		sql += " AND date >= @from"
+		params["from"] = *from
+	}
+	if to != nil {
+		sql += " AND date < @to"
+		params["to"] = *to
+	}
+	// Les lignes d'un même mouvement se suivent
+	sql += " ORDER BY date, reference, kind, uuid, currency"
+
+	var rows []struct {
+		AccountMovement
+		Currency string
+		Amount   utils.Amount
+	}
+	if err := db.Raw(sql, params).Scan(&rows).Error; err != nil {
+		return nil, nil, err
+	}
+
+	balances := make(map[string]utils.Amount, len(opening))
+	for currency, amount := range opening {
+		balances[currency] = amount
+	}
+	movements := []AccountMovement{}
+	for _, row := range rows {
+		last := len(movements) - 1
+		if last < 0 || movements[last].UUID != row.UUID || movements[last].Kind != row.Kind {
+			movement := row.AccountMovement
+			movement.Amounts = make(map[string]utils.Amount)
+			movements = append(movements, movement)
+			last++
+		}
+		movement := &movements[last]
+		movement.Amounts[row.Currency] = movement.Amounts[row.Currency].Add(row.Amount)
+		balances[row.Currency] = balances[row.Currency].Add(row.Amount)
+
+		movement.Balances = make(map[string]utils.Amount, len(balances))
+		for currency, amount := range balances {
+			movement.Balances[currency] = amount
+		}
+	}
+	return opening, movements, nil
+}
+
+// AccountBalance calcule les soldes du compte par devise (soldes d'ouverture compris)
+// avant la date donnée
+func AccountBalance(db *gorm.DB, account TreasuryAccount, before time.Time) (map[string]utils.Amount, error) {
+	var sums []struct {
+		Currency string
+		Amount   utils.Amount
+	}
+	err := db.Raw("SELECT currency, COALESCE(SUM(amount), 0) AS amount FROM ("+accountMovementsSQL+") m WHERE date < @before GROUP BY currency",
+		map[string]interface{}{"account": account.UUID, "before": before}).Scan(&sums).Error
+	if err != nil {
+		return nil, err
+	}
+
+	balances := account.OpeningBalances()
+	for _, sum := range sums {
+		balances[sum.Currency] = balances[sum.Currency].Add(sum.Amount)
+	}
+	return balances, nil
+}
+
+// EnsureAppartmentAccount retourne la petite caisse d'un appartement, créée au besoin.
+// Elle reprend l'UUID de l'appartement : les pièces numérotées avant les comptes de
+// trésorerie (caisse = appartement) restent ainsi dans la même chaîne.
+func EnsureAppartmentAccount(db *gorm.DB, appartmentUUID string) (*TreasuryAccount, error) {
+	var account TreasuryAccount
+	err := db.Unscoped().Where("uuid = ?", appartmentUUID).First(&account).Error
+	if err == nil {
+		return &account, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var apt Appartment
+	if err := db.Unscoped().Where("uuid = ?", appartmentUUID).First(&apt).Error; err != nil {
+		return nil, err
+	}
+
+	account = TreasuryAccount{
+		UUID:           apt.UUID,
+		Name:           "Caisse " + apt.Name + " " + apt.Number,
+		Kind:           AccountPettyCash,
+		Building:       apt.Name,
+		AppartmentUUID: apt.UUID,
+		OpeningDate:    apt.CreatedAt,
+		Active:         true,
+	}
+	if err := db.Create(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// BackfillTreasuryAccounts crée la petite caisse de chaque appartement, supprimés compris
+// pour que leurs entrées aient aussi un compte
+func BackfillTreasuryAccounts(db *gorm.DB) error {
+	var uuids []string
+	err := db.Unscoped().Model(&Appartment{}).
+		Where("uuid NOT IN (?)", db.Unscoped().Model(&TreasuryAccount{}).Select("uuid")).
+		Pluck("uuid", &uuids).Error
+	if err != nil {
+		return err
+	}
+	for _, uuid := range uuids {
+		if _, err := EnsureAppartmentAccount(db, uuid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LinkCaissesToAccounts rattache les entrées sans compte à la caisse de leur chaîne
+func LinkCaissesToAccounts(db *gorm.DB) error {
+	return db.Exec("UPDATE caisses SET account_uuid = COALESCE(NULLIF(register_uuid, ''), appartment_uuid) WHERE account_uuid IS NULL OR account_uuid = ''").Error
+}
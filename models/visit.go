@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Statuts d'une visite
+const (
+	VisitScheduled = "scheduled"
+	VisitDone      = "done"
+	VisitCancelled = "cancelled"
+	VisitNoShow    = "no_show"
+)
+
+// Visit est un rendez-vous de visite d'un appartement avec un prospect
+type Visit struct {
+	UUID      string `gorm:"type:varchar(255);primary_key" json:"uuid"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+
+	ProspectUUID string   `gorm:"type:varchar(255);not null;index" json:"prospect_uuid"`
+	Prospect     Prospect `gorm:"foreignKey:ProspectUUID;references:UUID" json:"prospect"`
+
+	AppartmentUUID string     `gorm:"type:varchar(255);not null;index" json:"appartment_uuid"`
+	Appartment     Appartment `gorm:"foreignKey:AppartmentUUID;references:UUID" json:"appartment"`
+
+	ManagerUUID string `gorm:"type:varchar(255);index" json:"manager_uuid"`
+	Manager     User   `gorm:"foreignKey:ManagerUUID;references:UUID" json:"manager"`
+
+	StartsAt time.Time `gorm:"not null;index" json:"starts_at"`
+	EndsAt   time.Time `gorm:"not null" json:"ends_at"`
+
+	Status string `gorm:"default:'scheduled'" json:"status"` // scheduled, done, cancelled, no_show
+	Notes  string `json:"notes"`
+}
+
+// ValidateStatus validates that the Status field contains only allowed values
+func (v *Visit) ValidateStatus() bool {
+	switch v.Status {
+	case VisitScheduled, VisitDone, VisitCancelled, VisitNoShow:
+		return true
+	}
+	return false
+}
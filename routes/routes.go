@@ -1,13 +1,27 @@
 package routes
 
 import (
+	"time"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/kgermando/appartment-app-api/controllers/accounts"
 	"github.com/kgermando/appartment-app-api/controllers/appartments"
 	"github.com/kgermando/appartment-app-api/controllers/auth"
+	"github.com/kgermando/appartment-app-api/controllers/budgets"
 	"github.com/kgermando/appartment-app-api/controllers/caisses"
+	"github.com/kgermando/appartment-app-api/controllers/categories"
 	"github.com/kgermando/appartment-app-api/controllers/dashboard"
+	"github.com/kgermando/appartment-app-api/controllers/leases"
+	"github.com/kgermando/appartment-app-api/controllers/periods"
+	"github.com/kgermando/appartment-app-api/controllers/prospects"
+	"github.com/kgermando/appartment-app-api/controllers/public"
+	"github.com/kgermando/appartment-app-api/controllers/rates"
+	"github.com/kgermando/appartment-app-api/controllers/recurring"
+	"github.com/kgermando/appartment-app-api/controllers/tenants"
 	"github.com/kgermando/appartment-app-api/controllers/users"
+	"github.com/kgermando/appartment-app-api/middlewares"
 
+	"github.com/gofiber/fiber/v2/middleware/limiter"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 )
 
@@ -23,6 +37,18 @@ func Setup(app *fiber.App) {
 	a.Post("/forgot-password", auth.ForgotPassword)
 	a.Post("/reset/:token", auth.ResetPassword)
 
+	// Public listings (sans authentification, limité par IP)
+	pub := api.Group("/public", limiter.New(limiter.Config{
+		Max:        60,
+		Expiration: 1 * time.Minute,
+	}))
+	pub.Get("/listings", public.GetPublicListings)
+	pub.Get("/listings/:uuid", public.GetPublicListing)
+	pub.Post("/listings/:uuid/contact", limiter.New(limiter.Config{
+		Max:        5,
+		Expiration: 15 * time.Minute,
+	}), public.CreateVisitRequest)
+
 	// app.Use(middlewares.IsAuthenticated)
 
 	a.Get("/user", auth.AuthUser)
@@ -49,20 +75,114 @@ func Setup(app *fiber.App) {
 	ap.Get("/stats/:uuid", appartments.GetAppartmentStats) // Route statique "stats" avant "get"
 	ap.Get("/get/:uuid", appartments.GetAppartment)
 	ap.Post("/create", appartments.CreateAppartment)
+	ap.Post("/import", appartments.ImportAppartments) // CSV ou XLSX, ?dry_run=true pour valider
 	ap.Put("/update/:uuid", appartments.UpdateAppartment)
 	ap.Delete("/delete/:uuid", appartments.DeleteAppartment)
 
+	// Appartment photos
+	ap.Get("/:uuid/photos", appartments.GetAppartmentPhotos)
+	ap.Post("/:uuid/photos", appartments.UploadAppartmentPhotos)
+	ap.Put("/:uuid/photos/order", appartments.ReorderAppartmentPhotos)
+	ap.Put("/:uuid/photos/:photo_uuid/cover", appartments.SetAppartmentPhotoCover)
+	ap.Delete("/:uuid/photos/:photo_uuid", appartments.DeleteAppartmentPhoto)
+
 	// Caisses controller
 	c := api.Group("/caisses")
 	c.Get("/all/paginate", caisses.GetPaginatedCaissesSuperAdmin)         // Route statique en premier
 	c.Get("/all/:appartment_uuid/paginate", caisses.GetPaginatedCaisses)  // Route avec paramètre + suffixe
+	c.Get("/export", caisses.ExportCaissesSuperAdmin)                     // ?format=csv|xlsx|pdf
+	c.Get("/all/:appartment_uuid/export", caisses.ExportCaisses)          // ?format=csv|xlsx|pdf
 	c.Get("/all/:appartment_uuid", caisses.GetAllCaissesByAppartmentUUID) // Route dynamique seule
 	c.Get("/all", caisses.GetAllCaisses)
+	c.Get("/verify", caisses.VerifyCaisseChain) // Vérification de la chaîne, ?register_uuid=&year=
 	c.Get("/get/:uuid", caisses.GetCaisse)
+	c.Get("/:uuid/receipt", caisses.GetCaisseReceipt)          // Quittance PDF, ?period_start=&period_end=
+	c.Post("/:uuid/receipt/email", caisses.EmailCaisseReceipt) // Envoi de la quittance par email
 	c.Post("/create", caisses.CreateCaisse)
+	c.Post("/import", caisses.ImportCaisses) // CSV ou XLSX, ?dry_run=true pour valider
 	c.Put("/update/:uuid", caisses.UpdateCaisse)
+	c.Put("/:uuid/post", caisses.PostCaisse)        // Comptabiliser un brouillon
+	c.Post("/:uuid/reverse", caisses.ReverseCaisse) // Contre-passation avec motif
 	c.Delete("/delete/:uuid", caisses.DeleteCaisse)
 
+	// Prospects controller
+	pr := api.Group("/prospects")
+	pr.Get("/all/paginate", prospects.GetPaginatedProspects)               // Route statique en premier
+	pr.Get("/all/:manager_uuid/paginate", prospects.GetPaginatedProspects) // Route avec paramètre + suffixe
+	pr.Get("/get/:uuid", prospects.GetProspect)
+	pr.Post("/create", prospects.CreateProspect)
+	pr.Put("/status/:uuid", prospects.UpdateProspectStatus)
+	pr.Post("/convert/:uuid", prospects.ConvertProspect)
+	pr.Delete("/delete/:uuid", prospects.DeleteProspect)
+
+	// Visits controller
+	v := api.Group("/visits")
+	v.Get("/all/:manager_uuid", prospects.GetVisitsByManagerUUID)
+	v.Post("/create", prospects.CreateVisit)
+	v.Put("/update/:uuid", prospects.UpdateVisit)
+
+	// Tenants controller
+	t := api.Group("/tenants")
+	t.Get("/all/paginate", tenants.GetPaginatedTenants)
+	t.Get("/get/:uuid", tenants.GetTenant)
+	t.Put("/update/:uuid", tenants.UpdateTenant)
+
+	// Leases controller
+	le := api.Group("/leases")
+	le.Get("/all/:appartment_uuid", leases.GetAllLeasesByAppartmentUUID)
+	le.Get("/get/:uuid", leases.GetLease)
+	le.Put("/update/:uuid", leases.UpdateLease)
+	le.Post("/activate/:uuid", leases.ActivateLease)
+	le.Post("/terminate/:uuid", leases.TerminateLease)
+
+	// Treasury accounts controller
+	ac := api.Group("/accounts")
+	ac.Get("/all", accounts.GetAllAccounts) // ?kind=&active=
+	ac.Get("/get/:uuid", accounts.GetAccount)
+	ac.Get("/:uuid/ledger", accounts.GetAccountLedger) // Solde courant, ?start_date=&end_date=
+	ac.Get("/:uuid/transfers", accounts.GetAccountTransfers)
+	ac.Post("/create", accounts.CreateAccount)
+	ac.Put("/update/:uuid", accounts.UpdateAccount)
+	ac.Post("/transfers", accounts.CreateTransfer)
+
+	// Categories controller
+	cat := api.Group("/categories")
+	cat.Get("/tree", categories.GetCategoryTree) // ?type=Income|Expense&active=
+	cat.Get("/get/:uuid", categories.GetCategory)
+	cat.Post("/create", categories.CreateCategory)
+	cat.Put("/update/:uuid", categories.UpdateCategory)
+
+	// Budgets controller
+	bu := api.Group("/budgets")
+	bu.Get("/all", budgets.GetBudgets)         // ?year=&month=&building=&category_uuid=
+	bu.Get("/report", budgets.GetBudgetReport) // Budget vs réalisé, ?year=&month=&building=
+	bu.Post("/save", budgets.SaveBudget)       // Crée ou remplace le budget du mois
+	bu.Delete("/delete/:uuid", budgets.DeleteBudget)
+
+	// Recurring expenses controller
+	re := api.Group("/recurring-expenses")
+	re.Get("/all/paginate", recurring.GetPaginatedRecurringExpenses) // ?search=&user_uuid=&active=
+	re.Get("/pending", recurring.GetPendingRecurringDrafts)          // Brouillons à comptabiliser, ?user_uuid=
+	re.Get("/get/:uuid", recurring.GetRecurringExpense)
+	re.Post("/create", recurring.CreateRecurringExpense)
+	re.Put("/update/:uuid", recurring.UpdateRecurringExpense)
+	re.Delete("/delete/:uuid", recurring.DeleteRecurringExpense)
+	re.Post("/run", middlewares.HasRole("Supervisor", "Admin"), recurring.RunRecurringExpenses) // Crée les brouillons échus sans attendre
+
+	// Exchange rates controller
+	ra := api.Group("/rates")
+	ra.Get("/all", rates.GetExchangeRates)
+	ra.Get("/at", rates.GetExchangeRateAt) // Taux en vigueur, ?currency=CDF&date=YYYY-MM-DD
+	ra.Post("/create", middlewares.HasRole("Supervisor", "Admin"), rates.CreateExchangeRate)
+	ra.Put("/update/:uuid", middlewares.HasRole("Supervisor", "Admin"), rates.UpdateExchangeRate)
+	ra.Delete("/delete/:uuid", middlewares.HasRole("Supervisor", "Admin"), rates.DeleteExchangeRate)
+
+	// Accounting periods controller
+	pe := api.Group("/periods")
+	pe.Get("/all", periods.GetPeriods)
+	pe.Get("/:year/:month/reconciliation", periods.GetPeriodReconciliation)
+	pe.Post("/:year/:month/close", middlewares.HasRole("Supervisor", "Admin"), periods.ClosePeriod) // Comptage physique par caisse et devise
+
 	// Dashboard controller
 	d := api.Group("/dashboard")
 	d.Get("/stats", dashboard.GetDashboardStats)                 // Statistiques générales
@@ -72,5 +192,12 @@ func Setup(app *fiber.App) {
 	d.Get("/appartments-stats", dashboard.GetAppartmentStats)     // Statistiques de paiement par appartement
 	d.Get("/occupancy-stats", dashboard.GetOccupancyStats)       // Statistiques d'occupation
 	d.Get("/top-managers", dashboard.GetTopManagers)             // Classement des meilleurs managers
+	d.Get("/category-totals", dashboard.GetCategoryTotals)       // Totaux par catégorie
+	d.Get("/compare", dashboard.GetPeriodComparison)             // Comparaison de deux périodes
+	d.Get("/rent-roll", dashboard.GetRentRoll)                   // Rôle des loyers et taux d'encaissement
+	d.Get("/forecast", dashboard.GetCashFlowForecast)            // Prévision de trésorerie des prochains mois
+	d.Get("/export/:report", dashboard.ExportReport)             // Export CSV, XLSX ou PDF d'un rapport
+	d.Get("/summaries/check", dashboard.CheckDailySummaries)     // Résumés journaliers comparés aux entrées
+	d.Post("/summaries/rebuild", middlewares.HasRole("Supervisor", "Admin"), dashboard.RebuildDailySummaries)
 
 }
@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"strings"
+)
+
+var frenchUnits = []string{
+	"zéro", "un", "deux", "trois", "quatre", "cinq", "six", "sept", "huit", "neuf",
+	"dix", "onze", "douze", "treize", "quatorze", "quinze", "seize",
+}
+
+var frenchTens = []string{
+	"", "dix", "vingt", "trente", "quarante", "cinquante", "soixante", "soixante", "quatre-vingt", "quatre-vingt",
+}
+
+// currencyNames donne le nom (singulier, pluriel) de l'unité et de la subdivision de chaque devise
+var currencyNames = map[string][4]string{
+	"USD": {"dollar américain", "dollars américains", "cent", "cents"},
+	"CDF": {"franc congolais", "francs congolais", "centime", "centimes"},
+	"EUR": {"euro", "euros", "centime", "centimes"},
+	"ZAR": {"rand", "rands", "cent", "cents"},
+}
+
+// NumberInWords écrit un entier positif en toutes lettres en français
+// (orthographe traditionnelle), ex. 1281 -> "mille deux cent quatre-vingt-un"
+func NumberInWords(n int64) string {
+	if n == 0 {
+		return frenchUnits[0]
+	}
+
+	scales := []struct {
+		value    int64
+		singular string
+		plural   string
+	}{
+		{1_000_000_000, "milliard", "milliards"},
+		{1_000_000, "million", "millions"},
+	}
+
+	var parts []string
+	for _, scale := range scales {
+		if count := n / scale.value; count > 0 {
+			name := scale.plural
+			if count == 1 {
+				name = scale.singular
+			}
+			// Million et milliard sont des noms : "deux cents millions"
+			parts = append(parts, numberInWordsBelowThousand(count, true)+" "+name)
+			n %= scale.value
+		}
+	}
+
+	if thousands := n / 1000; thousands > 0 {
+		if thousands == 1 {
+			parts = append(parts, "mille")
+		} else {
+			// Mille est invariable et "cent"/"vingt" ne prennent pas de s devant : "deux cent mille"
+			parts = append(parts, numberInWordsBelowThousand(thousands, false)+" mille")
+		}
+		n %= 1000
+	}
+
+	if n > 0 {
+		parts = append(parts, numberInWordsBelowThousand(n, true))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// numberInWordsBelowThousand écrit un nombre entre 1 et 999. final indique si le
+// nombre termine l'expression, auquel cas "cents" et "quatre-vingts" prennent un s.
+func numberInWordsBelowThousand(n int64, final bool) string {
+	var parts []string
+
+	if hundreds := n / 100; hundreds > 0 {
+		rest := n % 100
+		switch {
+		case hundreds == 1:
+			parts = append(parts, "cent")
+		case rest == 0 && final:
+			parts = append(parts, frenchUnits[hundreds]+" cents")
+		default:
+			parts = append(parts, frenchUnits[hundreds]+" cent")
+		}
+		n = rest
+	}
+
+	if n > 0 {
+		parts = append(parts, numberInWordsBelowHundred(n, final))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func numberInWordsBelowHundred(n int64, final bool) string {
+	if n <= 16 {
+		return frenchUnits[n]
+	}
+	if n < 20 {
+		return "dix-" + frenchUnits[n-10]
+	}
+
+	tens, units := n/10, n%10
+
+	// 70-79 et 90-99 se construisent sur soixante et quatre-vingt + 10..19
+	if tens == 7 || tens == 9 {
+		units += 10
+	}
+
+	word := frenchTens[tens]
+	switch {
+	case units == 0 && tens == 8:
+		if final {
+			return word + "s"
+		}
+		return word
+	case units == 0:
+		return word
+	case (units == 1 || units == 11) && tens != 8 && tens != 9:
+		return word + " et " + numberInWordsBelowHundred(units, final)
+	default:
+		return word + "-" + numberInWordsBelowHundred(units, final)
+	}
+}
+
+// AmountInWords écrit un montant en toutes lettres avec sa devise,
+// ex. AmountInWords(250.5, "USD") -> "deux cent cinquante dollars américains et cinquante cents"
+func AmountInWords(amount Amount, currency string) string {
+	names, ok := currencyNames[currency]
+	if !ok {
+		names = [4]string{currency, currency, "centième", "centièmes"}
+	}
+
+	cents := amount.Abs().Decimal().Shift(2).Round(0).IntPart()
+	units, fraction := cents/100, cents%100
+
+	unitName := names[1]
+	if units <= 1 {
+		unitName = names[0]
+	}
+	// "un million de dollars"
+	words := NumberInWords(units)
+	if units > 0 && units%1_000_000 == 0 {
+		words += " de"
+	}
+	result := words + " " + unitName
+
+	if fraction > 0 {
+		fractionName := names[3]
+		if fraction == 1 {
+			fractionName = names[2]
+		}
+		result += " et " + NumberInWords(fraction) + " " + fractionName
+	}
+
+	if amount.IsNegative() {
+		result = "moins " + result
+	}
+	return result
+}
@@ -1,58 +1,82 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
 )
 
-// Configuration des taux de change par défaut
+// Devises de référence : les taux sont exprimés pour 1 USD et les comptes de
+// trésorerie sont tenus en USD et en CDF
 const (
-	DefaultUSDToCDF = 2700.0  // Taux par défaut USD vers CDF
-	DefaultCDFToUSD = 0.00037 // Taux par défaut CDF vers USD
+	CurrencyUSD = "USD"
+	CurrencyCDF = "CDF"
 )
 
-// ConvertCurrency convertit un montant d'une devise à une autre en utilisant un taux manuel
-func ConvertCurrency(amount float64, rate float64) float64 {
-	return amount * rate
-}
+// ErrInvalidRate est retournée quand aucun taux valide n'est fourni pour une conversion
+var ErrInvalidRate = errors.New("invalid exchange rate")
 
-// GetDefaultExchangeRate retourne les taux de change par défaut
-func GetDefaultExchangeRate(from, to string) float64 {
-	switch {
-	case from == "USD" && to == "CDF":
-		return DefaultUSDToCDF
-	case from == "CDF" && to == "USD":
-		return DefaultCDFToUSD
-	default:
-		return 1.0 // Aucune conversion par défaut
+// EnabledCurrencies retourne les devises acceptées par la caisse, lues dans CURRENCIES
+// (codes ISO 4217 séparés par des virgules, ex. USD,CDF,EUR). USD et CDF sont toujours actives.
+func EnabledCurrencies() []string {
+	currencies := []string{CurrencyUSD, CurrencyCDF}
+	for _, code := range strings.Split(Env("CURRENCIES"), ",") {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if !isCurrencyCode(code) || containsCurrency(currencies, code) {
+			continue
+		}
+		currencies = append(currencies, code)
 	}
+	return currencies
+}
+
+// IsCurrency indique si la devise est activée
+func IsCurrency(currency string) bool {
+	return containsCurrency(EnabledCurrencies(), currency)
 }
 
-// ConvertUSDToCDF convertit USD vers CDF avec un taux donné
-func ConvertUSDToCDF(usdAmount float64, rate float64) float64 {
-	if rate <= 0 {
-		rate = DefaultUSDToCDF
+// isCurrencyCode vérifie le format d'un code ISO 4217 : trois lettres majuscules
+func isCurrencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
 	}
-	return ConvertCurrency(usdAmount, rate)
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
 }
 
-// ConvertCDFToUSD convertit CDF vers USD avec un taux donné
-func ConvertCDFToUSD(cdfAmount float64, rate float64) float64 {
-	if rate <= 0 {
-		rate = DefaultCDFToUSD
+func containsCurrency(currencies []string, currency string) bool {
+	for _, c := range currencies {
+		if c == currency {
+			return true
+		}
 	}
-	return ConvertCurrency(cdfAmount, rate)
+	return false
 }
 
-// ConvertWithDefaultRate convertit en utilisant les taux par défaut
-func ConvertWithDefaultRate(amount float64, fromCurrency, toCurrency string) (float64, error) {
+// ConvertCurrency convertit un montant d'une devise à une autre en passant par le dollar.
+// Les taux sont exprimés en unités de chaque devise pour 1 USD ; celui du dollar est ignoré.
+func ConvertCurrency(amount Amount, fromCurrency, toCurrency string, fromRate, toRate decimal.Decimal) (Amount, error) {
 	if fromCurrency == toCurrency {
 		return amount, nil
 	}
-
-	rate := GetDefaultExchangeRate(fromCurrency, toCurrency)
-	if rate == 1.0 && fromCurrency != toCurrency {
-		return 0, fmt.Errorf("no conversion rate available for %s to %s", fromCurrency, toCurrency)
+	value := amount.Decimal()
+	if fromCurrency != CurrencyUSD {
+		if !fromRate.IsPositive() {
+			return Zero, fmt.Errorf("%w for %s: %s", ErrInvalidRate, fromCurrency, fromRate)
+		}
+		value = value.DivRound(fromRate, 12)
 	}
-
-	return ConvertCurrency(amount, rate), nil
+	if toCurrency != CurrencyUSD {
+		if !toRate.IsPositive() {
+			return Zero, fmt.Errorf("%w for %s: %s", ErrInvalidRate, toCurrency, toRate)
+		}
+		value = value.Mul(toRate)
+	}
+	return AmountFromDecimal(value), nil
 }
@@ -0,0 +1,472 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/gofiber/fiber/v2"
+	"github.com/xuri/excelize/v2"
+)
+
+// Formats d'export supportés
+const (
+	ExportCSV  = "csv"
+	ExportXLSX = "xlsx"
+	ExportPDF  = "pdf"
+)
+
+// MaxPDFRows est le nombre maximal de lignes d'un export PDF. fpdf construit tout le
+// document en mémoire avant de l'écrire : au-delà, il faut exporter en CSV ou XLSX,
+// qui sont écrits à mesure que les lignes arrivent.
+const MaxPDFRows = 5000
+
+// ExportColumn décrit une colonne d'un export. Width est exprimée en millimètres (PDF).
+type ExportColumn struct {
+	Title string
+	Width float64
+}
+
+// TableWriter écrit un tableau ligne par ligne dans un des formats d'export.
+// Les cellules peuvent être des string, int, int64, float64 ou time.Time.
+type TableWriter interface {
+	WriteRow(cells []interface{}) error
+	// WriteFooter écrit une ligne de total, mise en évidence quand le format le permet
+	WriteFooter(cells []interface{}) error
+	// Close termine le document et l'écrit dans le writer sous-jacent
+	Close() error
+}
+
+// IsExportFormat indique si le format demandé est supporté
+func IsExportFormat(format string) bool {
+	return format == ExportCSV || format == ExportXLSX || format == ExportPDF
+}
+
+// ExportContentType retourne le type MIME du format d'export
+func ExportContentType(format string) string {
+	switch format {
+	case ExportXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case ExportPDF:
+		return "application/pdf"
+	default:
+		return "text/csv; charset=utf-8"
+	}
+}
+
+// ExportFilename construit le nom du fichier téléchargé, ex. caisses-2025-01-31.xlsx
+func ExportFilename(name, format string) string {
+	return fmt.Sprintf("%s-%s.%s", name, time.Now().Format("2006-01-02"), format)
+}
+
+// NewTableWriter crée un TableWriter pour le format donné, l'en-tête est écrit immédiatement.
+// subtitle est affiché sous le titre dans le PDF (période, filtres...).
+func NewTableWriter(format string, w io.Writer, title, subtitle string, columns []ExportColumn) (TableWriter, error) {
+	switch format {
+	case ExportCSV:
+		return newCSVTableWriter(w, columns)
+	case ExportXLSX:
+		return newXLSXTableWriter(w, title, columns)
+	case ExportPDF:
+		return newPDFTableWriter(w, title, subtitle, columns), nil
+	}
+	return nil, fmt.Errorf("unsupported export format '%s', expected csv, xlsx or pdf", format)
+}
+
+// CheckExportSize refuse un export PDF de plus de MaxPDFRows lignes, avant que la réponse
+// ne soit commencée
+func CheckExportSize(format string, rows int64) error {
+	if format == ExportPDF && rows > MaxPDFRows {
+		return fiber.NewError(fiber.StatusBadRequest,
+			fmt.Sprintf("PDF exports are limited to %d rows (%d requested), use csv or xlsx", MaxPDFRows, rows))
+	}
+	return nil
+}
+
+// StreamTable envoie l'export en réponse. Un CSV est écrit à mesure que write lit les
+// lignes : write est alors appelé après la fin du handler et ne doit pas utiliser le contexte
+// de la requête ; ses erreurs sont journalisées, l'en-tête de la réponse étant déjà envoyé.
+// Les requêtes qui peuvent échouer doivent donc être lancées avant. Un XLSX ou un PDF est
+// construit entièrement en mémoire de toute façon (le PDF est limité à MaxPDFRows lignes) :
+// il est écrit dans un tampon avant l'envoi et les erreurs de write sont retournées.
+func StreamTable(c *fiber.Ctx, format, name, title, subtitle string, columns []ExportColumn, write func(TableWriter) error) error {
+	if format != ExportCSV {
+		var buf bytes.Buffer
+		table, err := NewTableWriter(format, &buf, title, subtitle, columns)
+		if err != nil {
+			return err
+		}
+		if err := write(table); err != nil {
+			table.Close()
+			return err
+		}
+		if err := table.Close(); err != nil {
+			return err
+		}
+		setExportHeaders(c, format, name)
+		return c.Send(buf.Bytes())
+	}
+
+	setExportHeaders(c, format, name)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer w.Flush()
+
+		table, err := NewTableWriter(format, w, title, subtitle, columns)
+		if err != nil {
+			log.Println(name, "export:", err)
+			return
+		}
+		if err := write(table); err != nil {
+			log.Println(name, "export:", err)
+			return
+		}
+		if err := table.Close(); err != nil {
+			log.Println(name, "export:", err)
+		}
+	})
+	return nil
+}
+
+func setExportHeaders(c *fiber.Ctx, format, name string) {
+	c.Set(fiber.HeaderContentType, ExportContentType(format))
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+ExportFilename(name, format)+`"`)
+}
+
+// FormatAmount formate un montant avec deux décimales et un séparateur de milliers, ex. 12 500.00
+func FormatAmount(amount Amount) string {
+	s := amount.String()
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	intPart, decPart := s[:len(s)-3], s[len(s)-3:]
+
+	var b strings.Builder
+	for i, r := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteRune(r)
+	}
+	return sign + b.String() + decPart
+}
+
+func formatCell(cell interface{}) string {
+	switch v := cell.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	case Amount:
+		return v.String()
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format("2006-01-02 15:04")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// CSV
+
+type csvTableWriter struct {
+	w *csv.Writer
+}
+
+func newCSVTableWriter(w io.Writer, columns []ExportColumn) (*csvTableWriter, error) {
+	// BOM pour qu'Excel reconnaisse l'UTF-8 (accents)
+	if _, err := io.WriteString(w, "\xef\xbb\xbf"); err != nil {
+		return nil, err
+	}
+	t := &csvTableWriter{w: csv.NewWriter(w)}
+
+	header := make([]interface{}, len(columns))
+	for i, col := range columns {
+		header[i] = col.Title
+	}
+	return t, t.WriteRow(header)
+}
+
+func (t *csvTableWriter) WriteRow(cells []interface{}) error {
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		record[i] = formatCell(cell)
+	}
+	return t.w.Write(record)
+}
+
+func (t *csvTableWriter) WriteFooter(cells []interface{}) error {
+	return t.WriteRow(cells)
+}
+
+func (t *csvTableWriter) Close() error {
+	t.w.Flush()
+	return t.w.Error()
+}
+
+// XLSX
+
+type xlsxTableWriter struct {
+	out    io.Writer
+	book   *excelize.File
+	stream *excelize.StreamWriter
+	row    int
+	bold   int
+	date   int
+	amount int
+}
+
+func newXLSXTableWriter(w io.Writer, title string, columns []ExportColumn) (*xlsxTableWriter, error) {
+	book := excelize.NewFile()
+	sheet := "Sheet1"
+	if title != "" {
+		// Les noms de feuille sont limités à 31 caractères
+		name := []rune(strings.NewReplacer("/", "-", "\\", "-", ":", "-", "?", "", "*", "", "[", "(", "]", ")").Replace(title))
+		if len(name) > 31 {
+			name = name[:31]
+		}
+		if err := book.SetSheetName(sheet, string(name)); err == nil {
+			sheet = string(name)
+		}
+	}
+
+	stream, err := book.NewStreamWriter(sheet)
+	if err != nil {
+		book.Close()
+		return nil, err
+	}
+
+	t := &xlsxTableWriter{out: w, book: book, stream: stream, row: 1}
+	t.bold, _ = book.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	t.date, _ = book.NewStyle(&excelize.Style{NumFmt: 22})  // m/d/yy h:mm
+	t.amount, _ = book.NewStyle(&excelize.Style{NumFmt: 4}) // #,##0.00
+
+	for i, col := range columns {
+		if col.Width > 0 {
+			stream.SetColWidth(i+1, i+1, col.Width/2)
+		}
+	}
+
+	header := make([]interface{}, len(columns))
+	for i, col := range columns {
+		header[i] = excelize.Cell{StyleID: t.bold, Value: col.Title}
+	}
+	if err := t.write(header); err != nil {
+		book.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *xlsxTableWriter) cells(cells []interface{}, bold bool) []interface{} {
+	row := make([]interface{}, len(cells))
+	for i, cell := range cells {
+		style := 0
+		switch v := cell.(type) {
+		case float64:
+			style = t.amount
+		case Amount:
+			style = t.amount
+			cell = v.Float64()
+		case time.Time:
+			if v.IsZero() {
+				cell = nil
+			} else {
+				style = t.date
+			}
+		}
+		if bold {
+			style = t.bold
+		}
+		row[i] = excelize.Cell{StyleID: style, Value: cell}
+	}
+	return row
+}
+
+func (t *xlsxTableWriter) write(row []interface{}) error {
+	cell, err := excelize.CoordinatesToCellName(1, t.row)
+	if err != nil {
+		return err
+	}
+	t.row++
+	return t.stream.SetRow(cell, row)
+}
+
+func (t *xlsxTableWriter) WriteRow(cells []interface{}) error {
+	return t.write(t.cells(cells, false))
+}
+
+func (t *xlsxTableWriter) WriteFooter(cells []interface{}) error {
+	return t.write(t.cells(cells, true))
+}
+
+func (t *xlsxTableWriter) Close() error {
+	defer t.book.Close()
+	if err := t.stream.Flush(); err != nil {
+		return err
+	}
+	return t.book.Write(t.out)
+}
+
+// PDF
+
+type pdfTableWriter struct {
+	out       io.Writer
+	pdf       *fpdf.Fpdf
+	tr        func(string) string
+	columns   []ExportColumn
+	rowHeight float64
+	rows      int
+}
+
+func newPDFTableWriter(w io.Writer, title, subtitle string, columns []ExportColumn) *pdfTableWriter {
+	pdf := fpdf.New("L", "mm", "A4", "")
+	pdf.SetMargins(10, 10, 10)
+	pdf.SetAutoPageBreak(true, 12)
+	pdf.AliasNbPages("")
+
+	t := &pdfTableWriter{
+		out:       w,
+		pdf:       pdf,
+		tr:        pdf.UnicodeTranslatorFromDescriptor(""),
+		columns:   fitColumns(columns, 277), // Largeur utile d'un A4 paysage
+		rowHeight: 6,
+	}
+
+	// Répéter l'en-tête du tableau en haut de chaque nouvelle page
+	pdf.SetHeaderFunc(func() {
+		if pdf.PageNo() > 1 {
+			t.writeHeader()
+		}
+	})
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-10)
+		pdf.SetFont("Helvetica", "I", 7)
+		pdf.CellFormat(0, 5, t.tr(fmt.Sprintf("Généré le %s - page %d/{nb}", time.Now().Format("02/01/2006 15:04"), pdf.PageNo())), "", 0, "R", false, 0, "")
+	})
+
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "B", 14)
+	pdf.CellFormat(0, 8, t.tr(title), "", 1, "L", false, 0, "")
+	if subtitle != "" {
+		pdf.SetFont("Helvetica", "", 9)
+		pdf.CellFormat(0, 5, t.tr(subtitle), "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(2)
+	t.writeHeader()
+
+	return t
+}
+
+// fitColumns répartit la largeur disponible entre les colonnes sans largeur
+// et réduit proportionnellement si le total dépasse la page
+func fitColumns(columns []ExportColumn, available float64) []ExportColumn {
+	fitted := make([]ExportColumn, len(columns))
+	copy(fitted, columns)
+
+	var fixed float64
+	var auto int
+	for _, col := range fitted {
+		if col.Width > 0 {
+			fixed += col.Width
+		} else {
+			auto++
+		}
+	}
+	if auto > 0 {
+		width := (available - fixed) / float64(auto)
+		if width < 15 {
+			width = 15
+		}
+		for i := range fitted {
+			if fitted[i].Width <= 0 {
+				fitted[i].Width = width
+			}
+		}
+	}
+
+	var total float64
+	for _, col := range fitted {
+		total += col.Width
+	}
+	if total > available {
+		for i := range fitted {
+			fitted[i].Width = fitted[i].Width * available / total
+		}
+	}
+	return fitted
+}
+
+func (t *pdfTableWriter) writeHeader() {
+	t.pdf.SetFont("Helvetica", "B", 9)
+	t.pdf.SetFillColor(230, 230, 230)
+	for _, col := range t.columns {
+		t.pdf.CellFormat(col.Width, t.rowHeight, t.tr(col.Title), "1", 0, "C", true, 0, "")
+	}
+	t.pdf.Ln(-1)
+}
+
+func (t *pdfTableWriter) writeCells(cells []interface{}, style string) {
+	t.pdf.SetFont("Helvetica", style, 8)
+	for i, col := range t.columns {
+		var cell interface{}
+		if i < len(cells) {
+			cell = cells[i]
+		}
+
+		align := "L"
+		text := formatCell(cell)
+		switch v := cell.(type) {
+		case float64:
+			align = "R"
+			text = FormatAmount(NewAmount(v))
+		case Amount:
+			align = "R"
+			text = FormatAmount(v)
+		case int, int64:
+			align = "R"
+		case time.Time:
+			if !v.IsZero() {
+				text = v.Format("02/01/2006")
+			}
+		}
+
+		// Tronquer le texte trop long pour la cellule
+		text = t.tr(text)
+		for len(text) > 1 && t.pdf.GetStringWidth(text) > col.Width-2 {
+			text = text[:len(text)-1]
+		}
+
+		t.pdf.CellFormat(col.Width, t.rowHeight, text, "1", 0, align, false, 0, "")
+	}
+	t.pdf.Ln(-1)
+}
+
+func (t *pdfTableWriter) WriteRow(cells []interface{}) error {
+	// Garde-fou : les exports vérifient la taille avec CheckExportSize avant de commencer
+	if t.rows++; t.rows > MaxPDFRows {
+		return fmt.Errorf("PDF exports are limited to %d rows", MaxPDFRows)
+	}
+	t.writeCells(cells, "")
+	return t.pdf.Error()
+}
+
+func (t *pdfTableWriter) WriteFooter(cells []interface{}) error {
+	t.writeCells(cells, "B")
+	return t.pdf.Error()
+}
+
+func (t *pdfTableWriter) Close() error {
+	return t.pdf.Output(t.out)
+}
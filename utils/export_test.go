@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestCheckExportSize(t *testing.T) {
+	tests := []struct {
+		format  string
+		rows    int64
+		wantErr bool
+	}{
+		{ExportPDF, 0, false},
+		{ExportPDF, MaxPDFRows, false},
+		{ExportPDF, MaxPDFRows + 1, true},
+		{ExportCSV, MaxPDFRows + 1, false},
+		{ExportXLSX, MaxPDFRows + 1, false},
+	}
+	for _, tt := range tests {
+		if err := CheckExportSize(tt.format, tt.rows); (err != nil) != tt.wantErr {
+			t.Errorf("CheckExportSize(%s, %d) = %v, want error %v", tt.format, tt.rows, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPDFTableWriterRowLimit(t *testing.T) {
+	var out bytes.Buffer
+	table, err := NewTableWriter(ExportPDF, &out, "Test", "", []ExportColumn{{Title: "N"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < MaxPDFRows; i++ {
+		if err := table.WriteRow([]interface{}{i}); err != nil {
+			t.Fatalf("row %d: %v", i, err)
+		}
+	}
+	if err := table.WriteRow([]interface{}{MaxPDFRows}); err == nil {
+		t.Fatalf("row %d was accepted, want the PDF row limit error", MaxPDFRows)
+	}
+	if err := table.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(out.Bytes(), []byte("%PDF")) {
+		t.Error("Close did not write a PDF document")
+	}
+}
+
+func TestStreamTableErrors(t *testing.T) {
+	failing := errors.New("query failed")
+	tests := []struct {
+		format string
+		write  func(TableWriter) error
+		want   int
+	}{
+		{ExportXLSX, func(TableWriter) error { return failing }, fiber.StatusInternalServerError},
+		{ExportPDF, func(TableWriter) error { return failing }, fiber.StatusInternalServerError},
+		{ExportXLSX, func(table TableWriter) error { return table.WriteRow([]interface{}{1}) }, fiber.StatusOK},
+		{ExportPDF, func(table TableWriter) error { return table.WriteRow([]interface{}{1}) }, fiber.StatusOK},
+		{ExportCSV, func(table TableWriter) error { return table.WriteRow([]interface{}{1}) }, fiber.StatusOK},
+	}
+	for _, tt := range tests {
+		app := fiber.New()
+		app.Get("/", func(c *fiber.Ctx) error {
+			if err := StreamTable(c, tt.format, "test", "Test", "", []ExportColumn{{Title: "N"}}, tt.write); err != nil {
+				return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+			}
+			return nil
+		})
+		resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.format, resp.StatusCode, tt.want)
+		}
+		if tt.want == fiber.StatusOK && resp.Header.Get(fiber.HeaderContentType) != ExportContentType(tt.format) {
+			t.Errorf("%s: content type %q", tt.format, resp.Header.Get(fiber.HeaderContentType))
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"strings"
+)
+
+// MailAttachment est une pièce jointe d'un email
+type MailAttachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// SendMail envoie un email HTML avec des pièces jointes éventuelles,
+// avec la même configuration SMTP que la réinitialisation du mot de passe
+func SendMail(to []string, subject, htmlBody string, attachments ...MailAttachment) error {
+	from := Env("EMAIL_FROM")
+	host := Env("EMAIL_HOST")
+	auth := smtp.PlainAuth("", Env("EMAIL_USERNAME"), Env("EMAIL_PASSWORD"), host)
+
+	boundary := GenerateRandomString(24)
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", boundary)
+
+	fmt.Fprintf(&msg, "--%s\r\n", boundary)
+	msg.WriteString("Content-Type: text/html; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	writeBase64Lines(&msg, []byte(htmlBody))
+
+	for _, a := range attachments {
+		fmt.Fprintf(&msg, "--%s\r\n", boundary)
+		fmt.Fprintf(&msg, "Content-Type: %s\r\n", a.ContentType)
+		msg.WriteString("Content-Transfer-Encoding: base64\r\n")
+		fmt.Fprintf(&msg, "Content-Disposition: attachment; filename=%q\r\n\r\n", a.Filename)
+		writeBase64Lines(&msg, a.Data)
+	}
+	fmt.Fprintf(&msg, "--%s--\r\n", boundary)
+
+	return smtp.SendMail(host+":"+Env("EMAIL_PORT"), auth, from, to, msg.Bytes())
+}
+
+// writeBase64Lines encode en base64 par lignes de 76 caractères (RFC 2045)
+func writeBase64Lines(buf *bytes.Buffer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded + "\r\n")
+}
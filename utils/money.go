@@ -0,0 +1,186 @@
+package utils
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// MoneyScale est le nombre de décimales des montants (centimes)
+const MoneyScale = 2
+
+// Amount est un montant exact en virgule fixe à deux décimales. Il est stocké en
+// numeric(20,2) et sérialisé en JSON comme un nombre à deux décimales (ex. 250.50) ;
+// il accepte en entrée un nombre ou une chaîne.
+type Amount struct {
+	d decimal.Decimal
+}
+
+// Zero est le montant nul
+var Zero = Amount{}
+
+func init() {
+	// Les taux (decimal.Decimal) sont sérialisés comme des nombres, comme les montants
+	decimal.MarshalJSONWithoutQuotes = true
+}
+
+// NewAmount crée un montant à partir d'un float64, arrondi au centime
+func NewAmount(value float64) Amount {
+	return Amount{decimal.NewFromFloat(value).Round(MoneyScale)}
+}
+
+// AmountFromDecimal crée un montant à partir d'un décimal, arrondi au centime
+func AmountFromDecimal(d decimal.Decimal) Amount {
+	return Amount{d.Round(MoneyScale)}
+}
+
+// ParseMoney lit un montant ("1250.50", "1 250,50"...). Une chaîne vide vaut 0,
+// un séparateur ambigu ("1,250") est une erreur (voir normalizeNumber).
+func ParseMoney(value string) (Amount, error) {
+	value, err := normalizeNumber(value)
+	if err != nil {
+		return Zero, err
+	}
+	if value == "" {
+		return Zero, nil
+	}
+	d, err := decimal.NewFromString(value)
+	if err != nil {
+		return Zero, fmt.Errorf("invalid amount '%s'", value)
+	}
+	return AmountFromDecimal(d), nil
+}
+
+// Decimal retourne la valeur décimale du montant
+func (a Amount) Decimal() decimal.Decimal { return a.d }
+
+// Float64 retourne le montant en float64, pour l'affichage et les tableurs uniquement
+func (a Amount) Float64() float64 {
+	f, _ := a.d.Float64()
+	return f
+}
+
+func (a Amount) Add(b Amount) Amount { return Amount{a.d.Add(b.d)} }
+func (a Amount) Sub(b Amount) Amount { return Amount{a.d.Sub(b.d)} }
+func (a Amount) Neg() Amount         { return Amount{a.d.Neg()} }
+func (a Amount) Abs() Amount         { return Amount{a.d.Abs()} }
+
+// Mul multiplie le montant par un facteur (taux, nombre de mois...), arrondi au centime
+func (a Amount) Mul(factor decimal.Decimal) Amount { return AmountFromDecimal(a.d.Mul(factor)) }
+
+// Div divise le montant par un diviseur non nul, arrondi au centime
+func (a Amount) Div(divisor decimal.Decimal) Amount {
+	return AmountFromDecimal(a.d.DivRound(divisor, MoneyScale+4))
+}
+
+// Percent retourne a / b * 100, 0 si b est nul
+func (a Amount) Percent(b Amount) float64 {
+	if b.d.IsZero() {
+		return 0
+	}
+	f, _ := a.d.Mul(decimal.NewFromInt(100)).DivRound(b.d, 2).Float64()
+	return f
+}
+
+func (a Amount) IsZero() bool              { return a.d.IsZero() }
+func (a Amount) IsNegative() bool          { return a.d.IsNegative() }
+func (a Amount) IsPositive() bool          { return a.d.IsPositive() }
+func (a Amount) Cmp(b Amount) int          { return a.d.Cmp(b.d) }
+func (a Amount) Equal(b Amount) bool       { return a.d.Equal(b.d) }
+func (a Amount) GreaterThan(b Amount) bool { return a.d.GreaterThan(b.d) }
+
+// String retourne le montant avec deux décimales, ex. 1250.50
+func (a Amount) String() string { return a.d.StringFixed(MoneyScale) }
+
+// MarshalJSON écrit le montant comme un nombre à deux décimales
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON accepte un nombre, une chaîne ou null. Un nombre JSON a toujours le point
+// pour séparateur décimal (1.250 vaut 1.25) : il n'est pas lu comme un texte de tableur.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	data = bytes.Trim(bytes.TrimSpace(data), `"`)
+	if len(data) == 0 || string(data) == "null" {
+		*a = Zero
+		return nil
+	}
+	d, err := decimal.NewFromString(string(data))
+	if err != nil {
+		return fmt.Errorf("invalid amount '%s'", data)
+	}
+	*a = AmountFromDecimal(d)
+	return nil
+}
+
+// UnmarshalText permet de lire un montant dans un formulaire ou une query
+func (a *Amount) UnmarshalText(text []byte) error {
+	parsed, err := ParseMoney(string(text))
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// Scan lit une colonne numeric (ou double precision avant migration)
+func (a *Amount) Scan(value interface{}) error {
+	if value == nil {
+		*a = Zero
+		return nil
+	}
+	var d decimal.Decimal
+	if err := d.Scan(value); err != nil {
+		return err
+	}
+	*a = AmountFromDecimal(d)
+	return nil
+}
+
+// Value écrit le montant en texte pour une colonne numeric, sans passer par un float
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// GormDBDataType déclare le type de colonne des montants
+func (Amount) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	return "numeric(20,2)"
+}
+
+// Money est un montant accompagné de sa devise
+type Money struct {
+	Amount   Amount `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// NewMoney crée un montant dans une devise
+func NewMoney(amount Amount, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// Add additionne deux montants de même devise
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, fmt.Errorf("cannot add %s and %s amounts", m.Currency, o.Currency)
+	}
+	return Money{Amount: m.Amount.Add(o.Amount), Currency: m.Currency}, nil
+}
+
+// Convert convertit le montant dans une autre devise, avec le taux de sa devise et celui
+// de la devise cible (unités pour 1 USD)
+func (m Money) Convert(currency string, rate, targetRate decimal.Decimal) (Money, error) {
+	amount, err := ConvertCurrency(m.Amount, m.Currency, currency, rate, targetRate)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+// String retourne le montant et sa devise, ex. 1250.50 USD
+func (m Money) String() string {
+	return m.Amount.String() + " " + m.Currency
+}
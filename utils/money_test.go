@@ -0,0 +1,81 @@
+package utils
+
+import "testing"
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"", "0.00", false},
+		{"1250", "1250.00", false},
+		{"1250.5", "1250.50", false},
+		{"1250,50", "1250.50", false},
+		{"1 250,50", "1250.50", false},
+		{"1 250,50", "1250.50", false},
+		{"1.250,50", "1250.50", false},
+		{"1,250.50", "1250.50", false},
+		{"1,250,000", "1250000.00", false},
+		{"1.250.000", "1250000.00", false},
+		{"0,125", "0.13", false},
+		{"-12,5", "-12.50", false},
+		{"1,250", "", true},
+		{"1.250", "", true},
+		{"12,50,0", "", true},
+		{"1,25.0,5", "", true},
+		{"abc", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseMoney(%q) = %s, want an error", tt.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseMoney(%q) returned %v", tt.value, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("ParseMoney(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestAmountUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		json    string
+		want    string
+		wantErr bool
+	}{
+		{`null`, "0.00", false},
+		{`""`, "0.00", false},
+		{`1250`, "1250.00", false},
+		{`1.250`, "1.25", false},
+		{`12.345`, "12.35", false},
+		{`"1250.50"`, "1250.50", false},
+		{`-12.5`, "-12.50", false},
+		{`1e3`, "1000.00", false},
+		{`"1 250,50"`, "", true},
+		{`"abc"`, "", true},
+	}
+	for _, tt := range tests {
+		var got Amount
+		err := got.UnmarshalJSON([]byte(tt.json))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("UnmarshalJSON(%s) = %s, want an error", tt.json, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("UnmarshalJSON(%s) returned %v", tt.json, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("UnmarshalJSON(%s) = %s, want %s", tt.json, got, tt.want)
+		}
+	}
+}
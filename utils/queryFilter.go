@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// QueryFilter regroupe les filtres communs des listes et des rapports, lus dans la query string :
+// période (start_date, end_date, dans le fuseau tz), recherche (search), gestionnaire (user_uuid)
+// et pagination (page, limit)
+type QueryFilter struct {
+	Location    *time.Location // Fuseau des dates de la période
+	Start       *time.Time     // Minuit de start_date, inclus
+	End         *time.Time     // Minuit du lendemain de end_date, exclu
+	Search      string
+	ManagerUUID string
+	Page        int
+	Limit       int
+}
+
+// ParseQueryFilter lit les filtres de la requête. Les dates sont au format YYYY-MM-DD et lues
+// dans le fuseau de la requête (voir RequestLocation) : un fuseau ou une date invalide, ou une
+// période dont la fin précède le début, est une *fiber.Error 400.
+// Une page ou une limite absente ou invalide prend la valeur par défaut.
+func ParseQueryFilter(c *fiber.Ctx, defaultLimit int) (*QueryFilter, error) {
+	loc, err := RequestLocation(c)
+	if err != nil {
+		return nil, err
+	}
+	filter := &QueryFilter{
+		Location:    loc,
+		Search:      strings.TrimSpace(c.Query("search")),
+		ManagerUUID: c.Query("user_uuid"),
+		Page:        1,
+		Limit:       defaultLimit,
+	}
+
+	if v := c.Query("start_date"); v != "" {
+		start, err := time.ParseInLocation("2006-01-02", v, loc)
+		if err != nil {
+			return nil, fiber.NewError(400, "Invalid start_date, expected YYYY-MM-DD")
+		}
+		filter.Start = &start
+	}
+	if v := c.Query("end_date"); v != "" {
+		end, err := time.ParseInLocation("2006-01-02", v, loc)
+		if err != nil {
+			return nil, fiber.NewError(400, "Invalid end_date, expected YYYY-MM-DD")
+		}
+		// Le lendemain, pour inclure toute la journée même un jour de changement d'heure
+		end = end.AddDate(0, 0, 1)
+		filter.End = &end
+	}
+	if filter.Start != nil && filter.End != nil && !filter.End.After(*filter.Start) {
+		return nil, fiber.NewError(400, "end_date cannot be before start_date")
+	}
+
+	if page, err := strconv.Atoi(c.Query("page", "1")); err == nil && page > 0 {
+		filter.Page = page
+	}
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 {
+		filter.Limit = limit
+	}
+	return filter, nil
+}
+
+// ApplyPeriod limite la requête à la période, sur la colonne de date donnée
+func (f *QueryFilter) ApplyPeriod(query *gorm.DB, column string) *gorm.DB {
+	if f.Start != nil {
+		query = query.Where(column+" >= ?", *f.Start)
+	}
+	if f.End != nil {
+		query = query.Where(column+" < ?", *f.End)
+	}
+	return query
+}
+
+// ApplySearch cherche le texte saisi dans l'une des colonnes données, sans tenir compte de la casse
+func (f *QueryFilter) ApplySearch(query *gorm.DB, columns ...string) *gorm.DB {
+	if f.Search == "" || len(columns) == 0 {
+		return query
+	}
+	conditions := make([]string, len(columns))
+	args := make([]interface{}, len(columns))
+	for i, column := range columns {
+		conditions[i] = column + " ILIKE ?"
+		args[i] = "%" + f.Search + "%"
+	}
+	return query.Where("("+strings.Join(conditions, " OR ")+")", args...)
+}
+
+// ApplyManager limite la requête au gestionnaire demandé, sur la colonne donnée
+func (f *QueryFilter) ApplyManager(query *gorm.DB, column string) *gorm.DB {
+	if f.ManagerUUID == "" {
+		return query
+	}
+	return query.Where(column+" = ?", f.ManagerUUID)
+}
+
+// Offset retourne le nombre de lignes des pages précédentes
+func (f *QueryFilter) Offset() int {
+	return (f.Page - 1) * f.Limit
+}
+
+// Paginate limite la requête à la page demandée
+func (f *QueryFilter) Paginate(query *gorm.DB) *gorm.DB {
+	return query.Offset(f.Offset()).Limit(f.Limit)
+}
+
+// Pagination retourne les métadonnées de pagination de la réponse
+func (f *QueryFilter) Pagination(totalRecords int64) map[string]interface{} {
+	return map[string]interface{}{
+		"total_records": totalRecords,
+		"total_pages":   int((totalRecords + int64(f.Limit) - 1) / int64(f.Limit)),
+		"current_page":  f.Page,
+		"page_size":     f.Limit,
+	}
+}
@@ -0,0 +1,295 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// ReadSpreadsheet lit toutes les lignes d'un fichier CSV ou XLSX envoyé en multipart.
+// Pour un XLSX, seule la première feuille est lue, avec les valeurs brutes des cellules.
+// Pour un CSV, le séparateur (virgule ou point-virgule) est détecté à partir de la ligne
+// d'en-tête.
+func ReadSpreadsheet(file *multipart.FileHeader) ([][]string, error) {
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(file.Filename)) {
+	case ".csv":
+		return readCSV(f)
+	case ".xlsx":
+		return readXLSX(f)
+	default:
+		return nil, fmt.Errorf("unsupported file type '%s', expected .csv or .xlsx", filepath.Ext(file.Filename))
+	}
+}
+
+func readCSV(r io.Reader) ([][]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")) // BOM UTF-8 ajouté par Excel
+
+	firstLine := data
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		firstLine = data[:i]
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		reader.Comma = ';'
+	}
+
+	return reader.ReadAll()
+}
+
+func readXLSX(r io.Reader) ([][]string, error) {
+	book, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer book.Close()
+
+	sheets := book.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, fmt.Errorf("workbook has no sheet")
+	}
+	sheet := sheets[0]
+
+	// Valeurs brutes : les nombres sans le format d'affichage du classeur (séparateurs de
+	// milliers, devise...), les dates en numéro de série converti ci-dessous
+	rows, err := book.GetRows(sheet, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, err
+	}
+	props, err := book.GetWorkbookProps()
+	if err != nil {
+		return nil, err
+	}
+	date1904 := props.Date1904 != nil && *props.Date1904
+
+	dateStyles := make(map[int]bool)
+	for i, row := range rows {
+		for j, value := range row {
+			serial, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			cell, err := excelize.CoordinatesToCellName(j+1, i+1)
+			if err != nil {
+				return nil, err
+			}
+			isDate, err := isDateCell(book, sheet, cell, dateStyles)
+			if err != nil {
+				return nil, err
+			}
+			if !isDate {
+				continue
+			}
+			t, err := excelize.ExcelDateToTime(serial, date1904)
+			if err != nil {
+				return nil, fmt.Errorf("invalid date in cell %s: %w", cell, err)
+			}
+			// Même texte qu'une date saisie dans un CSV (voir ParseDateIn)
+			t = t.Round(time.Second)
+			if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 {
+				row[j] = t.Format("2006-01-02")
+			} else {
+				row[j] = t.Format("2006-01-02 15:04:05")
+			}
+		}
+	}
+	return rows, nil
+}
+
+// isDateCell indique si la cellule est un nombre affiché comme une date ou une heure.
+// dateStyles garde le résultat par style, partagé par les cellules de la feuille.
+func isDateCell(book *excelize.File, sheet, cell string, dateStyles map[int]bool) (bool, error) {
+	styleID, err := book.GetCellStyle(sheet, cell)
+	if err != nil {
+		return false, err
+	}
+	isDate, ok := dateStyles[styleID]
+	if !ok {
+		style, err := book.GetStyle(styleID)
+		isDate = err == nil && isDateFormat(style)
+		dateStyles[styleID] = isDate
+	}
+	if !isDate {
+		return false, nil
+	}
+	// Une cellule texte qui ressemble à un nombre garde sa valeur
+	cellType, err := book.GetCellType(sheet, cell)
+	if err != nil {
+		return false, err
+	}
+	return cellType == excelize.CellTypeUnset || cellType == excelize.CellTypeNumber, nil
+}
+
+// Texte entre guillemets et sections entre crochets ([Red], [$-409]) d'un format de nombre
+var numFmtLiterals = regexp.MustCompile(`"[^"]*"|\[[^\]]*\]|\\.`)
+
+// isDateFormat indique si le format de nombre du style affiche une date ou une heure :
+// formats intégrés 14 à 22 et 45 à 47, ou format personnalisé avec jour, mois, année ou heure
+func isDateFormat(style *excelize.Style) bool {
+	if (style.NumFmt >= 14 && style.NumFmt <= 22) || (style.NumFmt >= 45 && style.NumFmt <= 47) {
+		return true
+	}
+	if style.CustomNumFmt == nil {
+		return false
+	}
+	format := numFmtLiterals.ReplaceAllString(*style.CustomNumFmt, "")
+	return strings.ContainsAny(strings.ToLower(format), "ydmhs")
+}
+
+// SpreadsheetRow donne accès aux cellules d'une ligne par nom de colonne
+type SpreadsheetRow struct {
+	Number  int // Numéro de ligne dans le fichier (l'en-tête est la ligne 1)
+	columns map[string]int
+	cells   []string
+}
+
+// SpreadsheetRows associe chaque ligne de données aux colonnes de l'en-tête.
+// Les noms de colonnes sont comparés sans tenir compte de la casse ni des espaces.
+// Les lignes entièrement vides sont ignorées.
+func SpreadsheetRows(rows [][]string) []SpreadsheetRow {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	columns := make(map[string]int, len(rows[0]))
+	for i, name := range rows[0] {
+		columns[normalizeColumn(name)] = i
+	}
+
+	var result []SpreadsheetRow
+	for i, cells := range rows[1:] {
+		if strings.TrimSpace(strings.Join(cells, "")) == "" {
+			continue
+		}
+		result = append(result, SpreadsheetRow{Number: i + 2, columns: columns, cells: cells})
+	}
+	return result
+}
+
+// HasColumn indique si l'en-tête contient la colonne
+func (r SpreadsheetRow) HasColumn(name string) bool {
+	_, ok := r.columns[normalizeColumn(name)]
+	return ok
+}
+
+// Get retourne la valeur de la cellule, vide si la colonne ou la cellule n'existe pas
+func (r SpreadsheetRow) Get(name string) string {
+	i, ok := r.columns[normalizeColumn(name)]
+	if !ok || i >= len(r.cells) {
+		return ""
+	}
+	return strings.TrimSpace(r.cells[i])
+}
+
+func normalizeColumn(name string) string {
+	name = strings.TrimPrefix(name, "\ufeff")
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), " ", "_"))
+}
+
+// normalizeNumber retire les espaces et les séparateurs de milliers et remplace la virgule
+// décimale par un point ("1 250,50", "1.250,50" et "1,250.50" valent 1250.50). Quand un seul
+// séparateur est suivi de trois chiffres ("1,250" ou "1.250"), il peut séparer les milliers
+// comme les décimales : le nombre est refusé plutôt que deviné.
+func normalizeNumber(value string) (string, error) {
+	value = strings.NewReplacer(" ", "", "\u00a0", "", "\u202f", "").Replace(strings.TrimSpace(value))
+	lastDot, lastComma := strings.LastIndex(value, "."), strings.LastIndex(value, ",")
+	if lastDot < 0 && lastComma < 0 {
+		return value, nil
+	}
+
+	// Les deux séparateurs : le dernier est la virgule décimale
+	if lastDot >= 0 && lastComma >= 0 {
+		decimalSep, thousandSep := ".", ","
+		if lastComma > lastDot {
+			decimalSep, thousandSep = ",", "."
+		}
+		integer, fraction, _ := strings.Cut(value, decimalSep)
+		if strings.Contains(fraction, decimalSep) || strings.Contains(fraction, thousandSep) {
+			return "", fmt.Errorf("invalid number '%s'", value)
+		}
+		if !thousandGroups(strings.Split(integer, thousandSep)) {
+			return "", fmt.Errorf("invalid thousands separators in '%s'", value)
+		}
+		return strings.ReplaceAll(integer, thousandSep, "") + "." + fraction, nil
+	}
+
+	sep := "."
+	if lastComma >= 0 {
+		sep = ","
+	}
+	parts := strings.Split(value, sep)
+	if len(parts) > 2 {
+		// Plusieurs fois le même séparateur : des milliers
+		if !thousandGroups(parts) {
+			return "", fmt.Errorf("invalid thousands separators in '%s'", value)
+		}
+		return strings.Join(parts, ""), nil
+	}
+	integer := strings.TrimLeft(parts[0], "+-")
+	if len(parts[1]) == 3 && integer != "" && integer != "0" {
+		return "", fmt.Errorf("ambiguous number '%s': write 1250 or 1250%s00", value, sep)
+	}
+	return parts[0] + "." + parts[1], nil
+}
+
+// thousandGroups indique si les groupes séparés par les milliers sont bien formés :
+// un premier groupe de 1 à 3 chiffres, les suivants de 3 chiffres
+func thousandGroups(groups []string) bool {
+	first := strings.TrimLeft(groups[0], "+-")
+	if len(first) < 1 || len(first) > 3 {
+		return false
+	}
+	for _, group := range groups[1:] {
+		if len(group) != 3 {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseYesNo lit un booléen (true/false, oui/non, yes/no, 1/0). Une cellule vide vaut false.
+func ParseYesNo(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "false", "non", "no", "n", "0":
+		return false, nil
+	case "true", "oui", "yes", "o", "y", "1":
+		return true, nil
+	}
+	return false, fmt.Errorf("invalid boolean '%s'", value)
+}
+
+// ParseDate lit une date au format YYYY-MM-DD ou DD/MM/YYYY
+func ParseDate(value string) (time.Time, error) {
+	return ParseDateIn(value, time.UTC)
+}
+
+// ParseDateIn lit une date comme ParseDate, à l'heure du fuseau donné
+func ParseDateIn(value string, loc *time.Location) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", "02/01/2006", "2006-01-02 15:04:05"} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date '%s', expected YYYY-MM-DD or DD/MM/YYYY", value)
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestReadXLSXRawValues(t *testing.T) {
+	book := excelize.NewFile()
+	defer book.Close()
+	sheet := book.GetSheetName(0)
+
+	thousands, _ := book.NewStyle(&excelize.Style{NumFmt: 4}) // #,##0.00
+	date, _ := book.NewStyle(&excelize.Style{NumFmt: 14})
+	dateTimeFormat := `dd/mm/yyyy hh:mm`
+	dateTime, _ := book.NewStyle(&excelize.Style{CustomNumFmt: &dateTimeFormat})
+	currencyFormat := `#,##0.00 "USD"`
+	currency, _ := book.NewStyle(&excelize.Style{CustomNumFmt: &currencyFormat})
+
+	cells := []struct {
+		cell  string
+		value interface{}
+		style int
+		want  string
+	}{
+		{"A1", 1250.5, thousands, "1250.5"},
+		{"B1", 12.345, 0, "12.345"},
+		{"C1", 1234567.8, currency, "1234567.8"},
+		{"D1", time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC), date, "2025-01-31"},
+		{"E1", time.Date(2025, 2, 1, 23, 30, 0, 0, time.UTC), dateTime, "2025-02-01 23:30:00"},
+		{"F1", "1.250", date, "1.250"},
+	}
+	for _, c := range cells {
+		if err := book.SetCellValue(sheet, c.cell, c.value); err != nil {
+			t.Fatal(err)
+		}
+		if c.style != 0 {
+			if err := book.SetCellStyle(sheet, c.cell, c.cell, c.style); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := book.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := readXLSX(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || len(rows[0]) != len(cells) {
+		t.Fatalf("readXLSX returned %v", rows)
+	}
+	for i, c := range cells {
+		if rows[0][i] != c.want {
+			t.Errorf("%s = %q, want %q", c.cell, rows[0][i], c.want)
+		}
+	}
+}
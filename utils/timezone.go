@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+	_ "time/tzdata" // Fuseaux embarqués : l'image de production n'a pas de base tzdata
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// OrgLocation retourne le fuseau horaire de l'organisation, lu dans ORG_TIMEZONE
+// (nom IANA, ex. Africa/Kinshasa ou Africa/Lubumbashi). UTC par défaut ou si le nom est invalide.
+// Les jours et les mois des rapports et des résumés journaliers sont ceux de ce fuseau.
+func OrgLocation() *time.Location {
+	name := strings.TrimSpace(Env("ORG_TIMEZONE"))
+	if name == "" {
+		return time.UTC
+	}
+	loc, err := loadLocation(name)
+	if err != nil {
+		log.Println("ORG_TIMEZONE:", err)
+		return time.UTC
+	}
+	return loc
+}
+
+// RequestLocation retourne le fuseau demandé par le paramètre tz de la requête,
+// celui de l'organisation par défaut. Un nom inconnu est une *fiber.Error 400.
+func RequestLocation(c *fiber.Ctx) (*time.Location, error) {
+	name := strings.TrimSpace(c.Query("tz"))
+	if name == "" {
+		return OrgLocation(), nil
+	}
+	loc, err := loadLocation(name)
+	if err != nil {
+		return nil, fiber.NewError(400, "Invalid tz, expected an IANA time zone such as Africa/Kinshasa")
+	}
+	return loc, nil
+}
+
+// loadLocation charge un fuseau IANA. Local est refusé : il dépend du serveur
+// et Postgres ne le connaît pas.
+func loadLocation(name string) (*time.Location, error) {
+	if name == "Local" {
+		return nil, errors.New("unknown time zone Local")
+	}
+	return time.LoadLocation(name)
+}
+
+// SameLocation indique si deux fuseaux portent le même nom
+func SameLocation(a, b *time.Location) bool {
+	return a.String() == b.String()
+}
+
+// StartOfDay retourne minuit du jour de t dans le fuseau donné
+func StartOfDay(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestOrgLocation(t *testing.T) {
+	tests := []struct {
+		env  string
+		want string
+	}{
+		{"", "UTC"},
+		{"Africa/Kinshasa", "Africa/Kinshasa"},
+		{" Africa/Lubumbashi ", "Africa/Lubumbashi"},
+		{"Local", "UTC"},
+		{"Mars/Olympus", "UTC"},
+	}
+	for _, tt := range tests {
+		t.Setenv("ORG_TIMEZONE", tt.env)
+		if got := OrgLocation().String(); got != tt.want {
+			t.Errorf("ORG_TIMEZONE=%q: OrgLocation() = %s, want %s", tt.env, got, tt.want)
+		}
+	}
+}
+
+// Les entrées de 23:30 et 00:30 heure locale, autour d'un changement de mois, tombent
+// le jour local et non le jour UTC
+func TestStartOfDay(t *testing.T) {
+	kinshasa := mustLoadLocation(t, "Africa/Kinshasa") // UTC+1
+	newYork := mustLoadLocation(t, "America/New_York") // UTC-5, UTC-4 en été
+
+	tests := []struct {
+		name string
+		t    time.Time
+		loc  *time.Location
+		want time.Time
+	}{
+		{"23:30 on the last day of the month", time.Date(2025, 1, 31, 22, 30, 0, 0, time.UTC), kinshasa,
+			time.Date(2025, 1, 31, 0, 0, 0, 0, kinshasa)},
+		{"00:30 on the first day of the month", time.Date(2025, 1, 31, 23, 30, 0, 0, time.UTC), kinshasa,
+			time.Date(2025, 2, 1, 0, 0, 0, 0, kinshasa)},
+		{"00:30 local is still the previous day in UTC", time.Date(2025, 1, 31, 23, 30, 0, 0, time.UTC), time.UTC,
+			time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)},
+		{"23:30 on the last day of the year", time.Date(2025, 1, 1, 4, 30, 0, 0, time.UTC), newYork,
+			time.Date(2024, 12, 31, 0, 0, 0, 0, newYork)},
+		{"00:30 on the first day of the year", time.Date(2025, 1, 1, 5, 30, 0, 0, time.UTC), newYork,
+			time.Date(2025, 1, 1, 0, 0, 0, 0, newYork)},
+		{"23:30 on the last day of a summer month", time.Date(2025, 8, 1, 3, 30, 0, 0, time.UTC), newYork,
+			time.Date(2025, 7, 31, 0, 0, 0, 0, newYork)},
+		{"00:30 on the first day of a summer month", time.Date(2025, 8, 1, 4, 30, 0, 0, time.UTC), newYork,
+			time.Date(2025, 8, 1, 0, 0, 0, 0, newYork)},
+		{"already midnight", time.Date(2025, 3, 1, 0, 0, 0, 0, kinshasa), kinshasa,
+			time.Date(2025, 3, 1, 0, 0, 0, 0, kinshasa)},
+	}
+	for _, tt := range tests {
+		got := StartOfDay(tt.t, tt.loc)
+		if !got.Equal(tt.want) || got.Location() != tt.loc {
+			t.Errorf("%s: StartOfDay(%s, %s) = %s, want %s", tt.name, tt.t, tt.loc, got, tt.want)
+		}
+	}
+}
+
+func TestParseDateIn(t *testing.T) {
+	kinshasa := mustLoadLocation(t, "Africa/Kinshasa")
+	newYork := mustLoadLocation(t, "America/New_York")
+
+	tests := []struct {
+		value   string
+		loc     *time.Location
+		want    time.Time // Instant UTC attendu
+		wantErr bool
+	}{
+		{"2025-01-31", kinshasa, time.Date(2025, 1, 30, 23, 0, 0, 0, time.UTC), false},
+		{"31/01/2025", kinshasa, time.Date(2025, 1, 30, 23, 0, 0, 0, time.UTC), false},
+		{"2025-01-31 23:30:00", kinshasa, time.Date(2025, 1, 31, 22, 30, 0, 0, time.UTC), false},
+		{"2025-02-01 00:30:00", kinshasa, time.Date(2025, 1, 31, 23, 30, 0, 0, time.UTC), false},
+		{"01/02/2025", kinshasa, time.Date(2025, 1, 31, 23, 0, 0, 0, time.UTC), false},
+		{"2024-12-31 23:30:00", newYork, time.Date(2025, 1, 1, 4, 30, 0, 0, time.UTC), false},
+		{"2025-01-01 00:30:00", newYork, time.Date(2025, 1, 1, 5, 30, 0, 0, time.UTC), false},
+		{"2025-07-31 23:30:00", newYork, time.Date(2025, 8, 1, 3, 30, 0, 0, time.UTC), false},
+		{"2025-01-31", time.UTC, time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC), false},
+		{"2025-02-30", kinshasa, time.Time{}, true},
+		{"31-01-2025", kinshasa, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDateIn(tt.value, tt.loc)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseDateIn(%q, %s) = %s, want an error", tt.value, tt.loc, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseDateIn(%q, %s) returned %v", tt.value, tt.loc, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseDateIn(%q, %s) = %s, want %s", tt.value, tt.loc, got.UTC(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// UploadDir retourne le dossier racine des fichiers uploadés (UPLOAD_DIR, par défaut "uploads")
+func UploadDir() string {
+	dir := Env("UPLOAD_DIR")
+	if dir == "" {
+		dir = "uploads"
+	}
+	return dir
+}
+
+// UploadURL construit l'URL publique d'un fichier à partir de son chemin relatif
+func UploadURL(relativePath string) string {
+	return "/uploads/" + filepath.ToSlash(relativePath)
+}
+
+// SaveUpload écrit le contenu dans le dossier d'upload en créant les sous-dossiers nécessaires
+func SaveUpload(relativePath string, data []byte) error {
+	fullPath := filepath.Join(UploadDir(), relativePath)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(fullPath, data, 0o644)
+}
+
+// RemoveUpload supprime un fichier du dossier d'upload, sans erreur s'il n'existe plus
+func RemoveUpload(relativePath string) error {
+	if relativePath == "" {
+		return nil
+	}
+	err := os.Remove(filepath.Join(UploadDir(), relativePath))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}